@@ -1,12 +1,14 @@
 module github.com/appbaseio/reactivesearch-api
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/bbalet/stopwords v1.0.0
 	github.com/buger/jsonparser v1.1.1
 	github.com/denisbrodbeck/machineid v1.0.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gertd/go-pluralize v0.1.7
 	github.com/getsentry/sentry-go v0.11.0
+	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/gobuffalo/envy v1.6.15 // indirect
 	github.com/gobuffalo/packr v1.22.0
 	github.com/google/uuid v1.3.0
@@ -29,6 +31,7 @@ require (
 	github.com/robfig/cron v1.1.0
 	github.com/rogpeppe/go-internal v1.2.2 // indirect
 	github.com/rs/cors v1.6.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/sergi/go-diff v1.2.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/smartystreets/goconvey v1.6.4
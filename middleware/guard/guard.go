@@ -0,0 +1,271 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+)
+
+const logTag = "[guard]"
+
+// Default global limits, the size matches the default http.max_content_length of elasticsearch.
+const (
+	defaultMaxBodyBytes   = 100 * 1024 * 1024
+	defaultMaxJSONDepth   = 100
+	defaultMaxReactDepth  = 10
+	defaultMaxReactFanOut = 100
+)
+
+// Names of the limits, returned with the errors.
+const (
+	LimitBodyBytes   = "max_body_bytes"
+	LimitJSONDepth   = "max_json_depth"
+	LimitReactDepth  = "max_react_depth"
+	LimitReactFanOut = "max_react_fan_out"
+	LimitBulkLines   = "max_bulk_lines"
+)
+
+var (
+	global     permission.RequestLimits
+	globalOnce sync.Once
+)
+
+// Global returns the global request limits configured with the env vars.
+func Global() permission.RequestLimits {
+	globalOnce.Do(func() {
+		global = permission.RequestLimits{
+			MaxBodyBytes:   envLimit("REQUEST_MAX_BODY_BYTES", defaultMaxBodyBytes),
+			MaxJSONDepth:   int(envLimit("REQUEST_MAX_JSON_DEPTH", defaultMaxJSONDepth)),
+			MaxReactDepth:  int(envLimit("REACTIVESEARCH_MAX_REACT_DEPTH", defaultMaxReactDepth)),
+			MaxReactFanOut: int(envLimit("REACTIVESEARCH_MAX_REACT_FAN_OUT", defaultMaxReactFanOut)),
+			MaxBulkLines:   int(envLimit("BULK_MAX_LINES", 0)),
+		}
+	})
+	return global
+}
+
+func envLimit(name string, defaultValue int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		log.Errorln(logTag, ": invalid", name, value, ", using", defaultValue)
+		return defaultValue
+	}
+	return limit
+}
+
+// For returns the limits of the request, the global limits tightened by the
+// limits of the permission if any.
+func For(req *http.Request) permission.RequestLimits {
+	limits := Global()
+	if reqPermission, err := permission.FromContext(req.Context()); err == nil {
+		limits = limits.Tighten(reqPermission.RequestLimits)
+	}
+	return limits
+}
+
+// Error is a violation of a request limit.
+type Error struct {
+	Code    int
+	Limit   string
+	Max     int64
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WriteBack writes the structured error of the violation.
+func (e *Error) WriteBack(w http.ResponseWriter) {
+	raw, err := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    e.Code,
+			"status":  http.StatusText(e.Code),
+			"message": e.Message,
+			"limit":   e.Limit,
+			"max":     e.Max,
+		},
+	})
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Code)
+	w.Write(raw)
+}
+
+// Body returns a middleware that checks the request body against the global
+// limits, before the body gets parsed by the other middleware.
+func Body() middleware.Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return guardBody(h, func(*http.Request) permission.RequestLimits {
+			return Global()
+		})
+	}
+}
+
+// Permission returns a middleware that checks the request body against the
+// limits of the permission, it must follow the auth middleware.
+func Permission() middleware.Middleware {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			reqPermission, err := permission.FromContext(req.Context())
+			if err != nil || reqPermission.RequestLimits == nil {
+				h(w, req)
+				return
+			}
+			guardBody(h, func(*http.Request) permission.RequestLimits {
+				return *reqPermission.RequestLimits
+			})(w, req)
+		}
+	}
+}
+
+func guardBody(h http.HandlerFunc, limitsFor func(*http.Request) permission.RequestLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		limits := limitsFor(req)
+		if req.Body == nil || req.Body == http.NoBody {
+			h(w, req)
+			return
+		}
+		body, err := readBody(req, limits.MaxBodyBytes)
+		if err != nil {
+			writeBack(w, err)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := CheckBody(body, isBulk(req), limits); err != nil {
+			writeBack(w, err)
+			return
+		}
+		h(w, req)
+	}
+}
+
+func writeBack(w http.ResponseWriter, err error) {
+	if guardErr, ok := err.(*Error); ok {
+		guardErr.WriteBack(w)
+		return
+	}
+	log.Errorln(logTag, ":", err)
+	(&Error{Code: http.StatusBadRequest, Message: fmt.Sprintf("can't read request body: %v", err)}).WriteBack(w)
+}
+
+// readBody reads the body up to the limit, the declared content length is
+// checked before reading anything.
+func readBody(req *http.Request, maxBytes int64) ([]byte, error) {
+	if maxBytes == 0 {
+		return ioutil.ReadAll(req.Body)
+	}
+	tooLarge := &Error{
+		Code:    http.StatusRequestEntityTooLarge,
+		Limit:   LimitBodyBytes,
+		Max:     maxBytes,
+		Message: fmt.Sprintf("request body is larger than %d bytes", maxBytes),
+	}
+	if req.ContentLength > maxBytes {
+		return nil, tooLarge
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, tooLarge
+	}
+	return body, nil
+}
+
+func isBulk(req *http.Request) bool {
+	return strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/_bulk")
+}
+
+// CheckBody checks the nesting of the JSON body and the number of lines of a
+// bulk body, the newline delimited bodies are checked line by line.
+func CheckBody(body []byte, bulk bool, limits permission.RequestLimits) error {
+	if limits.MaxJSONDepth > 0 {
+		if depth := jsonDepth(body, limits.MaxJSONDepth); depth > limits.MaxJSONDepth {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Limit:   LimitJSONDepth,
+				Max:     int64(limits.MaxJSONDepth),
+				Message: fmt.Sprintf("request body is nested deeper than %d levels", limits.MaxJSONDepth),
+			}
+		}
+	}
+	if bulk && limits.MaxBulkLines > 0 {
+		if lines := countLines(body); lines > limits.MaxBulkLines {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Limit:   LimitBulkLines,
+				Max:     int64(limits.MaxBulkLines),
+				Message: fmt.Sprintf("bulk request has %d lines, more than the %d allowed", lines, limits.MaxBulkLines),
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDepth returns the nesting of the objects and the arrays of the body, it
+// stops scanning as soon as the max is exceeded. The body isn't validated.
+func jsonDepth(body []byte, max int) int {
+	depth, deepest := 0, 0
+	inString, escaped := false, false
+	for _, c := range body {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > deepest {
+				deepest = depth
+				if deepest > max {
+					return deepest
+				}
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return deepest
+}
+
+// countLines returns the number of non empty lines of the body.
+func countLines(body []byte) int {
+	lines := 0
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,52 @@
+package guard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serve(mw func(http.HandlerFunc) http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mw(func(w http.ResponseWriter, req *http.Request) {})(w, req)
+	return w
+}
+
+func TestGuard(t *testing.T) {
+	globalOnce.Do(func() {})
+	global = permission.RequestLimits{MaxBodyBytes: 64, MaxJSONDepth: 3, MaxBulkLines: 2}
+	Convey("should reject the large bodies", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(`{"query":{"match_all":{}}}`))
+		So(serve(Body(), req).Code, ShouldEqual, http.StatusOK)
+		req = httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(strings.Repeat(" ", 65)))
+		w := serve(Body(), req)
+		So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(w.Body.String(), ShouldContainSubstring, `"limit":"max_body_bytes"`)
+	})
+	Convey("should reject the deeply nested bodies", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(`{"a":{"b":["[[[{"]}}`))
+		So(serve(Body(), req).Code, ShouldEqual, http.StatusOK)
+		req = httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(`{"a":{"b":[{}]}}`))
+		So(serve(Body(), req).Code, ShouldEqual, http.StatusBadRequest)
+	})
+	Convey("should count the lines of the bulk requests", t, func() {
+		body := "{\"index\":{}}\n{\"a\":1}\n"
+		So(serve(Body(), httptest.NewRequest(http.MethodPost, "/books/_bulk", strings.NewReader(body))).Code, ShouldEqual, http.StatusOK)
+		body += "{\"delete\":{}}\n"
+		So(serve(Body(), httptest.NewRequest(http.MethodPost, "/books/_bulk", strings.NewReader(body))).Code, ShouldEqual, http.StatusBadRequest)
+		So(serve(Body(), httptest.NewRequest(http.MethodPost, "/books/_msearch", strings.NewReader(body))).Code, ShouldEqual, http.StatusOK)
+	})
+	Convey("should apply the limits of the permission", t, func() {
+		p := &permission.Permission{Username: "key", RequestLimits: &permission.RequestLimits{MaxBodyBytes: 8}}
+		req := httptest.NewRequest(http.MethodPost, "/books/_search", strings.NewReader(`{"size":10}`))
+		req = req.WithContext(permission.NewContext(context.Background(), p))
+		So(serve(Permission(), req).Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(For(req).MaxJSONDepth, ShouldEqual, 3)
+		So(For(req).MaxBodyBytes, ShouldEqual, 8)
+	})
+}
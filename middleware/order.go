@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"net/http"
+
+	"github.com/appbaseio/reactivesearch-api/model/explain"
 )
 
 // Fifo is a type that implements Adapter. It provides
@@ -12,6 +14,7 @@ type Fifo string
 // The request will pass through the middleware in the sequence
 // in which they are passed in the function.
 func (f *Fifo) Adapt(h http.HandlerFunc, m ...Middleware) http.HandlerFunc {
+	h = skipOnDryRun(h)
 	for i := len(m) - 1; i >= 0; i-- {
 		h = m[i](h)
 	}
@@ -26,6 +29,7 @@ type Lifo string
 // The request will pass through the middleware in the opposite
 // sequence in which they are passed.
 func (l *Lifo) Adapt(h http.HandlerFunc, m ...Middleware) http.HandlerFunc {
+	h = skipOnDryRun(h)
 	for i := 0; i < len(m); i++ {
 		h = m[i](h)
 	}
@@ -40,8 +44,21 @@ type Single string
 
 // Adapt adapts the handler to a single middleware.
 func (s *Single) Adapt(h http.HandlerFunc, m ...Middleware) http.HandlerFunc {
+	h = skipOnDryRun(h)
 	if len(m) != 0 {
 		return m[0](h)
 	}
 	return h
 }
+
+// skipOnDryRun stops an explained request before it reaches the handler, the
+// middleware have recorded the outcome of their checks by then.
+func skipOnDryRun(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if report, err := explain.FromContext(req.Context()); err == nil {
+			report.Complete(req.Context())
+			return
+		}
+		h(w, req)
+	}
+}
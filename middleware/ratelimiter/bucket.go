@@ -0,0 +1,192 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ulule/limiter"
+
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+)
+
+// Bucket is a token bucket that refills at the rate per second up to the burst,
+// each request takes a token unless it has a cost.
+type Bucket struct {
+	Rate  float64 `json:"rate"`
+	Burst int64   `json:"burst"`
+}
+
+// capacity returns the burst of the bucket, it holds a second worth of tokens at least.
+func (b Bucket) capacity() int64 {
+	if b.Burst > 0 {
+		return b.Burst
+	}
+	return int64(math.Max(1, math.Ceil(b.Rate)))
+}
+
+// bucketStore takes the tokens of the buckets identified by the keys, the
+// explained requests only check the tokens without taking them.
+type bucketStore interface {
+	Take(ctx context.Context, key string, bucket Bucket, cost int64) (limiter.Context, error)
+}
+
+// bucketContext returns the limiter context of a bucket with the tokens left,
+// the reset is the instant the cost is available if the request wasn't
+// allowed, otherwise the instant the bucket is full again.
+func bucketContext(now time.Time, bucket Bucket, tokens float64, cost int64, allowed bool) limiter.Context {
+	capacity := bucket.capacity()
+	missing := float64(capacity) - tokens
+	if !allowed {
+		missing = float64(cost) - tokens
+	}
+	wait := time.Duration(missing / bucket.Rate * float64(time.Second))
+	return limiter.Context{
+		Limit:     capacity,
+		Remaining: int64(math.Floor(tokens)),
+		Reset:     int64(math.Ceil(float64(now.Add(wait).UnixNano()) / float64(time.Second))),
+		Reached:   !allowed,
+	}
+}
+
+// maxBuckets is the number of buckets after which the full buckets are dropped.
+const maxBuckets = 10000
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+	// the bucket of the last take, to know when the tokens refilled
+	bucket Bucket
+}
+
+// isFull checks whether the tokens of the bucket have been refilled up to its
+// capacity since it was last used.
+func (s *bucketState) isFull(now time.Time) bool {
+	capacity := float64(s.bucket.capacity())
+	return s.tokens+now.Sub(s.last).Seconds()*s.bucket.Rate >= capacity
+}
+
+// memoryBuckets keeps the buckets local to the node.
+type memoryBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+}
+
+func newMemoryBuckets() *memoryBuckets {
+	return &memoryBuckets{buckets: make(map[string]*bucketState)}
+}
+
+// Take takes the cost in tokens from the bucket of the key.
+func (m *memoryBuckets) Take(ctx context.Context, key string, bucket Bucket, cost int64) (limiter.Context, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if len(m.buckets) >= maxBuckets {
+		m.dropFull(now)
+	}
+	capacity := float64(bucket.capacity())
+	state, ok := m.buckets[key]
+	if !ok {
+		state = &bucketState{tokens: capacity, last: now}
+	}
+	tokens := math.Min(capacity, state.tokens+now.Sub(state.last).Seconds()*bucket.Rate)
+	allowed := tokens >= float64(cost)
+	if explain.IsDryRun(ctx) {
+		return bucketContext(now, bucket, tokens, cost, allowed), nil
+	}
+	if allowed {
+		tokens -= float64(cost)
+	}
+	state.tokens, state.last, state.bucket = tokens, now, bucket
+	m.buckets[key] = state
+	return bucketContext(now, bucket, state.tokens, cost, allowed), nil
+}
+
+// dropFull drops the buckets that have been refilled since they were last
+// used, a new bucket of the same key starts full so nothing is lost.
+func (m *memoryBuckets) dropFull(now time.Time) {
+	for key, state := range m.buckets {
+		if state.isFull(now) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// takeScript refills the bucket stored in a hash and takes the cost from it,
+// it returns whether the tokens were taken and the tokens left. The bucket
+// isn't updated if the cost is only being checked.
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local check = ARGV[5] == "1"
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = capacity
+	last = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - last) * rate / 1000)
+local allowed = 0
+if tokens >= cost then
+	allowed = 1
+end
+if check then
+	return {allowed, tostring(tokens)}
+end
+if allowed == 1 then
+	tokens = tokens - cost
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// Take takes the cost in tokens from the bucket of the key stored in redis, the
+// fallback policy applies while redis is unavailable.
+func (s *fallbackStore) Take(ctx context.Context, key string, bucket Bucket, cost int64) (limiter.Context, error) {
+	if s.connect() != nil {
+		lctx, err := s.takeFromRedis(key, bucket, cost, explain.IsDryRun(ctx))
+		if err == nil {
+			return lctx, nil
+		}
+		s.disconnect(err)
+	}
+	switch s.policy {
+	case FallbackAllow:
+		return bucketContext(time.Now(), bucket, float64(bucket.capacity()), cost, true), nil
+	case FallbackDeny:
+		return limiter.Context{}, errStoreUnavailable
+	default:
+		return s.localBuckets.Take(ctx, key, bucket, cost)
+	}
+}
+
+func (s *fallbackStore) takeFromRedis(key string, bucket Bucket, cost int64, check bool) (limiter.Context, error) {
+	now := time.Now()
+	checkArg := 0
+	if check {
+		checkArg = 1
+	}
+	result, err := s.client.Eval(takeScript, []string{keyPrefix + ":bucket:" + key},
+		bucket.Rate, bucket.capacity(), now.UnixNano()/int64(time.Millisecond), cost, checkArg).Result()
+	if err != nil {
+		return limiter.Context{}, err
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return limiter.Context{}, fmt.Errorf("unexpected result of the bucket script: %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	text, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return limiter.Context{}, fmt.Errorf("unexpected tokens of the bucket script: %v", values[1])
+	}
+	return bucketContext(now, bucket, tokens, cost, allowed == 1), nil
+}
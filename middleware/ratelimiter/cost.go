@@ -0,0 +1,32 @@
+package ratelimiter
+
+import (
+	"context"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// costCtxKey is a key against which the cost of a request is stored in the context.
+const costCtxKey = contextKey("cost")
+
+// NewCostContext returns a new context with the cost of the request, the cost
+// is charged against the category limit of the permission or the user instead
+// of a single request.
+func NewCostContext(ctx context.Context, cost int64) context.Context {
+	return context.WithValue(ctx, costCtxKey, cost)
+}
+
+// CostFromContext retrieves the cost of the request stored in the context.
+func CostFromContext(ctx context.Context) (int64, error) {
+	ctxCost := ctx.Value(costCtxKey)
+	if ctxCost == nil {
+		return 0, errors.NewNotFoundInContextError("cost")
+	}
+	cost, ok := ctxCost.(int64)
+	if !ok {
+		return 0, errors.NewInvalidCastError("ctxCost", "int64")
+	}
+	return cost, nil
+}
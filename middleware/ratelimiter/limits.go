@@ -0,0 +1,97 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+)
+
+// defaultAnonymousLimit is the bucket of the anonymous requests made from an IP.
+const defaultAnonymousLimit = "20:40"
+
+// parseBucket parses a bucket in the "rate[:burst]" format, a zero rate
+// returns a nil bucket that doesn't limit the requests.
+func parseBucket(value string) (*Bucket, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf(`invalid rate "%s", must be a positive number`, parts[0])
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+	bucket := &Bucket{Rate: rate}
+	if len(parts) == 2 {
+		burst, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf(`invalid burst "%s", must be a positive integer`, parts[1])
+		}
+		bucket.Burst = burst
+	}
+	return bucket, nil
+}
+
+// parseRouteGroups parses the limits per route group, i.e. per category, in
+// the "category=rate[:burst],..." format, e.g. "search=100:200,docs=20".
+func parseRouteGroups(value string) (map[category.Category]Bucket, error) {
+	groups := make(map[category.Category]Bucket)
+	if strings.TrimSpace(value) == "" {
+		return groups, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(`invalid route group limit "%s", must be in the "category=rate[:burst]" format`, entry)
+		}
+		// an unknown category is unmarshalled without an error
+		var c category.Category
+		name := strings.TrimSpace(parts[0])
+		raw, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &c); err != nil || c.String() != name {
+			return nil, fmt.Errorf(`invalid route group "%s", must be a category`, name)
+		}
+		bucket, err := parseBucket(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf(`invalid limit of the route group "%s": %v`, parts[0], err)
+		}
+		if bucket != nil {
+			groups[c] = *bucket
+		}
+	}
+	return groups, nil
+}
+
+// routeGroupsFromEnv returns the global limits of the route groups configured
+// with the RATE_LIMIT_ROUTE_GROUPS env var.
+func routeGroupsFromEnv() map[category.Category]Bucket {
+	groups, err := parseRouteGroups(os.Getenv("RATE_LIMIT_ROUTE_GROUPS"))
+	if err != nil {
+		log.Errorln(logTag, ": ignoring RATE_LIMIT_ROUTE_GROUPS :", err)
+		return make(map[category.Category]Bucket)
+	}
+	return groups
+}
+
+// anonymousFromEnv returns the per IP limit of the open routes configured
+// with the RATE_LIMIT_ANONYMOUS env var.
+func anonymousFromEnv() *Bucket {
+	value := os.Getenv("RATE_LIMIT_ANONYMOUS")
+	if value == "" {
+		value = defaultAnonymousLimit
+	}
+	bucket, err := parseBucket(value)
+	if err != nil {
+		log.Errorln(logTag, ": invalid RATE_LIMIT_ANONYMOUS, using", defaultAnonymousLimit, ":", err)
+		bucket, _ = parseBucket(defaultAnonymousLimit)
+	}
+	return bucket
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,20 +13,20 @@ import (
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
 	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/ulule/limiter"
-	"github.com/ulule/limiter/drivers/store/memory"
 )
 
 const (
 	logTag          = "[ratelimiter]"
 	defaultRedisDB  = 0
 	defaultMaxRetry = 4
-	redisAddr       = ""
-	redisPassword   = ""
+	redisAddr       = "localhost:6379"
 )
 
 var (
@@ -33,29 +34,56 @@ var (
 	once     sync.Once
 )
 
-// Ratelimiter limits the number of requests made by a permission per category
-// as well as per IP. Creating direct instances of RateLimiter should be avoided.
+// Ratelimiter limits the number of requests made by a permission or a user per
+// category, by a permission per IP, per route group and per IP for the open routes.
+// Creating direct instances of RateLimiter should be avoided.
 // ratelimiter.Instance returns the singleton instance of the Ratelimiter.
 type Ratelimiter struct {
 	sync.Mutex
+	store    limiter.Store
+	buckets  bucketStore
 	limiters map[string]*limiter.Limiter
+	// routeGroups are the global limits of the categories
+	routeGroups map[category.Category]Bucket
+	// anonymous is the per IP limit of the open routes
+	anonymous *Bucket
 }
 
 // Instance returns the singleton instance of ratelimiter.
 func Instance() *Ratelimiter {
 	once.Do(func() {
-		instance = &Ratelimiter{
-			limiters: make(map[string]*limiter.Limiter),
-		}
+		instance = newRatelimiter(newStore())
+		instance.routeGroups = routeGroupsFromEnv()
+		instance.anonymous = anonymousFromEnv()
 	})
 	return instance
 }
 
-// Limit middleware limits the requests made to elasticsearch for each permission.
+// newRatelimiter returns a rate limiter backed by the store, the token buckets
+// are kept in the store as well if it supports them.
+func newRatelimiter(store limiter.Store) *Ratelimiter {
+	buckets, ok := store.(bucketStore)
+	if !ok {
+		buckets = newMemoryBuckets()
+	}
+	return &Ratelimiter{
+		store:       store,
+		buckets:     buckets,
+		limiters:    make(map[string]*limiter.Limiter),
+		routeGroups: make(map[category.Category]Bucket),
+	}
+}
+
+// Limit middleware limits the requests made to elasticsearch for each permission and user.
 func Limit() middleware.Middleware {
 	return Instance().rateLimit
 }
 
+// LimitAnonymous middleware limits the requests made to the open routes per IP.
+func LimitAnonymous() middleware.Middleware {
+	return Instance().limitAnonymous
+}
+
 func (rl *Ratelimiter) rateLimit(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
@@ -67,9 +95,10 @@ func (rl *Ratelimiter) rateLimit(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		errMsg := "An error occurred while validating rate limit"
+		var limits []limiter.Context
 		if reqCredential == credential.Permission {
 			remoteIP := iplookup.FromRequest(req)
-			errMsg := "An error occurred while validating rate limit"
 			reqPermission, err := permission.FromContext(ctx)
 			if err != nil {
 				log.Errorln(logTag, ":", err)
@@ -93,60 +122,163 @@ func (rl *Ratelimiter) rateLimit(h http.HandlerFunc) http.HandlerFunc {
 			}
 
 			key := fmt.Sprintf("%s:%s", reqPermission.Username, *reqCategory)
-			if rl.limitExceededByACL(key, categoryLimit) {
-				util.WriteBackMessage(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			lctx, err := rl.takeCategory(ctx, key, categoryLimit, reqPermission.Limits.Burst)
+			if !rl.allow(w, req, lctx, err) {
 				return
 			}
+			limits = append(limits, lctx)
 
 			// limit on IP per hour
 			ipLimit := reqPermission.GetIPLimit()
 			key = fmt.Sprintf("%s:%s", reqPermission.Username, remoteIP)
-			if rl.limitExceededByIP(key, ipLimit) {
-				util.WriteBackMessage(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			lctx, err = rl.take(ctx, key, ipLimit, 1*time.Hour)
+			if !rl.allow(w, req, lctx, err) {
+				return
+			}
+			limits = append(limits, lctx)
+		}
+
+		if reqCredential == credential.User {
+			reqUser, err := user.FromContext(ctx)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, errMsg, http.StatusInternalServerError)
 				return
 			}
+			// the users are limited only in the categories they have a limit for
+			reqCategory, err := category.FromContext(ctx)
+			if err == nil && reqUser.Limits != nil {
+				if categoryLimit, err := reqUser.Limits.For(*reqCategory); err == nil && categoryLimit > 0 {
+					key := fmt.Sprintf("user:%s:%s", reqUser.Username, *reqCategory)
+					lctx, err := rl.takeCategory(ctx, key, categoryLimit, reqUser.Limits.Burst)
+					if !rl.allow(w, req, lctx, err) {
+						return
+					}
+					limits = append(limits, lctx)
+				}
+			}
 		}
 
+		// global limit of the route group
+		if reqCategory, err := category.FromContext(ctx); err == nil {
+			if bucket, ok := rl.routeGroups[*reqCategory]; ok {
+				lctx, err := rl.buckets.Take(ctx, fmt.Sprintf("group:%s", *reqCategory), bucket, 1)
+				if !rl.allow(w, req, lctx, err) {
+					return
+				}
+				limits = append(limits, lctx)
+			}
+		}
+
+		if len(limits) > 0 {
+			setHeaders(w, tightest(limits))
+		}
 		h(w, req)
 	}
 }
 
-func (rl *Ratelimiter) limitExceededByACL(key string, aclLimit int64) bool {
-	period := 1 * time.Second
-	rem, _ := rl.peekLimit(key, aclLimit, period)
-	if rem <= 0 {
-		return true
+func (rl *Ratelimiter) limitAnonymous(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if rl.anonymous == nil {
+			h(w, req)
+			return
+		}
+		key := fmt.Sprintf("anonymous:%s", iplookup.FromRequest(req))
+		lctx, err := rl.buckets.Take(req.Context(), key, *rl.anonymous, 1)
+		if !rl.allow(w, req, lctx, err) {
+			return
+		}
+		setHeaders(w, lctx)
+		h(w, req)
 	}
-	rl.limit(key, aclLimit, period)
-	return false
 }
 
-func (rl *Ratelimiter) limitExceededByIP(key string, ipLimit int64) bool {
-	period := 1 * time.Hour
-	rem, _ := rl.peekLimit(key, ipLimit, period)
-	if rem <= 0 {
-		return true
+// allow writes back an error along with the rate limit headers if the limit
+// is reached or the store failed.
+func (rl *Ratelimiter) allow(w http.ResponseWriter, req *http.Request, lctx limiter.Context, err error) bool {
+	if err == errStoreUnavailable {
+		util.WriteBackError(w, err.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		telemetry.WriteBackErrorWithTelemetry(req, w, "An error occurred while validating rate limit", http.StatusInternalServerError)
+		return false
+	}
+	if lctx.Reached {
+		setHeaders(w, lctx)
+		w.Header().Set("Retry-After", w.Header().Get("RateLimit-Reset"))
+		util.WriteBackMessage(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return false
 	}
-	rl.limit(key, ipLimit, period)
-	return false
+	return true
 }
 
-func (rl *Ratelimiter) peekLimit(key string, limit int64, period time.Duration) (int64, bool) {
-	l := rl.getLimiter(key, limit, period)
-	if c, err := l.Peek(context.Background(), key); err == nil {
-		return c.Remaining, c.Reached
+// tightest returns the limit with the fewest remaining requests.
+func tightest(limits []limiter.Context) limiter.Context {
+	result := limits[0]
+	for _, lctx := range limits[1:] {
+		if lctx.Remaining < result.Remaining {
+			result = lctx
+		}
 	}
-	// an error getting the limiter context ...
-	return -1, false
+	return result
 }
 
-func (rl *Ratelimiter) limit(key string, limit int64, period time.Duration) (int64, bool) {
+// setHeaders sets the standard RateLimit-* headers, the reset is the number
+// of seconds until the limit resets.
+func setHeaders(w http.ResponseWriter, lctx limiter.Context) {
+	remaining := lctx.Remaining
+	if remaining < 0 {
+		remaining = 0
+	}
+	reset := lctx.Reset - time.Now().Unix()
+	if reset < 0 {
+		reset = 0
+	}
+	w.Header().Set("RateLimit-Limit", strconv.FormatInt(lctx.Limit, 10))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+}
+
+// takeCategory consumes a request from the limit per second of the key, the
+// limit is a token bucket if the burst is set. A request with a cost in the
+// context always takes its cost from the bucket, a cost larger than the bucket
+// empties it.
+func (rl *Ratelimiter) takeCategory(ctx context.Context, key string, limit, burst int64) (limiter.Context, error) {
+	cost, err := CostFromContext(ctx)
+	if err != nil {
+		cost = 1
+	}
+	if limit > 0 && (burst > 0 || err == nil) {
+		bucket := Bucket{Rate: float64(limit), Burst: burst}
+		if cost > bucket.capacity() {
+			cost = bucket.capacity()
+		}
+		if cost < 1 {
+			cost = 1
+		}
+		return rl.buckets.Take(ctx, key, bucket, cost)
+	}
+	return rl.take(ctx, key, limit, 1*time.Second)
+}
+
+// take consumes a request from the limit of the key unless the limit has
+// already been reached, the explained requests don't consume anything.
+func (rl *Ratelimiter) take(ctx context.Context, key string, limit int64, period time.Duration) (limiter.Context, error) {
 	l := rl.getLimiter(key, limit, period)
-	if c, err := l.Get(context.Background(), key); err == nil {
-		return c.Remaining, c.Reached
+	lctx, err := l.Peek(ctx, key)
+	if err != nil {
+		return lctx, err
+	}
+	if lctx.Remaining <= 0 {
+		lctx.Reached = true
+		return lctx, nil
 	}
-	// an error getting the limiter context ...
-	return -1, false
+	if explain.IsDryRun(ctx) {
+		return lctx, nil
+	}
+	return l.Get(ctx, key)
 }
 
 func (rl *Ratelimiter) getLimiter(key string, limit int64, period time.Duration) *limiter.Limiter {
@@ -164,38 +296,13 @@ func (rl *Ratelimiter) getLimiter(key string, limit int64, period time.Duration)
 
 // A new instance for the given key is stored in the map each time this method is invoked.
 // The access must be mediated by some kind of synchronization mechanism to prevent concurrent
-// read/write operations to the map and vars.
+// read/write operations to the map and vars. The instances share the store of the rate limiter.
 func (rl *Ratelimiter) newLimiter(key string, limit int64, period time.Duration) *limiter.Limiter {
-	store := memory.NewStore()
 	rate := limiter.Rate{
 		Limit:  limit,
 		Period: period,
 	}
-	instance := limiter.New(store, rate)
+	instance := limiter.New(rl.store, rate)
 	rl.limiters[key] = instance
 	return instance
 }
-
-// func (rl *Ratelimiter) newLimiterWithRedis(key string, limit int64, period time.Duration) *limiter.Limiter {
-// 	option := &goredis.Options{
-// 		Addr:     redisAddr,
-// 		Password: redisPassword,
-// 		DB:       defaultRedisDB,
-// 	}
-// 	client := goredis.NewClient(option)
-// 	store, err := redis.NewStoreWithOptions(client, limiter.StoreOptions{
-// 		Prefix:   key,
-// 		MaxRetry: defaultMaxRetry,
-// 	})
-// 	if err != nil {
-// 		log.Printf("%s: cannot create redis store for the rate limiter: %v", logTag, err)
-// 		return nil
-// 	}
-// 	rate := limiter.Rate{
-// 		Limit:  limit,
-// 		Period: period,
-// 	}
-// 	instance := limiter.New(store, rate)
-// 	rl.limiters[key] = instance
-// 	return instance
-// }
@@ -0,0 +1,202 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	goredis "github.com/go-redis/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ulule/limiter"
+	"github.com/ulule/limiter/drivers/store/memory"
+)
+
+func limitedRequest() *http.Request {
+	p := &permission.Permission{Username: "key", Limits: &permission.Limits{SearchLimit: 2, IPLimit: 100}}
+	search := category.Search
+	ctx := credential.NewContext(context.Background(), credential.Permission)
+	ctx = permission.NewContext(ctx, p)
+	ctx = category.NewContext(ctx, &search)
+	req := httptest.NewRequest(http.MethodGet, "/books/_search", nil)
+	return req.WithContext(ctx)
+}
+
+func serve(rl *Ratelimiter) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rl.rateLimit(func(w http.ResponseWriter, req *http.Request) {})(w, limitedRequest())
+	return w
+}
+
+func TestRatelimiter(t *testing.T) {
+	Convey("should set the rate limit headers", t, func() {
+		rl := newRatelimiter(memory.NewStore())
+		w := serve(rl)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("RateLimit-Limit"), ShouldEqual, "2")
+		So(w.Header().Get("RateLimit-Remaining"), ShouldEqual, "1")
+		So(serve(rl).Code, ShouldEqual, http.StatusOK)
+		w = serve(rl)
+		So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(w.Header().Get("RateLimit-Remaining"), ShouldEqual, "0")
+		So(w.Header().Get("Retry-After"), ShouldNotBeEmpty)
+	})
+	Convey("should share the limits among the nodes with redis", t, func() {
+		server, err := miniredis.Run()
+		So(err, ShouldBeNil)
+		defer server.Close()
+		client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+		node1 := newRatelimiter(newRedisStore(client, memory.NewStore(), FallbackMemory))
+		node2 := newRatelimiter(newRedisStore(client, memory.NewStore(), FallbackMemory))
+		So(serve(node1).Code, ShouldEqual, http.StatusOK)
+		So(serve(node2).Code, ShouldEqual, http.StatusOK)
+		So(serve(node1).Code, ShouldEqual, http.StatusTooManyRequests)
+	})
+	Convey("should apply the fallback policy when redis is down", t, func() {
+		server, err := miniredis.Run()
+		So(err, ShouldBeNil)
+		client := goredis.NewClient(&goredis.Options{Addr: server.Addr(), MaxRetries: 0, DialTimeout: 100 * time.Millisecond})
+		allow := newRedisStore(client, memory.NewStore(), FallbackAllow)
+		deny := newRedisStore(client, memory.NewStore(), FallbackDeny)
+		local := newRedisStore(client, memory.NewStore(), FallbackMemory)
+		server.Close()
+
+		rate := limiter.Rate{Limit: 1, Period: time.Second}
+		lctx, err := allow.Get(context.Background(), "key", rate)
+		So(err, ShouldBeNil)
+		So(lctx.Remaining, ShouldEqual, 1)
+		_, err = deny.Get(context.Background(), "key", rate)
+		So(err, ShouldEqual, errStoreUnavailable)
+		lctx, err = local.Get(context.Background(), "key", rate)
+		So(err, ShouldBeNil)
+		So(lctx.Remaining, ShouldEqual, 0)
+		So(serve(newRatelimiter(deny)).Code, ShouldEqual, http.StatusServiceUnavailable)
+	})
+	Convey("should burst up to the size of the bucket", t, func() {
+		bucket := Bucket{Rate: 1, Burst: 3}
+		server, err := miniredis.Run()
+		So(err, ShouldBeNil)
+		defer server.Close()
+		client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+		for _, buckets := range []bucketStore{newMemoryBuckets(), newRedisStore(client, memory.NewStore(), FallbackMemory)} {
+			for i := 0; i < 3; i++ {
+				lctx, err := buckets.Take(context.Background(), "key", bucket, 1)
+				So(err, ShouldBeNil)
+				So(lctx.Reached, ShouldBeFalse)
+				So(lctx.Remaining, ShouldEqual, 2-i)
+			}
+			lctx, err := buckets.Take(context.Background(), "key", bucket, 1)
+			So(err, ShouldBeNil)
+			So(lctx.Reached, ShouldBeTrue)
+			So(lctx.Limit, ShouldEqual, 3)
+		}
+	})
+	Convey("should only drop the buckets that have been refilled", t, func() {
+		buckets := newMemoryBuckets()
+		slow := Bucket{Rate: 0.01, Burst: 3}
+		fast := Bucket{Rate: 100, Burst: 3}
+		_, err := buckets.Take(context.Background(), "slow", slow, 3)
+		So(err, ShouldBeNil)
+		_, err = buckets.Take(context.Background(), "fast", fast, 3)
+		So(err, ShouldBeNil)
+		buckets.dropFull(time.Now().Add(2 * time.Minute))
+		So(buckets.buckets, ShouldContainKey, "slow")
+		So(buckets.buckets, ShouldNotContainKey, "fast")
+	})
+	Convey("should not take any token for the explained requests", t, func() {
+		rl := newRatelimiter(memory.NewStore())
+		explained := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := limitedRequest()
+			ctx := explain.NewContext(req.Context(), explain.NewReport())
+			rl.rateLimit(func(w http.ResponseWriter, req *http.Request) {})(w, req.WithContext(ctx))
+			return w
+		}
+		for i := 0; i < 3; i++ {
+			So(explained().Code, ShouldEqual, http.StatusOK)
+		}
+		So(serve(rl).Header().Get("RateLimit-Remaining"), ShouldEqual, "1")
+
+		server, err := miniredis.Run()
+		So(err, ShouldBeNil)
+		defer server.Close()
+		client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+		bucket := Bucket{Rate: 1, Burst: 2}
+		ctx := explain.NewContext(context.Background(), explain.NewReport())
+		for _, buckets := range []bucketStore{newMemoryBuckets(), newRedisStore(client, memory.NewStore(), FallbackMemory)} {
+			for i := 0; i < 3; i++ {
+				lctx, err := buckets.Take(ctx, "key", bucket, 2)
+				So(err, ShouldBeNil)
+				So(lctx.Reached, ShouldBeFalse)
+			}
+			lctx, err := buckets.Take(ctx, "key", bucket, 3)
+			So(err, ShouldBeNil)
+			So(lctx.Reached, ShouldBeTrue)
+		}
+	})
+	Convey("should charge the cost of the request", t, func() {
+		rl := newRatelimiter(memory.NewStore())
+		request := func(cost int64) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := limitedRequest()
+			req = req.WithContext(NewCostContext(req.Context(), cost))
+			rl.rateLimit(func(w http.ResponseWriter, req *http.Request) {})(w, req)
+			return w
+		}
+		w := request(1)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("RateLimit-Remaining"), ShouldEqual, "1")
+		// the cost is capped to the size of the bucket
+		w = request(5)
+		So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(w.Header().Get("RateLimit-Remaining"), ShouldEqual, "1")
+	})
+	Convey("should limit the users and the route groups", t, func() {
+		rl := newRatelimiter(memory.NewStore())
+		rl.routeGroups, _ = parseRouteGroups("docs=1:1")
+		docs, search := category.Docs, category.Search
+		u := &user.User{Username: "admin", Limits: &permission.Limits{SearchLimit: 1}}
+		request := func(c *category.Category) int {
+			ctx := credential.NewContext(context.Background(), credential.User)
+			ctx = user.NewContext(ctx, u)
+			ctx = category.NewContext(ctx, c)
+			w := httptest.NewRecorder()
+			rl.rateLimit(func(w http.ResponseWriter, req *http.Request) {})(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			return w.Code
+		}
+		So(request(&search), ShouldEqual, http.StatusOK)
+		So(request(&search), ShouldEqual, http.StatusTooManyRequests)
+		So(request(&docs), ShouldEqual, http.StatusOK)
+		So(request(&docs), ShouldEqual, http.StatusTooManyRequests)
+	})
+	Convey("should limit the anonymous requests per IP", t, func() {
+		rl := newRatelimiter(memory.NewStore())
+		rl.anonymous, _ = parseBucket("1")
+		serve := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			rl.limitAnonymous(func(w http.ResponseWriter, req *http.Request) {})(w, httptest.NewRequest(http.MethodPost, "/_reactivesearch/validate", nil))
+			return w
+		}
+		So(serve().Code, ShouldEqual, http.StatusOK)
+		w := serve()
+		So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(w.Header().Get("Retry-After"), ShouldBeIn, []string{"1", "2"})
+	})
+	Convey("should parse the limits of the route groups", t, func() {
+		groups, err := parseRouteGroups("search=100:200, docs=20")
+		So(err, ShouldBeNil)
+		So(groups[category.Search], ShouldResemble, Bucket{Rate: 100, Burst: 200})
+		So(groups[category.Docs], ShouldResemble, Bucket{Rate: 20})
+		_, err = parseRouteGroups("searches=1")
+		So(err, ShouldNotBeNil)
+		_, err = parseRouteGroups("search=1:0")
+		So(err, ShouldNotBeNil)
+	})
+}
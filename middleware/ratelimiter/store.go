@@ -0,0 +1,180 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+	"github.com/ulule/limiter"
+	"github.com/ulule/limiter/drivers/store/memory"
+	"github.com/ulule/limiter/drivers/store/redis"
+)
+
+// Stores of the rate limits.
+const (
+	// StoreMemory keeps the rate limits local to the node.
+	StoreMemory = "memory"
+	// StoreRedis shares the rate limits among the nodes.
+	StoreRedis = "redis"
+)
+
+// Policies applied while the redis store is unavailable.
+const (
+	// FallbackMemory limits the requests with the local store of the node.
+	FallbackMemory = "memory"
+	// FallbackAllow lets the requests through without any limit.
+	FallbackAllow = "allow"
+	// FallbackDeny rejects the requests.
+	FallbackDeny = "deny"
+)
+
+const (
+	keyPrefix = "ratelimiter"
+	// reconnectInterval is the time between the attempts to reconnect to redis
+	reconnectInterval = 10 * time.Second
+)
+
+// errStoreUnavailable is returned by the store when the fallback policy denies the requests.
+var errStoreUnavailable = fmt.Errorf("rate limit store is unavailable")
+
+// newStore returns the store configured with the RATE_LIMIT_STORE env var.
+func newStore() limiter.Store {
+	local := memory.NewStoreWithOptions(limiter.StoreOptions{
+		Prefix:          keyPrefix,
+		CleanUpInterval: limiter.DefaultCleanUpInterval,
+	})
+	storeType := os.Getenv("RATE_LIMIT_STORE")
+	if storeType == "" || storeType == StoreMemory {
+		return local
+	}
+	if storeType != StoreRedis {
+		log.Warnln(logTag, ": unknown rate limit store", storeType, ", using the", StoreMemory, "store")
+		return local
+	}
+
+	db := defaultRedisDB
+	if value := os.Getenv("REDIS_DB"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Warnln(logTag, ": invalid REDIS_DB", value, ", using the db", defaultRedisDB)
+		} else {
+			db = parsed
+		}
+	}
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = redisAddr
+	}
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       db,
+	})
+	policy := os.Getenv("RATE_LIMIT_REDIS_FALLBACK")
+	switch policy {
+	case FallbackMemory, FallbackAllow, FallbackDeny:
+	case "":
+		policy = FallbackMemory
+	default:
+		log.Warnln(logTag, ": unknown rate limit fallback policy", policy, ", using", FallbackMemory)
+		policy = FallbackMemory
+	}
+	return newRedisStore(client, local, policy)
+}
+
+// fallbackStore keeps the rate limits in redis and falls back to the policy
+// while redis is unavailable, the connection is retried in the background of
+// the requests.
+type fallbackStore struct {
+	mu          sync.Mutex
+	client      redis.Client
+	primary     limiter.Store
+	lastAttempt time.Time
+	local       limiter.Store
+	// localBuckets are the token buckets used by the memory fallback policy
+	localBuckets *memoryBuckets
+	policy       string
+}
+
+func newRedisStore(client redis.Client, local limiter.Store, policy string) *fallbackStore {
+	s := &fallbackStore{client: client, local: local, localBuckets: newMemoryBuckets(), policy: policy}
+	s.connect()
+	return s
+}
+
+// connect returns the redis store, it is created at most once per reconnect interval.
+func (s *fallbackStore) connect() limiter.Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.primary != nil || time.Since(s.lastAttempt) < reconnectInterval {
+		return s.primary
+	}
+	s.lastAttempt = time.Now()
+	store, err := redis.NewStoreWithOptions(s.client, limiter.StoreOptions{
+		Prefix:   keyPrefix,
+		MaxRetry: defaultMaxRetry,
+	})
+	if err != nil {
+		log.Errorln(logTag, ": cannot connect to the redis store, falling back to", s.policy, ":", err)
+		return nil
+	}
+	s.primary = store
+	return store
+}
+
+// disconnect drops the redis store after a failure so that the next request
+// reconnects to it once the interval has passed.
+func (s *fallbackStore) disconnect(err error) {
+	log.Errorln(logTag, ": redis store failed, falling back to", s.policy, ":", err)
+	s.mu.Lock()
+	s.primary = nil
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+}
+
+// Get returns the limit for given identifier.
+func (s *fallbackStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	if store := s.connect(); store != nil {
+		lctx, err := store.Get(ctx, key, rate)
+		if err == nil {
+			return lctx, nil
+		}
+		s.disconnect(err)
+	}
+	return s.fallback(ctx, key, rate, false)
+}
+
+// Peek returns the limit for given identifier, without modification on current values.
+func (s *fallbackStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	if store := s.connect(); store != nil {
+		lctx, err := store.Peek(ctx, key, rate)
+		if err == nil {
+			return lctx, nil
+		}
+		s.disconnect(err)
+	}
+	return s.fallback(ctx, key, rate, true)
+}
+
+func (s *fallbackStore) fallback(ctx context.Context, key string, rate limiter.Rate, peek bool) (limiter.Context, error) {
+	switch s.policy {
+	case FallbackAllow:
+		return limiter.Context{
+			Limit:     rate.Limit,
+			Remaining: rate.Limit,
+			Reset:     time.Now().Add(rate.Period).Unix(),
+		}, nil
+	case FallbackDeny:
+		return limiter.Context{}, errStoreUnavailable
+	default:
+		if peek {
+			return s.local.Peek(ctx, key, rate)
+		}
+		return s.local.Get(ctx, key, rate)
+	}
+}
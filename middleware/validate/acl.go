@@ -24,7 +24,7 @@ func validateACL(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		if req.Method == http.MethodGet {
 			if req.RequestURI == "/" {
-				h(w, req)
+				allow(h, w, req, "acl", "not validated for the route")
 				return
 			}
 		}
@@ -54,12 +54,11 @@ func validateACL(h http.HandlerFunc) http.HandlerFunc {
 
 		if !ok {
 			msg := fmt.Sprintf(`credentials cannot access "%s" acl`, reqACL.String())
-			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-			telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+			deny(h, w, req, "acl", msg)
 			return
 		}
 
-		h(w, req)
+		allow(h, w, req, "acl", fmt.Sprintf(`credentials can access "%s" acl`, reqACL.String()))
 	}
 }
 
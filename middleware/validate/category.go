@@ -28,12 +28,19 @@ func validateCategory(h http.HandlerFunc) http.HandlerFunc {
 		// GET /
 		if req.Method == http.MethodGet {
 			if req.RequestURI == "/" || strings.HasSuffix(req.RequestURI, "_user") {
-				h(w, req)
+				allow(h, w, req, "category", "not validated for the route")
 				return
 			}
 		}
 		ctx := req.Context()
 
+		// users can always change their own password
+		if reqUser, err := user.FromContext(ctx); err == nil && req.Method == http.MethodPatch &&
+			req.URL.Path == user.PasswordChangePath(reqUser.Username) {
+			allow(h, w, req, "category", "users can always change their own password")
+			return
+		}
+
 		errMsg := "an error occurred while validating request category"
 		reqCategory, err := category.FromContext(ctx)
 		if err != nil {
@@ -58,12 +65,11 @@ func validateCategory(h http.HandlerFunc) http.HandlerFunc {
 
 		if !ok {
 			msg := fmt.Sprintf(`credential can't access "%s" category`, reqCategory.String())
-			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-			telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+			deny(h, w, req, "category", msg)
 			return
 		}
 
-		h(w, req)
+		allow(h, w, req, "category", fmt.Sprintf(`credential can access "%s" category`, reqCategory.String()))
 	}
 }
 
@@ -3,16 +3,19 @@ package validate
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
 )
 
-// PermissionExpiry returns a middleware that checks whether a permission is expired or not.
+// PermissionExpiry returns a middleware that checks whether a permission is expired
+// or not, and whether its schedule allows it to be used at the time of the request.
 func PermissionExpiry() middleware.Middleware {
 	return validateExpiry
 }
@@ -43,14 +46,26 @@ func validateExpiry(h http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 
+			// both of the checks are evaluated, so that an explained request
+			// reports an expired permission outside of its schedule as such
 			if expired {
 				msg := fmt.Sprintf("permission with username=%s is expired", reqPermission.Username)
-				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+				if !fail(w, req, "expiry", msg) {
+					return
+				}
+			} else {
+				explain.Record(ctx, "expiry", true, "")
+			}
+
+			if err := reqPermission.CheckSchedule(time.Now()); err != nil {
+				msg := fmt.Sprintf("permission with username=%s can't be used now: %v", reqPermission.Username, err)
+				deny(h, w, req, "schedule", msg)
 				return
 			}
+			allow(h, w, req, "schedule", "")
+			return
 		}
 
-		h(w, req)
+		allow(h, w, req, "expiry", "")
 	}
 }
@@ -0,0 +1,35 @@
+package validate
+
+import (
+	"net/http"
+
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+)
+
+// allow records the passed check of an explained request and serves the request.
+func allow(h http.HandlerFunc, w http.ResponseWriter, req *http.Request, check, msg string) {
+	explain.Record(req.Context(), check, true, msg)
+	h(w, req)
+}
+
+// deny rejects the request with the message of the failed check, an explained
+// request records the failure instead and goes on with the rest of the checks.
+func deny(h http.HandlerFunc, w http.ResponseWriter, req *http.Request, check, msg string) {
+	if fail(w, req, check, msg) {
+		h(w, req)
+	}
+}
+
+// fail records the failed check of an explained request and returns true, so
+// that the following checks of the same middleware are evaluated as well. The
+// other requests are rejected with the message of the check.
+func fail(w http.ResponseWriter, req *http.Request, check, msg string) bool {
+	if explain.IsDryRun(req.Context()) {
+		explain.Record(req.Context(), check, false, msg)
+		return true
+	}
+	w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+	telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+	return false
+}
@@ -48,10 +48,11 @@ func indices(h http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 			if !ok {
-				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				telemetry.WriteBackErrorWithTelemetry(req, w, "credentials cannot access cluster level routes", http.StatusUnauthorized)
+				deny(h, w, req, "indices", "credentials cannot access cluster level routes")
 				return
 			}
+			allow(h, w, req, "indices", "credentials can access cluster level routes")
+			return
 		} else {
 			// validate index level access
 			ok, err := allowedIndexAccess(ctx, reqCredential, reqIndices)
@@ -62,13 +63,12 @@ func indices(h http.HandlerFunc) http.HandlerFunc {
 			}
 			if !ok {
 				msg := fmt.Sprintf("credentials cannot access %v index/indices", reqIndices)
-				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+				deny(h, w, req, "indices", msg)
 				return
 			}
 		}
 
-		h(w, req)
+		allow(h, w, req, "indices", fmt.Sprintf("credentials can access %v index/indices", reqIndices))
 	}
 }
 
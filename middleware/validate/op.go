@@ -47,12 +47,11 @@ func operation(h http.HandlerFunc) http.HandlerFunc {
 
 		if !ok {
 			msg := fmt.Sprintf(`credential cannot perform "%v" operation`, reqOp.String())
-			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-			telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+			deny(h, w, req, "op", msg)
 			return
 		}
 
-		h(w, req)
+		allow(h, w, req, "op", fmt.Sprintf(`credential can perform "%v" operation`, reqOp.String()))
 	}
 }
 
@@ -2,8 +2,6 @@ package validate
 
 import (
 	"net/http"
-	"regexp"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -11,6 +9,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/credential"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 )
 
 // Referers returns a middleware that validates the request referers against the permission referers.
@@ -39,32 +38,12 @@ func referers(h http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 
-			var validated bool
-			for _, referer := range reqPermission.Referers {
-				if referer == "*" {
-					validated = true
-					break
-				}
-				referer = strings.Replace(referer, "*", ".*", -1)
-				matched, err := regexp.MatchString(referer, reqDomain)
-				if err != nil {
-					log.Errorln(logTag, ":", err)
-					telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				if matched {
-					validated = true
-					break
-				}
-			}
-
-			if !validated {
-				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				telemetry.WriteBackErrorWithTelemetry(req, w, "permission doesn't have required referers", http.StatusUnauthorized)
+			if !matcher.Referers(reqPermission.Referers).Match(reqDomain) {
+				deny(h, w, req, "referers", "permission doesn't have required referers")
 				return
 			}
 		}
 
-		h(w, req)
+		allow(h, w, req, "referers", "")
 	}
 }
@@ -12,8 +12,8 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
-	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/appbaseio/reactivesearch-api/util/iplookup"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 )
 
 const logTag = "[validate]"
@@ -23,10 +23,6 @@ func Sources() middleware.Middleware {
 	return sources
 }
 
-// ipv6 and ipv4 addresses when present then it would skip
-// the source validation and allow the access
-var allowAllAddresses = []string{"::/0", "0000:0000:0000:0000:0000:0000:0000:0000/0", "0.0.0.0/0"}
-
 func sources(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
@@ -54,31 +50,17 @@ func sources(h http.HandlerFunc) http.HandlerFunc {
 				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			allowedSources := reqPermission.Sources
-
-			var validated bool
-			for _, source := range allowedSources {
-				if util.Contains(allowAllAddresses, source) {
-					validated = true
-					break
-				}
-				_, ipNet, err := net.ParseCIDR(source)
-				if err != nil {
-					log.Errorln(logTag, ":", err)
-					telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				if ipNet.Contains(ip) {
-					validated = true
-					break
-				}
+			allowedSources, err := matcher.CIDRs(reqPermission.Sources)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+				return
 			}
 
-			if !validated {
+			if !allowedSources.Contains(ip) {
 				msg := fmt.Sprintf(`permission with username %s is failing IP sources validation. reqIP = %s`,
 					reqPermission.Username, reqIP)
-				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-				telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+				deny(h, w, req, "sources", msg)
 				return
 			}
 		} else {
@@ -91,7 +73,12 @@ func sources(h http.HandlerFunc) http.HandlerFunc {
 
 			if reqUser.Sources != nil {
 				// handle user credential
-				var allowedSources = *reqUser.Sources
+				allowedSources, err := matcher.CIDRs(*reqUser.Sources)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+					return
+				}
 
 				reqIP := iplookup.FromRequest(req)
 				if reqIP == "" {
@@ -102,35 +89,16 @@ func sources(h http.HandlerFunc) http.HandlerFunc {
 				}
 				ip := net.ParseIP(reqIP)
 
-				var validated bool
-				for _, source := range allowedSources {
-					if source == "0.0.0.0/0" {
-						validated = true
-						break
-					}
-					_, ipNet, err := net.ParseCIDR(source)
-					if err != nil {
-						log.Errorln(logTag, ":", err)
-						telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-					if ipNet.Contains(ip) {
-						validated = true
-						break
-					}
-				}
-
-				if !validated {
+				if !allowedSources.Contains(ip) {
 					msg := fmt.Sprintf(`username %s has an invalid IP. Detected IP = %s`,
 						reqUser.Username, reqIP)
-					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
-					telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+					deny(h, w, req, "sources", msg)
 					return
 				}
 			}
 
 		}
 
-		h(w, req)
+		allow(h, w, req, "sources", "")
 	}
 }
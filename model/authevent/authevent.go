@@ -0,0 +1,107 @@
+package authevent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+)
+
+type contextKey string
+
+// CtxKey is a key against which the auth events of a request will get stored in the context.
+const CtxKey = contextKey("auth-events")
+
+// Type defines the kind of an auth event.
+type Type string
+
+const (
+	// LoginFailed is recorded when invalid credentials are provided.
+	LoginFailed Type = "login_failed"
+	// LockedOut is recorded when a username or an IP gets locked out.
+	LockedOut Type = "locked_out"
+	// Rejected is recorded when a request is rejected because of an active lockout.
+	Rejected Type = "rejected"
+)
+
+// Event represents an auth event that happened while processing a request.
+type Event struct {
+	Type        Type       `json:"type"`
+	Username    string     `json:"username,omitempty"`
+	IP          string     `json:"ip,omitempty"`
+	Failures    int        `json:"failures,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+// Events holds the auth events recorded for a request, along with the
+// username of the credential the request got authenticated with.
+type Events struct {
+	mu       sync.Mutex
+	events   []Event
+	username string
+}
+
+// Add appends an event.
+func (e *Events) Add(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	e.events = append(e.events, event)
+}
+
+// List returns the recorded events.
+func (e *Events) List() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Event{}, e.events...)
+}
+
+// Username returns the username of the authenticated credential, if any.
+func (e *Events) Username() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.username
+}
+
+// NewContext returns a context with the passed events holder stored against the context key.
+func NewContext(ctx context.Context, events *Events) context.Context {
+	return context.WithValue(ctx, CtxKey, events)
+}
+
+// FromContext retrieves the events holder saved in the context.
+func FromContext(ctx context.Context) (*Events, error) {
+	ctxEvents := ctx.Value(CtxKey)
+	if ctxEvents == nil {
+		return nil, errors.NewNotFoundInContextError("Auth Events")
+	}
+	events, ok := ctxEvents.(*Events)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxEvents", "Auth Events")
+	}
+	return events, nil
+}
+
+// Record adds the event to the holder present in the context, if any.
+func Record(ctx context.Context, event Event) {
+	events, err := FromContext(ctx)
+	if err != nil {
+		return
+	}
+	events.Add(event)
+}
+
+// SetUsername stores the username of the authenticated credential in the holder
+// present in the context, if any.
+func SetUsername(ctx context.Context, username string) {
+	events, err := FromContext(ctx)
+	if err != nil {
+		return
+	}
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.username = username
+}
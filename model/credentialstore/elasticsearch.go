@@ -0,0 +1,383 @@
+package credentialstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	es7 "github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	settings = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
+	typeName = "_doc"
+)
+
+type elasticsearch struct {
+	userIndex, permissionIndex, roleIndex string
+	initOnce                              sync.Once
+	initErr                               error
+}
+
+// NewElasticsearch returns a store backed by the users, the permissions and the roles indices.
+func NewElasticsearch(userIndex, permissionIndex, roleIndex string) CredentialStore {
+	return &elasticsearch{userIndex: userIndex, permissionIndex: permissionIndex, roleIndex: roleIndex}
+}
+
+func (es *elasticsearch) Kind() string {
+	return Elasticsearch
+}
+
+func (es *elasticsearch) Init(ctx context.Context) error {
+	es.initOnce.Do(func() {
+		for _, indexName := range []string{es.userIndex, es.permissionIndex, es.roleIndex} {
+			if es.initErr = createIndex(ctx, indexName); es.initErr != nil {
+				return
+			}
+		}
+		if err := es.putPermissionMapping(ctx); err != nil {
+			log.Errorln(logTag, ": error while updating the mapping of", es.permissionIndex, ":", err)
+		}
+	})
+	return es.initErr
+}
+
+// permissionMapping disables the indexing of the document filters, the query
+// DSL would otherwise pollute the mapping with conflicting field types.
+var permissionMapping = map[string]interface{}{
+	"properties": map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":    "object",
+			"enabled": false,
+		},
+	},
+}
+
+func (es *elasticsearch) putPermissionMapping(ctx context.Context) error {
+	switch util.GetVersion() {
+	case 6:
+		return es.putPermissionMappingEs6(ctx)
+	}
+	_, err := util.GetClient7().PutMapping().
+		Index(es.permissionIndex).
+		BodyJson(permissionMapping).
+		Do(ctx)
+	return err
+}
+
+func createIndex(ctx context.Context, indexName string) error {
+	// Check if the meta index already exists
+	exists, err := util.GetClient7().IndexExists(indexName).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: error while checking if index already exists: %v", logTag, err)
+	}
+	if exists {
+		log.Println(logTag, ": index named", indexName, "already exists, skipping...")
+		return nil
+	}
+
+	replicas := util.GetReplicas()
+	body := fmt.Sprintf(settings, util.HiddenIndexSettings(), replicas)
+	// Meta index does not exists, create a new one
+	_, err = util.GetClient7().CreateIndex(indexName).
+		Body(body).
+		Do(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: error while creating index named %s: %v", logTag, indexName, err)
+	}
+
+	log.Println(logTag, ": successfully created index named", indexName)
+	return nil
+}
+
+func (es *elasticsearch) GetCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
+	var hits []hit
+	var err error
+	switch util.GetVersion() {
+	case 6:
+		hits, err = es.searchEs6(ctx, []string{es.userIndex, es.permissionIndex}, "username.keyword", username, 0)
+	default:
+		hits, err = es.searchEs7(ctx, []string{es.userIndex, es.permissionIndex}, es7.NewTermQuery("username.keyword", username), 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hits) > 1 {
+		return nil, fmt.Errorf(`more than one result for "username"="%s"`, username)
+	}
+
+	// there should be either 0 or 1 hit
+	for _, h := range hits {
+		switch h.index {
+		case es.userIndex:
+			var u user.User
+			if err := json.Unmarshal(h.source, &u); err != nil {
+				return nil, err
+			}
+			return &u, nil
+		case es.permissionIndex:
+			var p permission.Permission
+			if err := json.Unmarshal(h.source, &p); err != nil {
+				return nil, err
+			}
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf(`invalid username or password`)
+}
+
+func (es *elasticsearch) GetRawUser(ctx context.Context, username string) ([]byte, error) {
+	return es.get(ctx, es.userIndex, username)
+}
+
+func (es *elasticsearch) GetRawUsers(ctx context.Context) ([]json.RawMessage, error) {
+	switch util.GetVersion() {
+	case 6:
+		return sources(es.searchEs6(ctx, []string{es.userIndex}, "", "", 1000))
+	default:
+		return sources(es.searchEs7(ctx, []string{es.userIndex}, nil, 1000))
+	}
+}
+
+func (es *elasticsearch) PutUser(ctx context.Context, u user.User) error {
+	return es.put(ctx, es.userIndex, u.Username, u)
+}
+
+func (es *elasticsearch) PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	return es.patch(ctx, es.userIndex, username, patch)
+}
+
+func (es *elasticsearch) DeleteUser(ctx context.Context, username string) error {
+	return es.delete(ctx, es.userIndex, username)
+}
+
+func (es *elasticsearch) GetRawPermission(ctx context.Context, username string) ([]byte, error) {
+	return es.get(ctx, es.permissionIndex, username)
+}
+
+func (es *elasticsearch) GetRawPermissions(ctx context.Context, indices []string) ([]json.RawMessage, error) {
+	switch util.GetVersion() {
+	case 6:
+		return es.getRawPermissionsEs6(ctx, indices)
+	default:
+		query := util.GetIndexFilterQueryEs7(es7.NewBoolQuery(), indices...)
+		return sources(es.searchEs7(ctx, []string{es.permissionIndex}, query, 10000))
+	}
+}
+
+func (es *elasticsearch) GetRawOwnerPermissions(ctx context.Context, owner string) ([]json.RawMessage, error) {
+	switch util.GetVersion() {
+	case 6:
+		return sources(es.searchEs6(ctx, []string{es.permissionIndex}, "owner.keyword", owner, 1000))
+	default:
+		return sources(es.searchEs7(ctx, []string{es.permissionIndex}, es7.NewTermQuery("owner.keyword", owner), 10000))
+	}
+}
+
+func (es *elasticsearch) GetRawRolePermission(ctx context.Context, role string) ([]byte, error) {
+	var hits []hit
+	var err error
+	switch util.GetVersion() {
+	case 6:
+		hits, err = es.searchEs6(ctx, []string{es.permissionIndex}, "role.keyword", role, 1)
+	default:
+		hits, err = es.searchEs7(ctx, []string{es.permissionIndex}, es7.NewTermQuery("role.keyword", role), 1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, ErrNotFound
+	}
+	return hits[0].source, nil
+}
+
+func (es *elasticsearch) PutPermission(ctx context.Context, p permission.Permission) error {
+	return es.put(ctx, es.permissionIndex, p.Username, p)
+}
+
+func (es *elasticsearch) PatchPermission(ctx context.Context, username string, patch map[string]interface{}, replace ...string) ([]byte, error) {
+	if len(replace) > 0 {
+		return es.patchReplacing(ctx, es.permissionIndex, username, patch, replace)
+	}
+	return es.patch(ctx, es.permissionIndex, username, patch)
+}
+
+func (es *elasticsearch) DeletePermission(ctx context.Context, username string) error {
+	return es.delete(ctx, es.permissionIndex, username)
+}
+
+func (es *elasticsearch) GetRawRole(ctx context.Context, name string) ([]byte, error) {
+	return es.get(ctx, es.roleIndex, name)
+}
+
+func (es *elasticsearch) GetRawRoles(ctx context.Context) ([]json.RawMessage, error) {
+	switch util.GetVersion() {
+	case 6:
+		return sources(es.searchEs6(ctx, []string{es.roleIndex}, "", "", 1000))
+	default:
+		return sources(es.searchEs7(ctx, []string{es.roleIndex}, nil, 1000))
+	}
+}
+
+func (es *elasticsearch) PutRole(ctx context.Context, r role.Role) error {
+	return es.put(ctx, es.roleIndex, r.Name, r)
+}
+
+func (es *elasticsearch) DeleteRole(ctx context.Context, name string) error {
+	return es.delete(ctx, es.roleIndex, name)
+}
+
+// hit is a search hit independent of the elasticsearch version.
+type hit struct {
+	index  string
+	source json.RawMessage
+}
+
+func sources(hits []hit, err error) ([]json.RawMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	raw := []json.RawMessage{}
+	for _, h := range hits {
+		raw = append(raw, h.source)
+	}
+	return raw, nil
+}
+
+func (es *elasticsearch) searchEs7(ctx context.Context, indices []string, query es7.Query, size int) ([]hit, error) {
+	search := util.GetClient7().Search().
+		Index(indices...).
+		FetchSource(true)
+	if query != nil {
+		search = search.Query(query)
+	}
+	if size > 0 {
+		search = search.Size(size)
+	}
+	response, err := search.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []hit
+	for _, h := range response.Hits.Hits {
+		if h.Source != nil {
+			hits = append(hits, hit{index: h.Index, source: h.Source})
+		}
+	}
+	return hits, nil
+}
+
+func (es *elasticsearch) get(ctx context.Context, indexName, id string) ([]byte, error) {
+	switch util.GetVersion() {
+	case 6:
+		return es.getEs6(ctx, indexName, id)
+	}
+	response, err := util.GetClient7().Get().
+		Index(indexName).
+		Id(id).
+		FetchSource(true).
+		Do(ctx)
+	if err != nil {
+		if es7.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return response.Source.MarshalJSON()
+}
+
+func (es *elasticsearch) put(ctx context.Context, indexName, id string, doc interface{}) error {
+	_, err := util.GetClient7().Index().
+		Refresh("wait_for").
+		Index(indexName).
+		Id(id).
+		BodyJson(doc).
+		Do(ctx)
+	return err
+}
+
+func (es *elasticsearch) patch(ctx context.Context, indexName, id string, patch map[string]interface{}) ([]byte, error) {
+	switch util.GetVersion() {
+	case 6:
+		return es.patchEs6(ctx, indexName, id, patch)
+	}
+	response, err := util.GetClient7().Update().
+		Refresh("wait_for").
+		Index(indexName).
+		Id(id).
+		Doc(patch).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+// replacingPatchScript merges the patch into the document the same way as a
+// partial update, except for the fields in replace that are set as a whole.
+const replacingPatchScript = `
+void merge(Map doc, Map patch) {
+	for (def entry : patch.entrySet()) {
+		def current = doc.get(entry.getKey());
+		if (current instanceof Map && entry.getValue() instanceof Map) {
+			merge(current, entry.getValue());
+		} else {
+			doc.put(entry.getKey(), entry.getValue());
+		}
+	}
+}
+for (def entry : params.patch.entrySet()) {
+	if (params.replace.contains(entry.getKey())) {
+		ctx._source.put(entry.getKey(), entry.getValue());
+	} else {
+		merge(ctx._source, [entry.getKey(): entry.getValue()]);
+	}
+}`
+
+// patchReplacing applies the patch with a single scripted update, so that the
+// replaced fields are never observed as partially updated.
+func (es *elasticsearch) patchReplacing(ctx context.Context, indexName, id string, patch map[string]interface{}, replace []string) ([]byte, error) {
+	params := map[string]interface{}{
+		"patch":   patch,
+		"replace": replace,
+	}
+	switch util.GetVersion() {
+	case 6:
+		return es.patchReplacingEs6(ctx, indexName, id, params)
+	}
+	response, err := util.GetClient7().Update().
+		Refresh("wait_for").
+		Index(indexName).
+		Id(id).
+		Script(es7.NewScript(replacingPatchScript).Params(params)).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+func (es *elasticsearch) delete(ctx context.Context, indexName, id string) error {
+	_, err := util.GetClient7().Delete().
+		Refresh("wait_for").
+		Index(indexName).
+		Id(id).
+		Do(ctx)
+	return err
+}
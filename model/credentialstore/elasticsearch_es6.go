@@ -0,0 +1,102 @@
+package credentialstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+	es6 "gopkg.in/olivere/elastic.v6"
+)
+
+func (es *elasticsearch) searchEs6(ctx context.Context, indices []string, field, value string, size int) ([]hit, error) {
+	var query es6.Query
+	if field != "" {
+		query = es6.NewTermQuery(field, value)
+	}
+	return es.searchQueryEs6(ctx, indices, query, size)
+}
+
+func (es *elasticsearch) searchQueryEs6(ctx context.Context, indices []string, query es6.Query, size int) ([]hit, error) {
+	search := util.GetClient6().Search().
+		Index(indices...).
+		FetchSource(true)
+	if query != nil {
+		search = search.Query(query)
+	}
+	if size > 0 {
+		search = search.Size(size)
+	}
+	response, err := search.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []hit
+	for _, h := range response.Hits.Hits {
+		if h.Source != nil {
+			hits = append(hits, hit{index: h.Index, source: *h.Source})
+		}
+	}
+	return hits, nil
+}
+
+func (es *elasticsearch) getRawPermissionsEs6(ctx context.Context, indices []string) ([]json.RawMessage, error) {
+	query := util.GetIndexFilterQueryEs6(es6.NewBoolQuery(), indices...)
+	return sources(es.searchQueryEs6(ctx, []string{es.permissionIndex}, query, 10000))
+}
+
+func (es *elasticsearch) getEs6(ctx context.Context, indexName, id string) ([]byte, error) {
+	response, err := util.GetClient6().Get().
+		Index(indexName).
+		Type(typeName).
+		Id(id).
+		FetchSource(true).
+		Do(ctx)
+	if err != nil {
+		if es6.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return response.Source.MarshalJSON()
+}
+
+func (es *elasticsearch) patchEs6(ctx context.Context, indexName, id string, patch map[string]interface{}) ([]byte, error) {
+	response, err := util.GetClient6().Update().
+		Refresh("wait_for").
+		Index(indexName).
+		Type(typeName).
+		Id(id).
+		Doc(patch).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+func (es *elasticsearch) patchReplacingEs6(ctx context.Context, indexName, id string, params map[string]interface{}) ([]byte, error) {
+	response, err := util.GetClient6().Update().
+		Refresh("wait_for").
+		Index(indexName).
+		Type(typeName).
+		Id(id).
+		Script(es6.NewScript(replacingPatchScript).Params(params)).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response)
+}
+
+func (es *elasticsearch) putPermissionMappingEs6(ctx context.Context) error {
+	_, err := util.GetClient6().PutMapping().
+		Index(es.permissionIndex).
+		Type(typeName).
+		BodyJson(permissionMapping).
+		Do(ctx)
+	return err
+}
@@ -0,0 +1,323 @@
+package credentialstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	badger "github.com/outcaste-io/badger/v3"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+)
+
+const (
+	userKeyPrefix       = "user/"
+	permissionKeyPrefix = "permission/"
+	roleKeyPrefix       = "role/"
+)
+
+type embedded struct {
+	db *badger.DB
+}
+
+// NewEmbedded opens the store persisted in the directory at path. The
+// directory is locked while the store is open, so a single instance must
+// be shared within the process.
+func NewEmbedded(path string) (CredentialStore, error) {
+	opts := badger.DefaultOptions(path).
+		WithSyncWrites(true).
+		WithLoggingLevel(badger.WARNING)
+	return openEmbedded(opts)
+}
+
+// NewInMemory returns an embedded store that isn't persisted, it's meant to
+// be used in the tests.
+func NewInMemory() (CredentialStore, error) {
+	opts := badger.DefaultOptions("").
+		WithInMemory(true).
+		WithLoggingLevel(badger.WARNING)
+	return openEmbedded(opts)
+}
+
+func openEmbedded(opts badger.Options) (CredentialStore, error) {
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error while opening the embedded store: %v", logTag, err)
+	}
+	return &embedded{db}, nil
+}
+
+func (e *embedded) Kind() string {
+	return Embedded
+}
+
+func (e *embedded) Init(ctx context.Context) error {
+	return nil
+}
+
+func (e *embedded) GetCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
+	rawUser, userErr := e.get(userKeyPrefix + username)
+	rawPermission, permissionErr := e.get(permissionKeyPrefix + username)
+	for _, err := range []error{userErr, permissionErr} {
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+	}
+
+	switch {
+	case userErr == nil && permissionErr == nil:
+		return nil, fmt.Errorf(`more than one result for "username"="%s"`, username)
+	case userErr == nil:
+		var u user.User
+		if err := json.Unmarshal(rawUser, &u); err != nil {
+			return nil, err
+		}
+		return &u, nil
+	case permissionErr == nil:
+		var p permission.Permission
+		if err := json.Unmarshal(rawPermission, &p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+
+	return nil, fmt.Errorf(`invalid username or password`)
+}
+
+func (e *embedded) GetRawUser(ctx context.Context, username string) ([]byte, error) {
+	return e.get(userKeyPrefix + username)
+}
+
+func (e *embedded) GetRawUsers(ctx context.Context) ([]json.RawMessage, error) {
+	return e.list(userKeyPrefix, func(raw []byte) (bool, error) {
+		return true, nil
+	})
+}
+
+func (e *embedded) PutUser(ctx context.Context, u user.User) error {
+	return e.put(userKeyPrefix+u.Username, u)
+}
+
+func (e *embedded) PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	return e.patch(userKeyPrefix, username, patch)
+}
+
+func (e *embedded) DeleteUser(ctx context.Context, username string) error {
+	return e.delete(userKeyPrefix + username)
+}
+
+func (e *embedded) GetRawPermission(ctx context.Context, username string) ([]byte, error) {
+	return e.get(permissionKeyPrefix + username)
+}
+
+// storedPermission holds the fields of a permission the store filters on.
+type storedPermission struct {
+	Owner   string   `json:"owner"`
+	Role    string   `json:"role"`
+	Indices []string `json:"indices"`
+}
+
+func (e *embedded) listPermissions(match func(p storedPermission) bool) ([]json.RawMessage, error) {
+	return e.list(permissionKeyPrefix, func(raw []byte) (bool, error) {
+		var p storedPermission
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return false, err
+		}
+		return match(p), nil
+	})
+}
+
+func (e *embedded) GetRawPermissions(ctx context.Context, indices []string) ([]json.RawMessage, error) {
+	return e.listPermissions(func(p storedPermission) bool {
+		if len(indices) == 0 {
+			return true
+		}
+		patterns := matcher.Indices(p.Indices)
+		for _, index := range indices {
+			if patterns.Match(index) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (e *embedded) GetRawOwnerPermissions(ctx context.Context, owner string) ([]json.RawMessage, error) {
+	return e.listPermissions(func(p storedPermission) bool {
+		return p.Owner == owner
+	})
+}
+
+func (e *embedded) GetRawRolePermission(ctx context.Context, role string) ([]byte, error) {
+	permissions, err := e.listPermissions(func(p storedPermission) bool {
+		return role != "" && p.Role == role
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(permissions) == 0 {
+		return nil, ErrNotFound
+	}
+	return permissions[0], nil
+}
+
+func (e *embedded) PutPermission(ctx context.Context, p permission.Permission) error {
+	return e.put(permissionKeyPrefix+p.Username, p)
+}
+
+func (e *embedded) PatchPermission(ctx context.Context, username string, patch map[string]interface{}, replace ...string) ([]byte, error) {
+	return e.patch(permissionKeyPrefix, username, patch, replace...)
+}
+
+func (e *embedded) DeletePermission(ctx context.Context, username string) error {
+	return e.delete(permissionKeyPrefix + username)
+}
+
+func (e *embedded) GetRawRole(ctx context.Context, name string) ([]byte, error) {
+	return e.get(roleKeyPrefix + name)
+}
+
+func (e *embedded) GetRawRoles(ctx context.Context) ([]json.RawMessage, error) {
+	return e.list(roleKeyPrefix, func(raw []byte) (bool, error) {
+		return true, nil
+	})
+}
+
+func (e *embedded) PutRole(ctx context.Context, r role.Role) error {
+	return e.put(roleKeyPrefix+r.Name, r)
+}
+
+func (e *embedded) DeleteRole(ctx context.Context, name string) error {
+	return e.delete(roleKeyPrefix + name)
+}
+
+func (e *embedded) get(key string) ([]byte, error) {
+	var raw []byte
+	err := e.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		raw, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	return raw, err
+}
+
+func (e *embedded) list(prefix string, match func(raw []byte) (bool, error)) ([]json.RawMessage, error) {
+	result := []json.RawMessage{}
+	err := e.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			raw, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			ok, err := match(raw)
+			if err != nil {
+				return err
+			}
+			if ok {
+				result = append(result, raw)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (e *embedded) put(key string, doc interface{}) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return e.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(key), raw)
+	})
+}
+
+// patch merges the patch into the stored document the same way as a partial
+// update in elasticsearch, i.e. the objects are merged and the rest is replaced.
+// The fields in replace are set as a whole even if they are objects.
+func (e *embedded) patch(prefix, id string, patch map[string]interface{}, replace ...string) ([]byte, error) {
+	key := []byte(prefix + id)
+	err := e.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		var doc map[string]interface{}
+		err = item.Value(func(raw []byte) error {
+			return json.Unmarshal(raw, &doc)
+		})
+		if err != nil {
+			return err
+		}
+		for _, field := range replace {
+			if _, ok := patch[field]; ok {
+				delete(doc, field)
+			}
+		}
+		raw, err := json.Marshal(mergeDoc(doc, patch))
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, raw)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"_id":    id,
+		"result": "updated",
+	})
+}
+
+func (e *embedded) delete(key string) error {
+	return e.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(key)); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		return txn.Delete([]byte(key))
+	})
+}
+
+func mergeDoc(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	for key, value := range patch {
+		// the patch might hold structs, normalize them to the json representation
+		if raw, err := json.Marshal(value); err == nil {
+			var normalized interface{}
+			if json.Unmarshal(raw, &normalized) == nil {
+				value = normalized
+			}
+		}
+		patchObject, ok := value.(map[string]interface{})
+		if docObject, isObject := doc[key].(map[string]interface{}); ok && isObject {
+			doc[key] = mergeDoc(docObject, patchObject)
+			continue
+		}
+		doc[key] = value
+	}
+	return doc
+}
@@ -0,0 +1,144 @@
+package credentialstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEmbeddedStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("should store and authenticate the users", t, func() {
+		So(store.PutUser(ctx, user.User{Username: "admin", Password: "secret"}), ShouldBeNil)
+
+		obj, err := store.GetCredential(ctx, "admin")
+		So(err, ShouldBeNil)
+		u, ok := obj.(*user.User)
+		So(ok, ShouldBeTrue)
+		So(u.Password, ShouldEqual, "secret")
+
+		users, err := store.GetRawUsers(ctx)
+		So(err, ShouldBeNil)
+		So(users, ShouldHaveLength, 1)
+	})
+
+	Convey("should store and filter the permissions", t, func() {
+		So(store.PutPermission(ctx, permission.Permission{
+			Username: "p1",
+			Owner:    "admin",
+			Role:     "reader",
+			Indices:  []string{"books", "movies"},
+		}), ShouldBeNil)
+		So(store.PutPermission(ctx, permission.Permission{
+			Username: "p2",
+			Owner:    "someone",
+			Indices:  []string{"music"},
+		}), ShouldBeNil)
+
+		obj, err := store.GetCredential(ctx, "p1")
+		So(err, ShouldBeNil)
+		_, ok := obj.(*permission.Permission)
+		So(ok, ShouldBeTrue)
+
+		all, err := store.GetRawPermissions(ctx, nil)
+		So(err, ShouldBeNil)
+		So(all, ShouldHaveLength, 2)
+
+		music, err := store.GetRawPermissions(ctx, []string{"music"})
+		So(err, ShouldBeNil)
+		So(music, ShouldHaveLength, 1)
+
+		So(store.PutPermission(ctx, permission.Permission{
+			Username: "p3",
+			Owner:    "someone",
+			Indices:  []string{"book*"},
+		}), ShouldBeNil)
+		books, err := store.GetRawPermissions(ctx, []string{"books"})
+		So(err, ShouldBeNil)
+		So(books, ShouldHaveLength, 2)
+		So(store.DeletePermission(ctx, "p3"), ShouldBeNil)
+
+		owned, err := store.GetRawOwnerPermissions(ctx, "admin")
+		So(err, ShouldBeNil)
+		So(owned, ShouldHaveLength, 1)
+
+		raw, err := store.GetRawRolePermission(ctx, "reader")
+		So(err, ShouldBeNil)
+		var p permission.Permission
+		So(json.Unmarshal(raw, &p), ShouldBeNil)
+		So(p.Username, ShouldEqual, "p1")
+
+		_, err = store.GetRawRolePermission(ctx, "writer")
+		So(err, ShouldEqual, ErrNotFound)
+	})
+
+	Convey("should merge the patch into the stored document", t, func() {
+		_, err := store.PatchPermission(ctx, "p1", map[string]interface{}{
+			"description": "updated",
+			"limits":      map[string]interface{}{"ip_limit": 10},
+		})
+		So(err, ShouldBeNil)
+
+		raw, err := store.GetRawPermission(ctx, "p1")
+		So(err, ShouldBeNil)
+		var p map[string]interface{}
+		So(json.Unmarshal(raw, &p), ShouldBeNil)
+		So(p["description"], ShouldEqual, "updated")
+		So(p["owner"], ShouldEqual, "admin")
+
+		_, err = store.PatchPermission(ctx, "p1", map[string]interface{}{
+			"filter": map[string]interface{}{"term": map[string]interface{}{"tenant": "a"}},
+		})
+		So(err, ShouldBeNil)
+		_, err = store.PatchPermission(ctx, "p1", map[string]interface{}{
+			"filter":      map[string]interface{}{"range": map[string]interface{}{"year": "2020"}},
+			"limits":      map[string]interface{}{"docs_limit": 5},
+			"description": "replaced",
+		}, "filter")
+		So(err, ShouldBeNil)
+		raw, err = store.GetRawPermission(ctx, "p1")
+		So(err, ShouldBeNil)
+		p = nil
+		So(json.Unmarshal(raw, &p), ShouldBeNil)
+		So(p["filter"], ShouldResemble, map[string]interface{}{"range": map[string]interface{}{"year": "2020"}})
+		So(p["limits"].(map[string]interface{})["ip_limit"], ShouldEqual, 10)
+		So(p["description"], ShouldEqual, "replaced")
+
+		_, err = store.PatchUser(ctx, "missing", map[string]interface{}{"email": "a@b.c"})
+		So(err, ShouldEqual, ErrNotFound)
+	})
+
+	Convey("should delete the credentials", t, func() {
+		So(store.DeleteUser(ctx, "admin"), ShouldBeNil)
+		_, err := store.GetRawUser(ctx, "admin")
+		So(err, ShouldEqual, ErrNotFound)
+		So(store.DeleteUser(ctx, "admin"), ShouldEqual, ErrNotFound)
+
+		_, err = store.GetCredential(ctx, "admin")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMergeDoc(t *testing.T) {
+	Convey("should merge the nested objects and replace the rest", t, func() {
+		doc := map[string]interface{}{
+			"limits":  map[string]interface{}{"ip_limit": 1.0, "docs_limit": 2.0},
+			"indices": []interface{}{"a", "b"},
+		}
+		merged := mergeDoc(doc, map[string]interface{}{
+			"limits":  map[string]interface{}{"ip_limit": 5},
+			"indices": []string{"c"},
+		})
+		So(merged["limits"], ShouldResemble, map[string]interface{}{"ip_limit": 5.0, "docs_limit": 2.0})
+		So(merged["indices"], ShouldResemble, []interface{}{"c"})
+	})
+}
@@ -0,0 +1,146 @@
+package credentialstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+const (
+	logTag                     = "[credentialstore]"
+	envCredentialStore         = "CREDENTIAL_STORE"
+	envCredentialStorePath     = "CREDENTIAL_STORE_PATH"
+	defaultCredentialStorePath = "data/credentials"
+	envUsersEsIndex            = "USERS_ES_INDEX"
+	defaultUsersEsIndex        = ".users"
+	envPermissionsEsIndex      = "PERMISSIONS_ES_INDEX"
+	defaultPermissionsEsIndex  = ".permissions"
+	envRolesEsIndex            = "ROLES_ES_INDEX"
+	defaultRolesEsIndex        = ".roles"
+)
+
+const (
+	// Elasticsearch stores the credentials in the meta indices of the cluster.
+	Elasticsearch = "elasticsearch"
+	// Embedded stores the credentials in a file local to the node, it doesn't
+	// depend on the availability of the cluster.
+	Embedded = "embedded"
+)
+
+// ErrNotFound is returned when the requested user, permission or role doesn't exist.
+var ErrNotFound = errors.New("credential not found")
+
+// CredentialStore persists the users, the permissions and the roles used to authenticate the requests.
+type CredentialStore interface {
+	// Kind returns the type of the store, i.e. elasticsearch or embedded.
+	Kind() string
+	// Init prepares the store for use, it's safe to call more than once.
+	Init(ctx context.Context) error
+	// GetCredential returns the user or the permission with the username.
+	GetCredential(ctx context.Context, username string) (credential.AuthCredential, error)
+
+	GetRawUser(ctx context.Context, username string) ([]byte, error)
+	GetRawUsers(ctx context.Context) ([]json.RawMessage, error)
+	PutUser(ctx context.Context, u user.User) error
+	PatchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error)
+	DeleteUser(ctx context.Context, username string) error
+
+	GetRawPermission(ctx context.Context, username string) ([]byte, error)
+	// GetRawPermissions returns the permissions that have access to any of the
+	// indices, all the permissions are returned if no index is specified.
+	GetRawPermissions(ctx context.Context, indices []string) ([]json.RawMessage, error)
+	GetRawOwnerPermissions(ctx context.Context, owner string) ([]json.RawMessage, error)
+	GetRawRolePermission(ctx context.Context, role string) ([]byte, error)
+	PutPermission(ctx context.Context, p permission.Permission) error
+	// PatchPermission merges the patch into the permission with a single update,
+	// the fields in replace are set as a whole instead of being merged.
+	PatchPermission(ctx context.Context, username string, patch map[string]interface{}, replace ...string) ([]byte, error)
+	DeletePermission(ctx context.Context, username string) error
+
+	GetRawRole(ctx context.Context, name string) ([]byte, error)
+	GetRawRoles(ctx context.Context) ([]json.RawMessage, error)
+	PutRole(ctx context.Context, r role.Role) error
+	DeleteRole(ctx context.Context, name string) error
+}
+
+var (
+	singleton CredentialStore
+	initErr   error
+	once      sync.Once
+)
+
+// Instance returns the credential store configured with the CREDENTIAL_STORE env,
+// it's shared by the plugins since the embedded store can be opened only once.
+func Instance() (CredentialStore, error) {
+	once.Do(func() {
+		if singleton != nil {
+			return
+		}
+		singleton, initErr = newFromEnv()
+	})
+	return singleton, initErr
+}
+
+// SetInstance overrides the credential store returned by Instance, it must be
+// called before the plugins are initialized.
+func SetInstance(store CredentialStore) {
+	once.Do(func() {})
+	singleton, initErr = store, nil
+}
+
+// IsEmbedded returns true if the credentials are not stored in elasticsearch.
+func IsEmbedded() bool {
+	store, err := Instance()
+	return err == nil && store.Kind() == Embedded
+}
+
+func newFromEnv() (CredentialStore, error) {
+	switch kind := os.Getenv(envCredentialStore); kind {
+	case "", Elasticsearch:
+		return NewElasticsearch(UserIndex(), PermissionIndex(), RoleIndex()), nil
+	case Embedded:
+		path := os.Getenv(envCredentialStorePath)
+		if path == "" {
+			path = defaultCredentialStorePath
+		}
+		log.Println(logTag, ": using the embedded credential store at", path)
+		return NewEmbedded(path)
+	default:
+		return nil, fmt.Errorf("%s: invalid value %q for %s, must be one of %s or %s",
+			logTag, kind, envCredentialStore, Elasticsearch, Embedded)
+	}
+}
+
+// UserIndex returns the name of the index that stores the users.
+func UserIndex() string {
+	if index := os.Getenv(envUsersEsIndex); index != "" {
+		return index
+	}
+	return defaultUsersEsIndex
+}
+
+// PermissionIndex returns the name of the index that stores the permissions.
+func PermissionIndex() string {
+	if index := os.Getenv(envPermissionsEsIndex); index != "" {
+		return index
+	}
+	return defaultPermissionsEsIndex
+}
+
+// RoleIndex returns the name of the index that stores the roles.
+func RoleIndex() string {
+	if index := os.Getenv(envRolesEsIndex); index != "" {
+		return index
+	}
+	return defaultRolesEsIndex
+}
@@ -0,0 +1,134 @@
+package explain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/appbaseio/reactivesearch-api/errors"
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+)
+
+type contextKey string
+
+// CtxKey is a key against which the explain report of a dry-run request is stored in the context.
+const CtxKey = contextKey("explain-report")
+
+// Check is the outcome of a single authorization check.
+type Check struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report collects the values derived by the classification middleware and
+// the outcome of the validation middleware of a dry-run request.
+type Report struct {
+	mu         sync.Mutex
+	Route      string   `json:"route,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	ACL        string   `json:"acl,omitempty"`
+	Op         string   `json:"op,omitempty"`
+	Indices    []string `json:"indices"`
+	Checks     []Check  `json:"checks"`
+	// Allowed is true if the request reached the handler without any failed check
+	Allowed bool `json:"allowed"`
+}
+
+// NewReport returns an empty report.
+func NewReport() *Report {
+	return &Report{Indices: []string{}, Checks: []Check{}}
+}
+
+// Add appends a check.
+func (r *Report) Add(check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Checks = append(r.Checks, check)
+}
+
+// Failed returns true if any of the checks failed.
+func (r *Report) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive copies the values derived so far by the classification middleware.
+func (r *Report) Derive(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, err := category.FromContext(ctx); err == nil && c != nil {
+		r.Category = c.String()
+	}
+	if a, err := acl.FromContext(ctx); err == nil && a != nil {
+		r.ACL = a.String()
+	}
+	if o, err := op.FromContext(ctx); err == nil && o != nil {
+		r.Op = o.String()
+	}
+	if indices, err := index.FromContext(ctx); err == nil && indices != nil {
+		r.Indices = indices
+	}
+	if c, err := credential.FromContext(ctx); err == nil {
+		switch c {
+		case credential.User:
+			r.Credential = "user"
+		case credential.Permission:
+			r.Credential = "permission"
+		}
+	}
+}
+
+// Complete marks the request as having reached the handler of the route.
+func (r *Report) Complete(ctx context.Context) {
+	r.Derive(ctx)
+	failed := r.Failed()
+	r.mu.Lock()
+	r.Allowed = !failed
+	r.mu.Unlock()
+}
+
+// NewContext returns a context with the passed report stored against the context key.
+func NewContext(ctx context.Context, report *Report) context.Context {
+	return context.WithValue(ctx, CtxKey, report)
+}
+
+// FromContext retrieves the report saved in the context.
+func FromContext(ctx context.Context) (*Report, error) {
+	ctxReport := ctx.Value(CtxKey)
+	if ctxReport == nil {
+		return nil, errors.NewNotFoundInContextError("Explain Report")
+	}
+	report, ok := ctxReport.(*Report)
+	if !ok {
+		return nil, errors.NewInvalidCastError("ctxReport", "Explain Report")
+	}
+	return report, nil
+}
+
+// IsDryRun returns true if the request is being explained, the handler of
+// the route must not be executed for such requests.
+func IsDryRun(ctx context.Context) bool {
+	_, err := FromContext(ctx)
+	return err == nil
+}
+
+// Record adds the check to the report present in the context, if any.
+func Record(ctx context.Context, name string, passed bool, message string) {
+	report, err := FromContext(ctx)
+	if err != nil {
+		return
+	}
+	report.Derive(ctx)
+	report.Add(Check{Name: name, Passed: passed, Message: message})
+}
@@ -0,0 +1,39 @@
+package explain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/index"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReport(t *testing.T) {
+	Convey("should ignore the requests that aren't explained", t, func() {
+		ctx := context.Background()
+		So(IsDryRun(ctx), ShouldBeFalse)
+		Record(ctx, "category", false, "denied")
+	})
+	Convey("should record the checks along with the derived values", t, func() {
+		report := NewReport()
+		ctx := NewContext(context.Background(), report)
+		So(IsDryRun(ctx), ShouldBeTrue)
+
+		docs := category.Docs
+		ctx = category.NewContext(ctx, &docs)
+		ctx = index.NewContext(ctx, []string{"movies"})
+		ctx = credential.NewContext(ctx, credential.Permission)
+		Record(ctx, "category", true, "")
+		Record(ctx, "indices", false, "credentials cannot access [movies] index/indices")
+		report.Complete(ctx)
+
+		So(report.Category, ShouldEqual, "docs")
+		So(report.Indices, ShouldResemble, []string{"movies"})
+		So(report.Credential, ShouldEqual, "permission")
+		So(report.Checks, ShouldHaveLength, 2)
+		So(report.Failed(), ShouldBeTrue)
+		So(report.Allowed, ShouldBeFalse)
+	})
+}
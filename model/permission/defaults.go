@@ -5,6 +5,9 @@ import (
 	"github.com/appbaseio/reactivesearch-api/model/op"
 )
 
+// minSigningSecretLength is the minimum length accepted for a signing key secret.
+const minSigningSecretLength = 16
+
 var (
 	defaultCategories = []category.Category{
 		category.Docs,
@@ -0,0 +1,140 @@
+package permission
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// filterQueryKey is the key of a document filter written in the query DSL,
+// a filter without it is a map of the fields to the values that must match.
+const filterQueryKey = "query"
+
+// SetFilter sets the document filter of the permission, only the documents
+// matching the filter can be searched with the permission. The string values
+// can refer to the {{user}}, {{owner}} and {{role}} variables.
+func SetFilter(filter map[string]interface{}) Options {
+	return func(p *Permission) error {
+		if err := validateFilter(filter); err != nil {
+			return err
+		}
+		p.Filter = filter
+		return nil
+	}
+}
+
+func validateFilter(filter map[string]interface{}) error {
+	if query, ok := filter[filterQueryKey]; ok {
+		if len(filter) > 1 {
+			return fmt.Errorf(`filter with a "%s" can't have any other key`, filterQueryKey)
+		}
+		clause, ok := query.(map[string]interface{})
+		if !ok || len(clause) != 1 {
+			return fmt.Errorf(`filter "%s" must be an object with a single query clause`, filterQueryKey)
+		}
+		return nil
+	}
+	for field, value := range filter {
+		if !isTermValue(value) {
+			return fmt.Errorf(`filter value for "%s" must be a string, number, boolean or an array of them`, field)
+		}
+	}
+	return nil
+}
+
+func isTermValue(value interface{}) bool {
+	switch v := value.(type) {
+	case string, float64, bool:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			switch item.(type) {
+			case string, float64, bool:
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// WithFilterUser returns a copy of the permission that resolves the {{user}}
+// variable of the filter to the given user instead of the permission username.
+func (p *Permission) WithFilterUser(user string) *Permission {
+	withUser := *p
+	withUser.FilterUser = user
+	return &withUser
+}
+
+func (p *Permission) filterVariables() *strings.Replacer {
+	user := p.FilterUser
+	if user == "" {
+		user = p.Username
+	}
+	return strings.NewReplacer(
+		"{{user}}", user,
+		"{{owner}}", p.Owner,
+		"{{role}}", p.Role,
+	)
+}
+
+// renderFilter substitutes the variables in all the string values of the filter.
+func renderFilter(value interface{}, variables *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case string:
+		return variables.Replace(v)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered[key] = renderFilter(item, variables)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered[i] = renderFilter(item, variables)
+		}
+		return rendered
+	}
+	return value
+}
+
+// termClauses converts a map of the fields to the values into sorted term clauses.
+func termClauses(terms map[string]interface{}) []interface{} {
+	var fields []string
+	for field := range terms {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var clauses []interface{}
+	for _, field := range fields {
+		value := terms[field]
+		if values, ok := value.([]interface{}); ok {
+			clauses = append(clauses, map[string]interface{}{
+				"terms": map[string]interface{}{field: values},
+			})
+		} else {
+			clauses = append(clauses, map[string]interface{}{
+				"term": map[string]interface{}{field: value},
+			})
+		}
+	}
+	return clauses
+}
+
+// GetFilterClauses returns the filter clauses that must be applied to the
+// search requests made with the permission, i.e. the document filter of the
+// permission along with the filter of the scoped token.
+func (p *Permission) GetFilterClauses() []interface{} {
+	var clauses []interface{}
+	if len(p.Filter) > 0 {
+		filter := renderFilter(p.Filter, p.filterVariables()).(map[string]interface{})
+		if query, ok := filter[filterQueryKey]; ok {
+			clauses = append(clauses, query)
+		} else {
+			clauses = append(clauses, termClauses(filter)...)
+		}
+	}
+	return append(clauses, termClauses(p.TokenFilter)...)
+}
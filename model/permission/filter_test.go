@@ -0,0 +1,50 @@
+package permission
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilter(t *testing.T) {
+	Convey("should validate the filter", t, func() {
+		So(validateFilter(map[string]interface{}{"tenant": "{{user}}"}), ShouldBeNil)
+		So(validateFilter(map[string]interface{}{"tenant": map[string]interface{}{"a": 1}}), ShouldNotBeNil)
+		So(validateFilter(map[string]interface{}{
+			"query": map[string]interface{}{"range": map[string]interface{}{"year": map[string]interface{}{"gte": 2000}}},
+		}), ShouldBeNil)
+		So(validateFilter(map[string]interface{}{"query": "tenant:a"}), ShouldNotBeNil)
+		So(validateFilter(map[string]interface{}{"query": map[string]interface{}{}, "tenant": "a"}), ShouldNotBeNil)
+	})
+	Convey("should render the term map with the variables", t, func() {
+		p := Permission{
+			Username: "perm",
+			Owner:    "acme",
+			Filter: map[string]interface{}{
+				"tenant": "{{owner}}",
+				"users":  []interface{}{"{{user}}", "shared"},
+			},
+		}
+		So(p.GetFilterClauses(), ShouldResemble, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"tenant": "acme"}},
+			map[string]interface{}{"terms": map[string]interface{}{"users": []interface{}{"perm", "shared"}}},
+		})
+		So(p.WithFilterUser("alice").GetFilterClauses()[1], ShouldResemble,
+			map[string]interface{}{"terms": map[string]interface{}{"users": []interface{}{"alice", "shared"}}})
+		// the stored filter must not be modified by rendering
+		So(p.Filter["tenant"], ShouldEqual, "{{owner}}")
+	})
+	Convey("should combine the query DSL with the token filter", t, func() {
+		p := Permission{
+			Username: "perm",
+			Filter: map[string]interface{}{
+				"query": map[string]interface{}{"match": map[string]interface{}{"owner": "{{user}}"}},
+			},
+			TokenFilter: map[string]interface{}{"status": "published"},
+		}
+		So(p.GetFilterClauses(), ShouldResemble, []interface{}{
+			map[string]interface{}{"match": map[string]interface{}{"owner": "perm"}},
+			map[string]interface{}{"term": map[string]interface{}{"status": "published"}},
+		})
+	})
+}
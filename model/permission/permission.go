@@ -5,17 +5,15 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/appbaseio/reactivesearch-api/errors"
 	"github.com/appbaseio/reactivesearch-api/model/acl"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 	"github.com/google/uuid"
 )
 
@@ -35,31 +33,69 @@ type ReactiveSearchConfig struct {
 	MaxSize            *int  `json:"maxSize,omitempty"`
 	MaxAggregationSize *int  `json:"maxAggregationSize,omitempty"`
 	DisbaleQueryDSL    *bool `json:"disableQueryDSL,omitempty"`
+	// AllowedQueryTypes restricts the types of the queries, e.g. "search" or "term"
+	AllowedQueryTypes []string `json:"allowedQueryTypes,omitempty"`
+	// MaxQueries is the maximum number of queries in a request
+	MaxQueries *int `json:"maxQueries,omitempty"`
+	// MaxResultWindow is the maximum value of from + size of a query
+	MaxResultWindow     *int  `json:"maxResultWindow,omitempty"`
+	DisableCustomQuery  *bool `json:"disableCustomQuery,omitempty"`
+	DisableDefaultQuery *bool `json:"disableDefaultQuery,omitempty"`
+	DisableScript       *bool `json:"disableScript,omitempty"`
+	DisableEndpoint     *bool `json:"disableEndpoint,omitempty"`
+	// AllowedEndpointHosts restricts the hosts the endpoint of a query can point to
+	AllowedEndpointHosts []string `json:"allowedEndpointHosts,omitempty"`
+	// AllowedDataFields restricts the fields the queries can be run on
+	AllowedDataFields []string `json:"allowedDataFields,omitempty"`
+}
+
+// SigningKey represents a shared secret that can be used to sign requests
+// with HMAC instead of sending the basic auth credentials.
+type SigningKey struct {
+	KeyID     string `json:"key_id"`
+	Secret    string `json:"secret"`
+	CreatedAt string `json:"created_at,omitempty"`
 }
 
 // Permission defines a permission type.
 type Permission struct {
-	Username             string                `json:"username"`
-	Password             string                `json:"password"`
-	Owner                string                `json:"owner"`
-	Creator              string                `json:"creator"`
-	Role                 string                `json:"role"`
-	Categories           []category.Category   `json:"categories"`
-	ACLs                 []acl.ACL             `json:"acls"`
-	Ops                  []op.Operation        `json:"ops"`
-	Indices              []string              `json:"indices"`
-	Sources              []string              `json:"sources"`
-	SourcesXffValue      *int                  `json:"sources_xff_value"`
-	Referers             []string              `json:"referers"`
-	CreatedAt            string                `json:"created_at"`
-	TTL                  time.Duration         `json:"ttl"`
-	Limits               *Limits               `json:"limits"`
-	Description          string                `json:"description"`
-	Includes             []string              `json:"include_fields"`
-	Excludes             []string              `json:"exclude_fields"`
-	Expired              bool                  `json:"expired"`
-	ReactiveSearchConfig *ReactiveSearchConfig `json:"reactivesearchConfig,omitempty"`
-	UpdatedAt            string                `json:"updated_at"`
+	Username             string                 `json:"username"`
+	Password             string                 `json:"password"`
+	Owner                string                 `json:"owner"`
+	Creator              string                 `json:"creator"`
+	Role                 string                 `json:"role"`
+	Categories           []category.Category    `json:"categories"`
+	ACLs                 []acl.ACL              `json:"acls"`
+	Ops                  []op.Operation         `json:"ops"`
+	Indices              []string               `json:"indices"`
+	Sources              []string               `json:"sources"`
+	SourcesXffValue      *int                   `json:"sources_xff_value"`
+	Referers             []string               `json:"referers"`
+	CreatedAt            string                 `json:"created_at"`
+	TTL                  time.Duration          `json:"ttl"`
+	Limits               *Limits                `json:"limits"`
+	Description          string                 `json:"description"`
+	Includes             []string               `json:"include_fields"`
+	Excludes             []string               `json:"exclude_fields"`
+	Expired              bool                   `json:"expired"`
+	ReactiveSearchConfig *ReactiveSearchConfig  `json:"reactivesearchConfig,omitempty"`
+	SigningKeys          []SigningKey           `json:"signing_keys,omitempty"`
+	Filter               map[string]interface{} `json:"filter,omitempty"`
+	Schedule             *Schedule              `json:"schedule,omitempty"`
+	WriteIncludes        []string               `json:"write_include_fields,omitempty"`
+	WriteExcludes        []string               `json:"write_exclude_fields,omitempty"`
+	WriteProtection      string                 `json:"write_protection,omitempty"`
+	Quota                *Quota                 `json:"quota,omitempty"`
+	RequestLimits        *RequestLimits         `json:"request_limits,omitempty"`
+	// Roles are the names of the roles the permission inherits the grants of
+	Roles     []string `json:"roles,omitempty"`
+	UpdatedAt string   `json:"updated_at"`
+	// TokenFilter holds the filter terms of the scoped token used for the request
+	TokenFilter map[string]interface{} `json:"-"`
+	// Scoped is true if the request is authenticated with a scoped token
+	Scoped bool `json:"-"`
+	// FilterUser is the user the {{user}} variable of the filter resolves to
+	FilterUser string `json:"-"`
 }
 
 // Limits defines the rate limits for each category.
@@ -88,6 +124,9 @@ type Limits struct {
 	StoredQueryLimit      int64 `json:"storedquery_limit"`
 	PipelinesLimit        int64 `json:"pipelines_limit"`
 	SyncLimit             int64 `json:"sync_limit"`
+	// Burst turns the category limits into token buckets of the size, i.e. the
+	// requests can burst up to it as long as the average stays under the limit
+	Burst int64 `json:"burst,omitempty"`
 }
 
 // Options is a function type used to define a permission's properties.
@@ -157,12 +196,7 @@ func SetIndices(indices []string) Options {
 		if indices == nil {
 			return errors.ErrNilIndices
 		}
-		for _, pattern := range indices {
-			pattern = strings.Replace(pattern, "*", ".*", -1)
-			if _, err := regexp.Compile(pattern); err != nil {
-				return err
-			}
-		}
+		matcher.Indices(indices)
 		p.Indices = indices
 		return nil
 	}
@@ -214,7 +248,8 @@ func validateSources(sources []string) error {
 			return fmt.Errorf(`source "%s" is not a valid CIDR notation: %v`, source, err)
 		}
 	}
-	return nil
+	_, err := matcher.CIDRs(sources)
+	return err
 }
 
 // SetReferers sets the referers from which the permission can make request from.
@@ -231,13 +266,15 @@ func SetReferers(referers []string) Options {
 	}
 }
 
+// validateReferers checks the referer patterns, a referer is a glob where "*"
+// matches any characters and that matches the URLs it's a prefix of.
 func validateReferers(referers []string) error {
 	for _, referer := range referers {
-		referer = strings.Replace(referer, "*", ".*", -1)
-		if _, err := regexp.Compile(referer); err != nil {
-			return fmt.Errorf(`invalid referer regexp "%s" encountered: %v`, referer, err)
+		if strings.TrimSpace(referer) == "" {
+			return fmt.Errorf("referer pattern can't be empty")
 		}
 	}
+	matcher.Referers(referers)
 	return nil
 }
 
@@ -284,11 +321,38 @@ func SetLimits(limits *Limits, isAdmin bool) Options {
 			StoredQueryLimit:      getNormalizedLimit(limits.StoredQueryLimit, defaults.StoredQueryLimit),
 			SyncLimit:             getNormalizedLimit(limits.SyncLimit, defaults.SyncLimit),
 			PipelinesLimit:        getNormalizedLimit(limits.PipelinesLimit, defaults.PipelinesLimit),
+			Burst:                 limits.Burst,
+		}
+		return nil
+	}
+}
+
+// SetRoles sets the roles the permission inherits the grants of, exists
+// reports whether a role is defined.
+func SetRoles(roles []string, exists func(name string) (bool, error)) Options {
+	return func(p *Permission) error {
+		if err := ValidateRoles(roles, exists); err != nil {
+			return err
 		}
+		p.Roles = roles
 		return nil
 	}
 }
 
+// ValidateRoles checks that all the roles are defined.
+func ValidateRoles(roles []string, exists func(name string) (bool, error)) error {
+	for _, name := range roles {
+		ok, err := exists(name)
+		if err != nil {
+			return fmt.Errorf(`unable to look up the role "%s": %v`, name, err)
+		}
+		if !ok {
+			return fmt.Errorf(`role "%s" doesn't exist`, name)
+		}
+	}
+	return nil
+}
+
 // SetDescription sets the permission description.
 func SetDescription(description string) Options {
 	return func(p *Permission) error {
@@ -300,11 +364,63 @@ func SetDescription(description string) Options {
 // SetDescription sets the permission reactivesearchConfig.
 func SetReactivesearchConfig(config ReactiveSearchConfig) Options {
 	return func(p *Permission) error {
+		if err := config.Validate(); err != nil {
+			return err
+		}
 		p.ReactiveSearchConfig = &config
 		return nil
 	}
 }
 
+// SetSigningKeys sets the secrets that can be used to sign requests with HMAC.
+func SetSigningKeys(keys []SigningKey) Options {
+	return func(p *Permission) error {
+		if err := validateSigningKeys(keys); err != nil {
+			return err
+		}
+		p.SigningKeys = withSigningKeyDefaults(keys)
+		return nil
+	}
+}
+
+func validateSigningKeys(keys []SigningKey) error {
+	keyIDs := make(map[string]bool)
+	for _, key := range keys {
+		if key.KeyID == "" {
+			return fmt.Errorf("signing key id cannot be an empty string")
+		}
+		if len(key.Secret) < minSigningSecretLength {
+			return fmt.Errorf(`signing key "%s" must have a secret of at least %d characters`, key.KeyID, minSigningSecretLength)
+		}
+		if keyIDs[key.KeyID] {
+			return fmt.Errorf(`duplicate signing key id "%s" encountered`, key.KeyID)
+		}
+		keyIDs[key.KeyID] = true
+	}
+	return nil
+}
+
+func withSigningKeyDefaults(keys []SigningKey) []SigningKey {
+	result := make([]SigningKey, len(keys))
+	for i, key := range keys {
+		if key.CreatedAt == "" {
+			key.CreatedAt = time.Now().Format(time.RFC3339)
+		}
+		result[i] = key
+	}
+	return result
+}
+
+// GetSigningSecret returns the secret for the given signing key id.
+func (p *Permission) GetSigningSecret(keyID string) (string, bool) {
+	for _, key := range p.SigningKeys {
+		if key.KeyID == keyID {
+			return key.Secret, true
+		}
+	}
+	return "", false
+}
+
 // SetTTL sets the permission's time-to-live.
 func SetTTL(duration time.Duration) Options {
 	return func(p *Permission) error {
@@ -470,19 +586,25 @@ func (p *Permission) CanDo(op op.Operation) bool {
 	return false
 }
 
+// CompileMatchers compiles the referers, the sources and the index patterns of
+// the permission ahead of its requests.
+func (p *Permission) CompileMatchers() error {
+	matcher.Referers(p.Referers)
+	matcher.Indices(p.Indices)
+	_, err := matcher.CIDRs(p.Sources)
+	return err
+}
+
+// ForgetMatchers drops the compiled patterns of the permission, e.g. once it's patched.
+func (p *Permission) ForgetMatchers() {
+	matcher.Forget(p.Referers)
+	matcher.Forget(p.Indices)
+	matcher.Forget(p.Sources)
+}
+
 // CanAccessCluster checks whether the user can access cluster level routes.
 func (p *Permission) CanAccessCluster() (bool, error) {
-	for _, pattern := range p.Indices {
-		pattern = strings.Replace(pattern, "*", ".*", -1)
-		matched, err := regexp.MatchString(pattern, "*")
-		if err != nil {
-			return false, err
-		}
-		if matched {
-			return true, nil
-		}
-	}
-	return false, nil
+	return matcher.Indices(p.Indices).Match("*"), nil
 }
 
 // CanAccessIndex checks whether the permission has access to given index or index pattern.
@@ -494,19 +616,9 @@ func (p *Permission) CanAccessIndex(name string) (bool, error) {
 		if suggestionsIndex == "" {
 			suggestionsIndex = ".suggestions"
 		}
-		indices = append(indices, suggestionsIndex)
+		indices = append(indices[:len(indices):len(indices)], suggestionsIndex)
 	}
-	for _, pattern := range indices {
-		matched, err := util.ValidateIndex(pattern, name)
-		if err != nil {
-			log.Errorln("invalid index regexp", pattern, "encountered: ", err)
-			return false, err
-		}
-		if matched {
-			return matched, nil
-		}
-	}
-	return false, nil
+	return matcher.Indices(indices).Match(name), nil
 }
 
 // CanAccessIndices checks whether the user has access to the given indices.
@@ -521,53 +633,58 @@ func (p *Permission) CanAccessIndices(indices ...string) (bool, error) {
 
 // GetLimitFor returns the rate limit for the given category in the permission.
 func (p *Permission) GetLimitFor(c category.Category) (int64, error) {
+	return p.Limits.For(c)
+}
+
+// For returns the rate limit for the given category.
+func (l *Limits) For(c category.Category) (int64, error) {
 	switch c {
 	case category.Docs:
-		return p.Limits.DocsLimit, nil
+		return l.DocsLimit, nil
 	case category.Search:
-		return p.Limits.SearchLimit, nil
+		return l.SearchLimit, nil
 	case category.Indices:
-		return p.Limits.IndicesLimit, nil
+		return l.IndicesLimit, nil
 	case category.Cat:
-		return p.Limits.CatLimit, nil
+		return l.CatLimit, nil
 	case category.Clusters:
-		return p.Limits.ClustersLimit, nil
+		return l.ClustersLimit, nil
 	case category.Misc:
-		return p.Limits.MiscLimit, nil
+		return l.MiscLimit, nil
 	case category.User:
-		return p.Limits.UserLimit, nil
+		return l.UserLimit, nil
 	case category.Permission:
-		return p.Limits.PermissionLimit, nil
+		return l.PermissionLimit, nil
 	case category.Analytics:
-		return p.Limits.AnalyticsLimit, nil
+		return l.AnalyticsLimit, nil
 	case category.Rules:
-		return p.Limits.RulesLimit, nil
+		return l.RulesLimit, nil
 	case category.Suggestions:
-		return p.Limits.SuggestionsLimit, nil
+		return l.SuggestionsLimit, nil
 	case category.Auth:
-		return p.Limits.AuthLimit, nil
+		return l.AuthLimit, nil
 	case category.Streams:
-		return p.Limits.StreamsLimit, nil
+		return l.StreamsLimit, nil
 	case category.ReactiveSearch:
-		return p.Limits.ReactiveSearchLimit, nil
+		return l.ReactiveSearchLimit, nil
 	case category.SearchRelevancy:
-		return p.Limits.SearchRelevancyLimit, nil
+		return l.SearchRelevancyLimit, nil
 	case category.SearchGrader:
-		return p.Limits.SearchGraderLimit, nil
+		return l.SearchGraderLimit, nil
 	case category.UIBuilder:
-		return p.Limits.EcommIntegrationLimit, nil
+		return l.EcommIntegrationLimit, nil
 	case category.Logs:
-		return p.Limits.LogsLimit, nil
+		return l.LogsLimit, nil
 	case category.Synonyms:
-		return p.Limits.SynonymsLimit, nil
+		return l.SynonymsLimit, nil
 	case category.Cache:
-		return p.Limits.CacheLimit, nil
+		return l.CacheLimit, nil
 	case category.StoredQuery:
-		return p.Limits.StoredQueryLimit, nil
+		return l.StoredQueryLimit, nil
 	case category.Pipelines:
-		return p.Limits.PipelinesLimit, nil
+		return l.PipelinesLimit, nil
 	case category.Sync:
-		return p.Limits.SyncLimit, nil
+		return l.SyncLimit, nil
 	default:
 		return -1, fmt.Errorf(`we do not rate limit "%s" category`, c)
 	}
@@ -710,6 +827,9 @@ func (p *Permission) GetPatch(rolePatched bool) (map[string]interface{}, error)
 		if p.Limits.PipelinesLimit != 0 {
 			limits["pipelines_limit"] = p.Limits.PipelinesLimit
 		}
+		if p.Limits.Burst != 0 {
+			limits["burst"] = p.Limits.Burst
+		}
 
 		patch["limits"] = limits
 	}
@@ -717,6 +837,9 @@ func (p *Permission) GetPatch(rolePatched bool) (map[string]interface{}, error)
 		patch["description"] = p.Description
 	}
 	if p.ReactiveSearchConfig != nil {
+		if err := p.ReactiveSearchConfig.Validate(); err != nil {
+			return nil, err
+		}
 		patch["reactivesearchConfig"] = p.ReactiveSearchConfig
 	}
 	if p.Includes != nil {
@@ -725,6 +848,57 @@ func (p *Permission) GetPatch(rolePatched bool) (map[string]interface{}, error)
 	if p.Excludes != nil {
 		patch["exclude_fields"] = p.Excludes
 	}
+	if p.SigningKeys != nil {
+		if err := validateSigningKeys(p.SigningKeys); err != nil {
+			return nil, err
+		}
+		patch["signing_keys"] = withSigningKeyDefaults(p.SigningKeys)
+	}
+	if p.Roles != nil {
+		patch["roles"] = p.Roles
+	}
+	if p.Filter != nil {
+		if err := validateFilter(p.Filter); err != nil {
+			return nil, err
+		}
+		patch["filter"] = p.Filter
+	}
+	if p.Quota != nil {
+		if err := p.Quota.Validate(); err != nil {
+			return nil, err
+		}
+		patch["quota"] = p.Quota
+	}
+	if p.RequestLimits != nil {
+		if err := p.RequestLimits.Validate(); err != nil {
+			return nil, err
+		}
+		patch["request_limits"] = p.RequestLimits
+	}
+	if p.Schedule != nil {
+		if err := p.Schedule.Validate(); err != nil {
+			return nil, err
+		}
+		patch["schedule"] = p.Schedule
+	}
+	if p.WriteIncludes != nil {
+		if err := validateFieldPatterns(p.WriteIncludes); err != nil {
+			return nil, err
+		}
+		patch["write_include_fields"] = p.WriteIncludes
+	}
+	if p.WriteExcludes != nil {
+		if err := validateFieldPatterns(p.WriteExcludes); err != nil {
+			return nil, err
+		}
+		patch["write_exclude_fields"] = p.WriteExcludes
+	}
+	if p.WriteProtection != "" {
+		if err := validateWriteProtection(p.WriteProtection); err != nil {
+			return nil, err
+		}
+		patch["write_protection"] = p.WriteProtection
+	}
 
 	return patch, nil
 }
@@ -0,0 +1,132 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+)
+
+// Periods of the quotas, the windows are the UTC days and months.
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+)
+
+// defaultSoftLimit is the used fraction of a quota after which the responses carry a warning.
+const defaultSoftLimit = 0.8
+
+// Quota is a budget of requests per day and per month, optionally per category.
+type Quota struct {
+	QuotaLimit
+	// Categories are the budgets per category, e.g. "search"
+	Categories map[string]QuotaLimit `json:"categories,omitempty"`
+	// SoftLimit is the used fraction of a budget after which the responses carry a warning, defaults to 0.8
+	SoftLimit float64 `json:"soft_limit,omitempty"`
+}
+
+// QuotaLimit is the number of requests allowed per day and per month, a zero
+// limit doesn't restrict the period.
+type QuotaLimit struct {
+	Daily   int64 `json:"daily,omitempty"`
+	Monthly int64 `json:"monthly,omitempty"`
+}
+
+// QuotaBudget is a single budget of a quota, the category is empty for the
+// budget shared by all the categories.
+type QuotaBudget struct {
+	Period   string
+	Category string
+	Limit    int64
+}
+
+// SetQuota sets the request budget of the permission.
+func SetQuota(quota *Quota) Options {
+	return func(p *Permission) error {
+		if err := quota.Validate(); err != nil {
+			return err
+		}
+		p.Quota = quota
+		return nil
+	}
+}
+
+// Validate checks the limits and the categories of the quota.
+func (q *Quota) Validate() error {
+	if err := q.QuotaLimit.validate("quota"); err != nil {
+		return err
+	}
+	for name, limit := range q.Categories {
+		// an unknown category is unmarshalled without an error
+		var c category.Category
+		raw, _ := json.Marshal(name)
+		if err := json.Unmarshal(raw, &c); err != nil || c.String() != name {
+			return fmt.Errorf(`invalid quota category "%s"`, name)
+		}
+		if err := limit.validate(fmt.Sprintf(`quota of the "%s" category`, name)); err != nil {
+			return err
+		}
+	}
+	if q.SoftLimit < 0 || q.SoftLimit > 1 {
+		return fmt.Errorf(`quota "soft_limit" must be between 0 and 1`)
+	}
+	return nil
+}
+
+func (l QuotaLimit) validate(name string) error {
+	if l.Daily < 0 || l.Monthly < 0 {
+		return fmt.Errorf(`%s can't be negative`, name)
+	}
+	return nil
+}
+
+// SoftLimitFraction returns the used fraction of a budget after which the
+// responses carry a warning.
+func (q *Quota) SoftLimitFraction() float64 {
+	if q.SoftLimit == 0 {
+		return defaultSoftLimit
+	}
+	return q.SoftLimit
+}
+
+// BudgetsFor returns the budgets that a request of the category counts against.
+func (q *Quota) BudgetsFor(c string) []QuotaBudget {
+	budgets := q.QuotaLimit.budgets("")
+	if limit, ok := q.Categories[c]; ok {
+		budgets = append(budgets, limit.budgets(c)...)
+	}
+	return budgets
+}
+
+// Budgets returns all the budgets of the quota.
+func (q *Quota) Budgets() []QuotaBudget {
+	budgets := q.QuotaLimit.budgets("")
+	for c, limit := range q.Categories {
+		budgets = append(budgets, limit.budgets(c)...)
+	}
+	return budgets
+}
+
+func (l QuotaLimit) budgets(c string) []QuotaBudget {
+	var budgets []QuotaBudget
+	if l.Daily > 0 {
+		budgets = append(budgets, QuotaBudget{Period: QuotaDaily, Category: c, Limit: l.Daily})
+	}
+	if l.Monthly > 0 {
+		budgets = append(budgets, QuotaBudget{Period: QuotaMonthly, Category: c, Limit: l.Monthly})
+	}
+	return budgets
+}
+
+// QuotaWindow returns the window of the period the instant falls in, e.g.
+// "2021-03-01" for a day, along with the instant the window ends at.
+func QuotaWindow(period string, now time.Time) (string, time.Time) {
+	now = now.UTC()
+	if period == QuotaMonthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,67 @@
+package permission
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// queryTypes are the types of the queries of the reactivesearch api.
+var queryTypes = []string{"search", "term", "range", "geo", "suggestion"}
+
+// Validate checks the query types, the limits and the patterns of the config.
+func (c *ReactiveSearchConfig) Validate() error {
+	for _, queryType := range c.AllowedQueryTypes {
+		if !util.Contains(queryTypes, queryType) {
+			return fmt.Errorf(`invalid query type "%s" in "allowedQueryTypes", must be one of: %s`,
+				queryType, strings.Join(queryTypes, ", "))
+		}
+	}
+	if c.MaxQueries != nil && *c.MaxQueries < 1 {
+		return fmt.Errorf(`"maxQueries" must be greater than 0`)
+	}
+	if c.MaxResultWindow != nil && *c.MaxResultWindow < 1 {
+		return fmt.Errorf(`"maxResultWindow" must be greater than 0`)
+	}
+	for _, host := range c.AllowedEndpointHosts {
+		if strings.TrimSpace(host) == "" {
+			return fmt.Errorf(`"allowedEndpointHosts" can't contain an empty host`)
+		}
+	}
+	return validateFieldPatterns(c.AllowedDataFields)
+}
+
+// AllowsQueryType returns true if the queries of the type can be executed.
+func (c *ReactiveSearchConfig) AllowsQueryType(queryType string) bool {
+	return len(c.AllowedQueryTypes) == 0 || util.Contains(c.AllowedQueryTypes, queryType)
+}
+
+// CanQueryField returns true if the queries can be run on the field, the
+// patterns of the allowed data fields match the sub fields as well.
+func (c *ReactiveSearchConfig) CanQueryField(field string) bool {
+	return len(c.AllowedDataFields) == 0 || matchesAny(c.AllowedDataFields, field)
+}
+
+// AllowsEndpointHost returns true if the endpoint of a query can point to the
+// host, a "*." prefix matches the sub domains of the host.
+func (c *ReactiveSearchConfig) AllowsEndpointHost(host string) bool {
+	if c.DisableEndpoint != nil && *c.DisableEndpoint {
+		return false
+	}
+	if len(c.AllowedEndpointHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range c.AllowedEndpointHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package permission
+
+import "fmt"
+
+// RequestLimits caps the size and the complexity of the requests, a zero
+// limit doesn't restrict the requests.
+type RequestLimits struct {
+	// MaxBodyBytes is the size of the request body in bytes
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+	// MaxJSONDepth is the nesting of the objects and the arrays of the request body
+	MaxJSONDepth int `json:"max_json_depth,omitempty"`
+	// MaxReactDepth is the nesting of the react prop of a reactivesearch query
+	MaxReactDepth int `json:"max_react_depth,omitempty"`
+	// MaxReactFanOut is the number of queries a reactivesearch query reacts to
+	MaxReactFanOut int `json:"max_react_fan_out,omitempty"`
+	// MaxBulkLines is the number of lines of a _bulk request
+	MaxBulkLines int `json:"max_bulk_lines,omitempty"`
+}
+
+// SetRequestLimits sets the limits of the size and the complexity of the
+// requests of the permission, they can only tighten the global limits.
+func SetRequestLimits(limits *RequestLimits) Options {
+	return func(p *Permission) error {
+		if err := limits.Validate(); err != nil {
+			return err
+		}
+		p.RequestLimits = limits
+		return nil
+	}
+}
+
+// Validate checks that none of the limits is negative.
+func (l *RequestLimits) Validate() error {
+	if l.MaxBodyBytes < 0 || l.MaxJSONDepth < 0 || l.MaxReactDepth < 0 ||
+		l.MaxReactFanOut < 0 || l.MaxBulkLines < 0 {
+		return fmt.Errorf(`"request_limits" can't be negative`)
+	}
+	return nil
+}
+
+// Tighten returns the tightest of the limits, a zero limit is ignored.
+func (l RequestLimits) Tighten(other *RequestLimits) RequestLimits {
+	if other == nil {
+		return l
+	}
+	return RequestLimits{
+		MaxBodyBytes:   tightestLimit(l.MaxBodyBytes, other.MaxBodyBytes),
+		MaxJSONDepth:   int(tightestLimit(int64(l.MaxJSONDepth), int64(other.MaxJSONDepth))),
+		MaxReactDepth:  int(tightestLimit(int64(l.MaxReactDepth), int64(other.MaxReactDepth))),
+		MaxReactFanOut: int(tightestLimit(int64(l.MaxReactFanOut), int64(other.MaxReactFanOut))),
+		MaxBulkLines:   int(tightestLimit(int64(l.MaxBulkLines), int64(other.MaxBulkLines))),
+	}
+}
+
+func tightestLimit(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,210 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule restricts the time windows in which a permission can be used.
+type Schedule struct {
+	// TimeZone is the IANA time zone of the weekdays and the hours, defaults to UTC
+	TimeZone string `json:"time_zone,omitempty"`
+	// Weekdays are the days of the week the permission can be used on, e.g. "monday"
+	Weekdays []string `json:"weekdays,omitempty"`
+	// Hours is the time of the day the permission can be used at
+	Hours *HourRange `json:"hours,omitempty"`
+	// NotBefore is the RFC3339 instant the permission gets activated at
+	NotBefore string `json:"not_before,omitempty"`
+	// NotAfter is the RFC3339 instant the permission gets deactivated at
+	NotAfter string `json:"not_after,omitempty"`
+	// Blackouts are the maintenance windows the permission can't be used in
+	Blackouts []Blackout `json:"blackouts,omitempty"`
+
+	// location is the parsed time zone, so that it isn't loaded on every request
+	location *time.Location
+}
+
+// UnmarshalJSON parses the time zone of the stored schedules once.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	type schedule Schedule
+	if err := json.Unmarshal(data, (*schedule)(s)); err != nil {
+		return err
+	}
+	// an invalid time zone is reported by Validate and Check
+	s.location, _ = time.LoadLocation(s.TimeZone)
+	return nil
+}
+
+// HourRange is a range of the time of the day in the "15:04" format, the end is
+// exclusive and a range that ends before it starts spans midnight.
+type HourRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Blackout is a window of RFC3339 instants in which the permission can't be used.
+type Blackout struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+const hourLayout = "15:04"
+
+// SetSchedule sets the time windows in which the permission can be used.
+func SetSchedule(schedule *Schedule) Options {
+	return func(p *Permission) error {
+		if err := schedule.Validate(); err != nil {
+			return err
+		}
+		p.Schedule = schedule
+		return nil
+	}
+}
+
+// Validate checks the time zone, the weekdays and the time formats of the schedule.
+func (s *Schedule) Validate() error {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return fmt.Errorf(`invalid schedule "time_zone": %s`, s.TimeZone)
+	}
+	s.location = location
+	for _, day := range s.Weekdays {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf(`invalid schedule weekday: %s`, day)
+		}
+	}
+	if s.Hours != nil {
+		if _, err := parseHour(s.Hours.From); err != nil {
+			return fmt.Errorf(`invalid schedule "hours.from", must be in the HH:MM format: %s`, s.Hours.From)
+		}
+		if _, err := parseHour(s.Hours.To); err != nil {
+			return fmt.Errorf(`invalid schedule "hours.to", must be in the HH:MM format: %s`, s.Hours.To)
+		}
+	}
+	notBefore, err := parseInstant(s.NotBefore)
+	if err != nil {
+		return fmt.Errorf(`invalid schedule "not_before", must be in the RFC3339 format: %s`, s.NotBefore)
+	}
+	notAfter, err := parseInstant(s.NotAfter)
+	if err != nil {
+		return fmt.Errorf(`invalid schedule "not_after", must be in the RFC3339 format: %s`, s.NotAfter)
+	}
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		return fmt.Errorf(`schedule "not_after" must be after "not_before"`)
+	}
+	for _, blackout := range s.Blackouts {
+		from, err := parseInstant(blackout.From)
+		if err != nil || from.IsZero() {
+			return fmt.Errorf(`invalid blackout "from", must be in the RFC3339 format: %s`, blackout.From)
+		}
+		to, err := parseInstant(blackout.To)
+		if err != nil || to.IsZero() {
+			return fmt.Errorf(`invalid blackout "to", must be in the RFC3339 format: %s`, blackout.To)
+		}
+		if !to.After(from) {
+			return fmt.Errorf(`blackout "to" must be after "from"`)
+		}
+	}
+	return nil
+}
+
+// Check returns an error describing why the schedule doesn't allow the access at the instant.
+func (s *Schedule) Check(now time.Time) error {
+	notBefore, _ := parseInstant(s.NotBefore)
+	if !notBefore.IsZero() && now.Before(notBefore) {
+		return fmt.Errorf("permission is not active before %s", s.NotBefore)
+	}
+	notAfter, _ := parseInstant(s.NotAfter)
+	if !notAfter.IsZero() && !now.Before(notAfter) {
+		return fmt.Errorf("permission is not active since %s", s.NotAfter)
+	}
+	for _, blackout := range s.Blackouts {
+		from, _ := parseInstant(blackout.From)
+		to, _ := parseInstant(blackout.To)
+		if !now.Before(from) && now.Before(to) {
+			msg := fmt.Sprintf("permission is blacked out from %s to %s", blackout.From, blackout.To)
+			if blackout.Reason != "" {
+				msg += ": " + blackout.Reason
+			}
+			return fmt.Errorf("%s", msg)
+		}
+	}
+
+	location := s.location
+	if location == nil {
+		var err error
+		if location, err = time.LoadLocation(s.TimeZone); err != nil {
+			return fmt.Errorf(`invalid schedule "time_zone": %s`, s.TimeZone)
+		}
+	}
+	local := now.In(location)
+	if len(s.Weekdays) > 0 {
+		allowed := false
+		for _, day := range s.Weekdays {
+			if weekday, ok := parseWeekday(day); ok && weekday == local.Weekday() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("permission can only be used on %s (%s), today is %s",
+				strings.Join(s.Weekdays, ", "), location, strings.ToLower(local.Weekday().String()))
+		}
+	}
+	if s.Hours != nil {
+		from, _ := parseHour(s.Hours.From)
+		to, _ := parseHour(s.Hours.To)
+		minute := local.Hour()*60 + local.Minute()
+		var allowed bool
+		if from <= to {
+			allowed = minute >= from && minute < to
+		} else {
+			// the range spans midnight
+			allowed = minute >= from || minute < to
+		}
+		if !allowed {
+			return fmt.Errorf("permission can only be used from %s to %s (%s), the time is %s",
+				s.Hours.From, s.Hours.To, location, local.Format(hourLayout))
+		}
+	}
+	return nil
+}
+
+// CheckSchedule returns an error if the permission can't be used at the instant
+// because of its schedule.
+func (p *Permission) CheckSchedule(now time.Time) error {
+	if p.Schedule == nil {
+		return nil
+	}
+	return p.Schedule.Check(now)
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(day)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if day == name || day == name[:3] {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// parseHour returns the minutes since midnight.
+func parseHour(hour string) (int, error) {
+	t, err := time.Parse(hourLayout, hour)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseInstant(instant string) (time.Time, error) {
+	if instant == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, instant)
+}
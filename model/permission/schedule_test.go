@@ -0,0 +1,63 @@
+package permission
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchedule(t *testing.T) {
+	Convey("should validate the schedule", t, func() {
+		So((&Schedule{TimeZone: "Asia/Kolkata", Weekdays: []string{"mon", "Friday"}}).Validate(), ShouldBeNil)
+		So((&Schedule{TimeZone: "Mars/Olympus"}).Validate(), ShouldNotBeNil)
+		So((&Schedule{Weekdays: []string{"funday"}}).Validate(), ShouldNotBeNil)
+		So((&Schedule{Hours: &HourRange{From: "9am", To: "18:00"}}).Validate(), ShouldNotBeNil)
+		So((&Schedule{NotBefore: "2021-02-01T00:00:00Z", NotAfter: "2021-01-01T00:00:00Z"}).Validate(), ShouldNotBeNil)
+	})
+	Convey("should allow the weekdays and the hours in the time zone", t, func() {
+		s := &Schedule{
+			TimeZone: "America/New_York",
+			Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"},
+			Hours:    &HourRange{From: "09:00", To: "18:00"},
+		}
+		// Monday 10:00 in New York
+		So(s.Check(time.Date(2021, 3, 1, 15, 0, 0, 0, time.UTC)), ShouldBeNil)
+		// Monday 08:00 in New York
+		So(s.Check(time.Date(2021, 3, 1, 13, 0, 0, 0, time.UTC)), ShouldNotBeNil)
+		// Saturday 10:00 in New York
+		So(s.Check(time.Date(2021, 3, 6, 15, 0, 0, 0, time.UTC)), ShouldNotBeNil)
+	})
+	Convey("should parse the time zone once", t, func() {
+		var s Schedule
+		So(json.Unmarshal([]byte(`{"time_zone":"Asia/Kolkata","hours":{"from":"09:00","to":"18:00"}}`), &s), ShouldBeNil)
+		So(s.location, ShouldNotBeNil)
+		So(s.location.String(), ShouldEqual, "Asia/Kolkata")
+		// 10:00 in Kolkata
+		So(s.Check(time.Date(2021, 3, 1, 4, 30, 0, 0, time.UTC)), ShouldBeNil)
+
+		s = Schedule{TimeZone: "Europe/Paris"}
+		So(s.Validate(), ShouldBeNil)
+		So(s.location.String(), ShouldEqual, "Europe/Paris")
+	})
+	Convey("should allow the hours spanning midnight", t, func() {
+		s := &Schedule{Hours: &HourRange{From: "22:00", To: "02:00"}}
+		So(s.Check(time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC)), ShouldBeNil)
+		So(s.Check(time.Date(2021, 3, 1, 1, 59, 0, 0, time.UTC)), ShouldBeNil)
+		So(s.Check(time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC)), ShouldNotBeNil)
+	})
+	Convey("should enforce the activation window and the blackouts", t, func() {
+		s := &Schedule{
+			NotBefore: "2021-03-01T00:00:00Z",
+			NotAfter:  "2021-04-01T00:00:00Z",
+			Blackouts: []Blackout{{From: "2021-03-10T00:00:00Z", To: "2021-03-11T00:00:00Z", Reason: "maintenance"}},
+		}
+		So(s.Check(time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)), ShouldNotBeNil)
+		So(s.Check(time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)), ShouldBeNil)
+		err := s.Check(time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "maintenance")
+		So(s.Check(time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)), ShouldNotBeNil)
+	})
+}
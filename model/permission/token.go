@@ -0,0 +1,286 @@
+package permission
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultTokenTTL is the lifetime of a scoped token when none is requested.
+	DefaultTokenTTL = 5 * time.Minute
+	// MaxTokenTTL is the maximum lifetime of a scoped token.
+	MaxTokenTTL = 24 * time.Hour
+
+	scopedTokenType = "scoped"
+)
+
+// TokenScope narrows down the access of a permission for a scoped token.
+type TokenScope struct {
+	Indices []string               `json:"indices,omitempty"`
+	ACLs    []acl.ACL              `json:"acls,omitempty"`
+	Limits  *Limits                `json:"limits,omitempty"`
+	Referer string                 `json:"referer,omitempty"`
+	Source  string                 `json:"source,omitempty"`
+	Filter  map[string]interface{} `json:"filter,omitempty"`
+}
+
+// ScopedTokenClaims represents the claims of a token minted from a permission.
+type ScopedTokenClaims struct {
+	jwt.StandardClaims
+	Type string `json:"typ"`
+	TokenScope
+}
+
+// tokenSigningKey derives the key used to sign the scoped tokens of a permission,
+// a token gets invalidated as soon as the permission is deleted.
+func (p *Permission) tokenSigningKey() []byte {
+	mac := hmac.New(sha256.New, []byte(p.Password))
+	mac.Write([]byte(scopedTokenType + ":" + p.Username))
+	return mac.Sum(nil)
+}
+
+// ValidateScope checks that the scope doesn't grant more access than the permission has.
+func (p *Permission) ValidateScope(scope TokenScope) error {
+	for _, pattern := range scope.Indices {
+		ok, err := p.CanAccessIndex(pattern)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf(`permission doesn't have access to "%s" index`, pattern)
+		}
+	}
+	for _, a := range scope.ACLs {
+		if !p.HasACL(a) {
+			return fmt.Errorf(`permission doesn't have access to "%s" acl`, a)
+		}
+	}
+	if scope.Source != "" {
+		if err := validateSources([]string{scope.Source}); err != nil {
+			return err
+		}
+		if !p.containsSource(scope.Source) {
+			return fmt.Errorf(`permission doesn't allow the requests from "%s" source`, scope.Source)
+		}
+	}
+	if scope.Referer != "" {
+		if err := validateReferers([]string{scope.Referer}); err != nil {
+			return err
+		}
+		if !p.containsReferer(scope.Referer) {
+			return fmt.Errorf(`permission doesn't allow the requests from "%s" referer`, scope.Referer)
+		}
+	}
+	for field, value := range scope.Filter {
+		if !isTermValue(value) {
+			return fmt.Errorf(`filter value for "%s" must be a string, number, boolean or an array of them`, field)
+		}
+	}
+	return nil
+}
+
+// containsSource checks whether the CIDR lies within one of the permission sources.
+func (p *Permission) containsSource(source string) bool {
+	ip, scopedNet, err := net.ParseCIDR(source)
+	if err != nil {
+		return false
+	}
+	scopedOnes, _ := scopedNet.Mask.Size()
+	for _, s := range p.Sources {
+		_, allowedNet, err := net.ParseCIDR(s)
+		if err != nil {
+			continue
+		}
+		allowedOnes, _ := allowedNet.Mask.Size()
+		if allowedNet.Contains(ip) && allowedOnes <= scopedOnes {
+			return true
+		}
+	}
+	return false
+}
+
+// containsReferer checks whether the referer is matched by one of the permission referers.
+func (p *Permission) containsReferer(referer string) bool {
+	return matcher.Referers(p.Referers).Match(referer)
+}
+
+// NewScopedToken mints a signed token for the permission that expires after the ttl.
+func (p *Permission) NewScopedToken(scope TokenScope, ttl time.Duration) (string, time.Time, error) {
+	if err := p.ValidateScope(scope); err != nil {
+		return "", time.Time{}, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	if ttl > MaxTokenTTL {
+		return "", time.Time{}, fmt.Errorf("token ttl can't be more than %s", MaxTokenTTL)
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := ScopedTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   p.Username,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+		Type:       scopedTokenType,
+		TokenScope: scope,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.tokenSigningKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// IsScopedToken checks whether the token has been minted from a permission, it
+// returns the username of the permission in that case.
+func IsScopedToken(tokenString string) (string, bool) {
+	var claims ScopedTokenClaims
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &claims)
+	if err != nil || token.Method != jwt.SigningMethodHS256 {
+		return "", false
+	}
+	if claims.Type != scopedTokenType || claims.Subject == "" {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// ParseScopedToken verifies the token against the permission and returns
+// the claims of a valid token.
+func (p *Permission) ParseScopedToken(tokenString string) (*ScopedTokenClaims, error) {
+	var claims ScopedTokenClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return p.tokenSigningKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != p.Username || claims.Type != scopedTokenType {
+		return nil, fmt.Errorf("token is not issued for the permission")
+	}
+	// the permission might have been narrowed down after the token was issued
+	if err := p.ValidateScope(claims.TokenScope); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// Narrow returns a copy of the permission with the access limited to the scope.
+func (p *Permission) Narrow(scope TokenScope) *Permission {
+	narrowed := *p
+	if len(scope.Indices) > 0 {
+		narrowed.Indices = scope.Indices
+	}
+	if len(scope.ACLs) > 0 {
+		narrowed.ACLs = scope.ACLs
+	}
+	if scope.Limits != nil && p.Limits != nil {
+		narrowed.Limits = narrowLimits(p.Limits, scope.Limits)
+	}
+	if scope.Referer != "" {
+		narrowed.Referers = []string{scope.Referer}
+	}
+	if scope.Source != "" {
+		narrowed.Sources = []string{scope.Source}
+	}
+	if len(scope.Filter) > 0 {
+		narrowed.TokenFilter = scope.Filter
+	}
+	// the token is not allowed to manage the signing keys nor to issue other tokens
+	narrowed.SigningKeys = nil
+	narrowed.Scoped = true
+	return &narrowed
+}
+
+// narrowLimit returns the scoped limit if it's lower than the permission limit.
+func narrowLimit(limit int64, scoped int64) int64 {
+	if scoped > 0 && scoped < limit {
+		return scoped
+	}
+	return limit
+}
+
+// maxLimit returns the higher of the two limits.
+func maxLimit(limit int64, other int64) int64 {
+	if other > limit {
+		return other
+	}
+	return limit
+}
+
+func narrowLimits(limits *Limits, scoped *Limits) *Limits {
+	return &Limits{
+		IPLimit:               narrowLimit(limits.IPLimit, scoped.IPLimit),
+		DocsLimit:             narrowLimit(limits.DocsLimit, scoped.DocsLimit),
+		SearchLimit:           narrowLimit(limits.SearchLimit, scoped.SearchLimit),
+		IndicesLimit:          narrowLimit(limits.IndicesLimit, scoped.IndicesLimit),
+		CatLimit:              narrowLimit(limits.CatLimit, scoped.CatLimit),
+		ClustersLimit:         narrowLimit(limits.ClustersLimit, scoped.ClustersLimit),
+		MiscLimit:             narrowLimit(limits.MiscLimit, scoped.MiscLimit),
+		UserLimit:             narrowLimit(limits.UserLimit, scoped.UserLimit),
+		PermissionLimit:       narrowLimit(limits.PermissionLimit, scoped.PermissionLimit),
+		AnalyticsLimit:        narrowLimit(limits.AnalyticsLimit, scoped.AnalyticsLimit),
+		RulesLimit:            narrowLimit(limits.RulesLimit, scoped.RulesLimit),
+		SuggestionsLimit:      narrowLimit(limits.SuggestionsLimit, scoped.SuggestionsLimit),
+		StreamsLimit:          narrowLimit(limits.StreamsLimit, scoped.StreamsLimit),
+		AuthLimit:             narrowLimit(limits.AuthLimit, scoped.AuthLimit),
+		ReactiveSearchLimit:   narrowLimit(limits.ReactiveSearchLimit, scoped.ReactiveSearchLimit),
+		SearchRelevancyLimit:  narrowLimit(limits.SearchRelevancyLimit, scoped.SearchRelevancyLimit),
+		SearchGraderLimit:     narrowLimit(limits.SearchGraderLimit, scoped.SearchGraderLimit),
+		EcommIntegrationLimit: narrowLimit(limits.EcommIntegrationLimit, scoped.EcommIntegrationLimit),
+		LogsLimit:             narrowLimit(limits.LogsLimit, scoped.LogsLimit),
+		SynonymsLimit:         narrowLimit(limits.SynonymsLimit, scoped.SynonymsLimit),
+		CacheLimit:            narrowLimit(limits.CacheLimit, scoped.CacheLimit),
+		StoredQueryLimit:      narrowLimit(limits.StoredQueryLimit, scoped.StoredQueryLimit),
+		SyncLimit:             narrowLimit(limits.SyncLimit, scoped.SyncLimit),
+		PipelinesLimit:        narrowLimit(limits.PipelinesLimit, scoped.PipelinesLimit),
+		Burst:                 narrowLimit(limits.Burst, scoped.Burst),
+	}
+}
+
+// MaxLimits returns the higher of the two limits for each category.
+func MaxLimits(limits *Limits, other *Limits) *Limits {
+	if limits == nil {
+		copied := *other
+		return &copied
+	}
+	return &Limits{
+		IPLimit:               maxLimit(limits.IPLimit, other.IPLimit),
+		DocsLimit:             maxLimit(limits.DocsLimit, other.DocsLimit),
+		SearchLimit:           maxLimit(limits.SearchLimit, other.SearchLimit),
+		IndicesLimit:          maxLimit(limits.IndicesLimit, other.IndicesLimit),
+		CatLimit:              maxLimit(limits.CatLimit, other.CatLimit),
+		ClustersLimit:         maxLimit(limits.ClustersLimit, other.ClustersLimit),
+		MiscLimit:             maxLimit(limits.MiscLimit, other.MiscLimit),
+		UserLimit:             maxLimit(limits.UserLimit, other.UserLimit),
+		PermissionLimit:       maxLimit(limits.PermissionLimit, other.PermissionLimit),
+		AnalyticsLimit:        maxLimit(limits.AnalyticsLimit, other.AnalyticsLimit),
+		RulesLimit:            maxLimit(limits.RulesLimit, other.RulesLimit),
+		SuggestionsLimit:      maxLimit(limits.SuggestionsLimit, other.SuggestionsLimit),
+		StreamsLimit:          maxLimit(limits.StreamsLimit, other.StreamsLimit),
+		AuthLimit:             maxLimit(limits.AuthLimit, other.AuthLimit),
+		ReactiveSearchLimit:   maxLimit(limits.ReactiveSearchLimit, other.ReactiveSearchLimit),
+		SearchRelevancyLimit:  maxLimit(limits.SearchRelevancyLimit, other.SearchRelevancyLimit),
+		SearchGraderLimit:     maxLimit(limits.SearchGraderLimit, other.SearchGraderLimit),
+		EcommIntegrationLimit: maxLimit(limits.EcommIntegrationLimit, other.EcommIntegrationLimit),
+		LogsLimit:             maxLimit(limits.LogsLimit, other.LogsLimit),
+		SynonymsLimit:         maxLimit(limits.SynonymsLimit, other.SynonymsLimit),
+		CacheLimit:            maxLimit(limits.CacheLimit, other.CacheLimit),
+		StoredQueryLimit:      maxLimit(limits.StoredQueryLimit, other.StoredQueryLimit),
+		SyncLimit:             maxLimit(limits.SyncLimit, other.SyncLimit),
+		PipelinesLimit:        maxLimit(limits.PipelinesLimit, other.PipelinesLimit),
+		Burst:                 maxLimit(limits.Burst, other.Burst),
+	}
+}
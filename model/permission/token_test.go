@@ -0,0 +1,58 @@
+package permission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScopedToken(t *testing.T) {
+	p, _ := New("foo", SetIndices([]string{"products-*"}), SetSources([]string{"10.0.0.0/8"}))
+	Convey("should mint and verify a token", t, func() {
+		token, expiresAt, err := p.NewScopedToken(TokenScope{
+			Indices: []string{"products-us"},
+			Source:  "10.1.0.0/16",
+			Filter:  map[string]interface{}{"tenant": "acme"},
+		}, time.Minute)
+		So(err, ShouldBeNil)
+		So(expiresAt, ShouldHappenAfter, time.Now())
+
+		username, ok := IsScopedToken(token)
+		So(ok, ShouldBeTrue)
+		So(username, ShouldEqual, p.Username)
+
+		claims, err := p.ParseScopedToken(token)
+		So(err, ShouldBeNil)
+		narrowed := p.Narrow(claims.TokenScope)
+		So(narrowed.Scoped, ShouldBeTrue)
+		So(narrowed.Indices, ShouldResemble, []string{"products-us"})
+		So(narrowed.Sources, ShouldResemble, []string{"10.1.0.0/16"})
+		So(narrowed.GetFilterClauses(), ShouldResemble, []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"tenant": "acme"}},
+		})
+		So(p.Indices, ShouldResemble, []string{"products-*"})
+	})
+	Convey("should not verify a token of another permission", t, func() {
+		other, _ := New("foo")
+		token, _, _ := other.NewScopedToken(TokenScope{}, time.Minute)
+		_, err := p.ParseScopedToken(token)
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should not widen the permission", t, func() {
+		_, _, err := p.NewScopedToken(TokenScope{Indices: []string{"orders"}}, time.Minute)
+		So(err, ShouldNotBeNil)
+		_, _, err = p.NewScopedToken(TokenScope{Source: "0.0.0.0/0"}, time.Minute)
+		So(err, ShouldNotBeNil)
+		_, _, err = p.NewScopedToken(TokenScope{ACLs: []acl.ACL{acl.Cat}}, time.Minute)
+		So(err, ShouldNotBeNil)
+		_, _, err = p.NewScopedToken(TokenScope{}, 2*MaxTokenTTL)
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should narrow the limits", t, func() {
+		narrowed := p.Narrow(TokenScope{Limits: &Limits{SearchLimit: 2, DocsLimit: 1000}})
+		So(narrowed.Limits.SearchLimit, ShouldEqual, 2)
+		So(narrowed.Limits.DocsLimit, ShouldEqual, p.Limits.DocsLimit)
+	})
+}
@@ -0,0 +1,156 @@
+package permission
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+)
+
+// Write protection modes, i.e. what happens to a write that sets a protected field.
+const (
+	// WriteProtectionReject rejects the write altogether.
+	WriteProtectionReject = "reject"
+	// WriteProtectionStrip removes the protected fields and lets the write through.
+	WriteProtectionStrip = "strip"
+)
+
+// SetWriteIncludes sets the fields the permission can write to.
+func SetWriteIncludes(fields []string) Options {
+	return func(p *Permission) error {
+		if err := validateFieldPatterns(fields); err != nil {
+			return err
+		}
+		p.WriteIncludes = fields
+		return nil
+	}
+}
+
+// SetWriteExcludes sets the fields the permission can't write to.
+func SetWriteExcludes(fields []string) Options {
+	return func(p *Permission) error {
+		if err := validateFieldPatterns(fields); err != nil {
+			return err
+		}
+		p.WriteExcludes = fields
+		return nil
+	}
+}
+
+// SetWriteProtection sets whether the writes to the protected fields are rejected or stripped.
+func SetWriteProtection(mode string) Options {
+	return func(p *Permission) error {
+		if err := validateWriteProtection(mode); err != nil {
+			return err
+		}
+		p.WriteProtection = mode
+		return nil
+	}
+}
+
+func validateWriteProtection(mode string) error {
+	if mode != WriteProtectionReject && mode != WriteProtectionStrip {
+		return fmt.Errorf(`"write_protection" must be one of "%s" or "%s"`, WriteProtectionReject, WriteProtectionStrip)
+	}
+	return nil
+}
+
+func validateFieldPatterns(fields []string) error {
+	for _, field := range fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("field pattern can't be empty")
+		}
+	}
+	return nil
+}
+
+// HasWriteFilter returns true if the permission restricts the fields it can write to.
+func (p *Permission) HasWriteFilter() bool {
+	return len(p.WriteIncludes) > 0 || len(p.WriteExcludes) > 0
+}
+
+// StripsProtectedFields returns true if the writes to the protected fields get
+// stripped instead of being rejected.
+func (p *Permission) StripsProtectedFields() bool {
+	return p.WriteProtection == WriteProtectionStrip
+}
+
+// matchesAny returns true if the field or one of its parents is matched by the
+// patterns, "*" matches any characters.
+func matchesAny(patterns []string, field string) bool {
+	return matcher.Fields(patterns).Match(field)
+}
+
+// CanWriteField returns true if the permission can write to the field, the
+// field is a dot separated path.
+func (p *Permission) CanWriteField(field string) bool {
+	if len(p.WriteIncludes) > 0 && !matchesAny(p.WriteIncludes, field) {
+		return false
+	}
+	return !matchesAny(p.WriteExcludes, field)
+}
+
+// ProtectedFields returns the sorted paths of the fields of the document
+// that the permission can't write to.
+func (p *Permission) ProtectedFields(doc map[string]interface{}) []string {
+	protected := make(map[string]bool)
+	p.walkProtected(doc, "", func(field string, _ map[string]interface{}, _ string) {
+		protected[field] = true
+	})
+	fields := make([]string, 0, len(protected))
+	for field := range protected {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// StripProtectedFields removes the fields the permission can't write to from
+// the document and returns their sorted paths.
+func (p *Permission) StripProtectedFields(doc map[string]interface{}) []string {
+	fields := p.ProtectedFields(doc)
+	p.walkProtected(doc, "", func(_ string, parent map[string]interface{}, key string) {
+		delete(parent, key)
+	})
+	return fields
+}
+
+// walkProtected calls fn for every leaf field of the document that can't be
+// written, the objects inside the arrays share the path of the array.
+func (p *Permission) walkProtected(doc map[string]interface{}, prefix string, fn func(field string, parent map[string]interface{}, key string)) {
+	for key, value := range doc {
+		field := prefix + key
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				if !p.CanWriteField(field) {
+					fn(field, doc, key)
+				}
+				continue
+			}
+			p.walkProtected(v, field+".", fn)
+			// an object left empty by stripping is removed as well
+			if len(v) == 0 {
+				delete(doc, key)
+			}
+		case []interface{}:
+			// an empty array clears the field
+			protected := len(v) == 0 && !p.CanWriteField(field)
+			for _, item := range v {
+				if object, ok := item.(map[string]interface{}); ok {
+					p.walkProtected(object, field+".", fn)
+				} else if !p.CanWriteField(field) {
+					protected = true
+				}
+			}
+			if protected {
+				fn(field, doc, key)
+			}
+		default:
+			if !p.CanWriteField(field) {
+				fn(field, doc, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package permission
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteFilter(t *testing.T) {
+	Convey("should match the fields along with their sub fields", t, func() {
+		p := &Permission{WriteIncludes: []string{"title", "meta.*"}, WriteExcludes: []string{"meta.owner"}}
+		So(p.CanWriteField("title"), ShouldBeTrue)
+		So(p.CanWriteField("title.raw"), ShouldBeTrue)
+		So(p.CanWriteField("titles"), ShouldBeFalse)
+		So(p.CanWriteField("meta.tags"), ShouldBeTrue)
+		So(p.CanWriteField("meta.owner"), ShouldBeFalse)
+		So(p.CanWriteField("meta.owner.id"), ShouldBeFalse)
+		So(p.CanWriteField("price"), ShouldBeFalse)
+	})
+	Convey("should return the protected fields of a document", t, func() {
+		p := &Permission{WriteExcludes: []string{"price", "seller.id"}}
+		doc := map[string]interface{}{
+			"title":   "book",
+			"price":   10,
+			"seller":  map[string]interface{}{"id": 1, "name": "abc"},
+			"offers":  []interface{}{map[string]interface{}{"price": 5}},
+			"ratings": []interface{}{4, 5},
+		}
+		So(p.ProtectedFields(doc), ShouldResemble, []string{"price", "seller.id"})
+		So(p.HasWriteFilter(), ShouldBeTrue)
+		So((&Permission{}).HasWriteFilter(), ShouldBeFalse)
+	})
+	Convey("should strip the protected fields of a document", t, func() {
+		p := &Permission{WriteExcludes: []string{"secret", "seller.id"}, WriteProtection: WriteProtectionStrip}
+		doc := map[string]interface{}{
+			"title":  "book",
+			"secret": []interface{}{},
+			"seller": map[string]interface{}{"id": 1},
+		}
+		So(p.StripsProtectedFields(), ShouldBeTrue)
+		So(p.StripProtectedFields(doc), ShouldResemble, []string{"secret", "seller.id"})
+		So(doc, ShouldResemble, map[string]interface{}{"title": "book"})
+	})
+	Convey("should validate the write protection", t, func() {
+		So(SetWriteProtection("drop")(&Permission{}), ShouldNotBeNil)
+		So(SetWriteProtection(WriteProtectionReject)(&Permission{}), ShouldBeNil)
+		So(SetWriteExcludes([]string{" "})(&Permission{}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,93 @@
+package role
+
+import (
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// ApplyToPermission returns a copy of the permission with the grants added to
+// its own, the limits are raised to the highest of the permission and the roles.
+func (g *Grants) ApplyToPermission(p *permission.Permission) *permission.Permission {
+	applied := *p
+	applied.Categories = unionCategories(p.Categories, g.Categories)
+	applied.ACLs = unionACLs(p.ACLs, g.ACLs)
+	applied.Ops = unionOps(p.Ops, g.Ops)
+	applied.Indices = unionStrings(p.Indices, g.Indices)
+	applied.Includes = unionStrings(p.Includes, g.Includes)
+	applied.Excludes = unionStrings(p.Excludes, g.Excludes)
+	if g.Limits != nil {
+		applied.Limits = permission.MaxLimits(p.Limits, g.Limits)
+	}
+	return &applied
+}
+
+// ApplyToUser returns a copy of the user with the grants added to its own,
+// the ops, limits and fields of the roles only apply to the permissions.
+func (g *Grants) ApplyToUser(u *user.User) *user.User {
+	applied := *u
+	applied.Categories = unionCategories(u.Categories, g.Categories)
+	applied.ACLs = unionACLs(u.ACLs, g.ACLs)
+	applied.Indices = unionStrings(u.Indices, g.Indices)
+	if g.Limits != nil {
+		applied.Limits = permission.MaxLimits(u.Limits, g.Limits)
+	}
+	return &applied
+}
+
+func unionCategories(values, other []category.Category) []category.Category {
+	result := append([]category.Category{}, values...)
+	for _, value := range other {
+		found := false
+		for _, v := range result {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func unionACLs(values, other []acl.ACL) []acl.ACL {
+	result := append([]acl.ACL{}, values...)
+	for _, value := range other {
+		if !acl.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func unionOps(values, other []op.Operation) []op.Operation {
+	result := append([]op.Operation{}, values...)
+	for _, value := range other {
+		found := false
+		for _, v := range result {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func unionStrings(values, other []string) []string {
+	result := append([]string{}, values...)
+	for _, value := range other {
+		if !util.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package role
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cache holds the resolved grants of the role sets, it must be cleared
+// whenever a role is changed since the grants depend on all the ancestors.
+type Cache struct {
+	mu     sync.RWMutex
+	grants map[string]*Grants
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{grants: make(map[string]*Grants)}
+}
+
+func cacheKey(names []string) string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// Resolve returns the cached grants of the roles, resolving them on a miss.
+func (c *Cache) Resolve(ctx context.Context, lookup Lookup, names ...string) (*Grants, error) {
+	key := cacheKey(names)
+	c.mu.RLock()
+	grants, ok := c.grants[key]
+	c.mu.RUnlock()
+	if ok {
+		return grants, nil
+	}
+	grants, err := Resolve(ctx, lookup, names...)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.grants[key] = grants
+	c.mu.Unlock()
+	return grants, nil
+}
+
+// Clear removes all the resolved grants.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	c.grants = make(map[string]*Grants)
+	c.mu.Unlock()
+}
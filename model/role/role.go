@@ -0,0 +1,220 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+)
+
+// maxDepth is the maximum length of an inheritance chain.
+const maxDepth = 16
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_\-.:]+$`)
+
+// Role is a named set of grants that can be referenced by the users and the
+// permissions, a role inherits the grants of its parent roles.
+type Role struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Inherits    []string            `json:"inherits,omitempty"`
+	Categories  []category.Category `json:"categories,omitempty"`
+	ACLs        []acl.ACL           `json:"acls,omitempty"`
+	Ops         []op.Operation      `json:"ops,omitempty"`
+	Indices     []string            `json:"indices,omitempty"`
+	Limits      *permission.Limits  `json:"limits,omitempty"`
+	Includes    []string            `json:"include_fields,omitempty"`
+	Excludes    []string            `json:"exclude_fields,omitempty"`
+	CreatedAt   string              `json:"created_at"`
+	UpdatedAt   string              `json:"updated_at"`
+}
+
+// Validate checks the role definition, the parent roles are validated while
+// resolving the grants.
+func (r *Role) Validate() error {
+	if !validName.MatchString(r.Name) {
+		return fmt.Errorf(`role name "%s" must only contain letters, digits, "_", "-", "." or ":"`, r.Name)
+	}
+	for _, parent := range r.Inherits {
+		if parent == r.Name {
+			return fmt.Errorf(`role "%s" can't inherit from itself`, r.Name)
+		}
+	}
+	for _, pattern := range r.Indices {
+		if pattern == "" {
+			return fmt.Errorf(`role "%s" has an empty index pattern`, r.Name)
+		}
+	}
+	return nil
+}
+
+// Lookup fetches a role by name.
+type Lookup func(ctx context.Context, name string) (*Role, error)
+
+// Grant identifies a single access a role can grant.
+type Grant struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Grant types.
+const (
+	GrantCategory = "category"
+	GrantACL      = "acl"
+	GrantOp       = "op"
+	GrantIndex    = "index"
+	GrantInclude  = "include_field"
+	GrantExclude  = "exclude_field"
+)
+
+// Grants are the effective grants of a role, i.e. its own grants along with
+// the grants of all of its ancestors.
+type Grants struct {
+	Categories []category.Category `json:"categories"`
+	ACLs       []acl.ACL           `json:"acls"`
+	Ops        []op.Operation      `json:"ops"`
+	Indices    []string            `json:"indices"`
+	Limits     *permission.Limits  `json:"limits,omitempty"`
+	Includes   []string            `json:"include_fields"`
+	Excludes   []string            `json:"exclude_fields"`
+	// GrantedBy maps each grant to the roles that grant it, in the order of resolution
+	GrantedBy map[Grant][]string `json:"-"`
+}
+
+func newGrants() *Grants {
+	return &Grants{
+		Categories: []category.Category{},
+		ACLs:       []acl.ACL{},
+		Ops:        []op.Operation{},
+		Indices:    []string{},
+		Includes:   []string{},
+		Excludes:   []string{},
+		GrantedBy:  make(map[Grant][]string),
+	}
+}
+
+// add records the grant, it returns false if the grant was already present.
+func (g *Grants) add(grant Grant, role string) bool {
+	roles, exists := g.GrantedBy[grant]
+	for _, r := range roles {
+		if r == role {
+			return false
+		}
+	}
+	g.GrantedBy[grant] = append(roles, role)
+	return !exists
+}
+
+func (g *Grants) merge(r *Role) {
+	for _, c := range r.Categories {
+		if g.add(Grant{GrantCategory, c.String()}, r.Name) {
+			g.Categories = append(g.Categories, c)
+		}
+	}
+	for _, a := range r.ACLs {
+		if g.add(Grant{GrantACL, a.String()}, r.Name) {
+			g.ACLs = append(g.ACLs, a)
+		}
+	}
+	for _, o := range r.Ops {
+		if g.add(Grant{GrantOp, o.String()}, r.Name) {
+			g.Ops = append(g.Ops, o)
+		}
+	}
+	for _, index := range r.Indices {
+		if g.add(Grant{GrantIndex, index}, r.Name) {
+			g.Indices = append(g.Indices, index)
+		}
+	}
+	for _, field := range r.Includes {
+		if g.add(Grant{GrantInclude, field}, r.Name) {
+			g.Includes = append(g.Includes, field)
+		}
+	}
+	for _, field := range r.Excludes {
+		if g.add(Grant{GrantExclude, field}, r.Name) {
+			g.Excludes = append(g.Excludes, field)
+		}
+	}
+	if r.Limits != nil {
+		g.Limits = permission.MaxLimits(g.Limits, r.Limits)
+	}
+}
+
+// Resolve computes the effective grants of the roles by walking up their parents.
+func Resolve(ctx context.Context, lookup Lookup, names ...string) (*Grants, error) {
+	grants := newGrants()
+	visited := make(map[string]bool)
+	for _, name := range names {
+		if err := resolve(ctx, name, lookup, grants, visited, nil); err != nil {
+			return nil, err
+		}
+	}
+	return grants, nil
+}
+
+func resolve(ctx context.Context, name string, lookup Lookup, grants *Grants, visited map[string]bool, path []string) error {
+	for _, ancestor := range path {
+		if ancestor == name {
+			return fmt.Errorf("role inheritance cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+	}
+	if len(path) >= maxDepth {
+		return fmt.Errorf("role inheritance of %s is deeper than %d levels", path[0], maxDepth)
+	}
+	// a role inherited through more than one parent is merged only once
+	if visited[name] {
+		return nil
+	}
+	r, err := lookup(ctx, name)
+	if err != nil {
+		return fmt.Errorf(`unable to resolve role "%s": %v`, name, err)
+	}
+	grants.merge(r)
+	path = append(path, name)
+	for _, parent := range r.Inherits {
+		if err := resolve(ctx, parent, lookup, grants, visited, path); err != nil {
+			return err
+		}
+	}
+	visited[name] = true
+	return nil
+}
+
+// Explanation tells whether the grant is present and the roles that granted it.
+type Explanation struct {
+	Grant
+	Granted   bool     `json:"granted"`
+	GrantedBy []string `json:"granted_by"`
+}
+
+// Explain returns which roles grant the access, index patterns are matched
+// against the index name.
+func (g *Grants) Explain(grant Grant) Explanation {
+	explanation := Explanation{Grant: grant, GrantedBy: []string{}}
+	if grant.Type != GrantIndex {
+		explanation.GrantedBy = append(explanation.GrantedBy, g.GrantedBy[grant]...)
+		explanation.Granted = len(explanation.GrantedBy) > 0
+		return explanation
+	}
+	for _, pattern := range g.Indices {
+		if matcher.Globs([]string{pattern}).Match(grant.Value) {
+			explanation.GrantedBy = append(explanation.GrantedBy, g.GrantedBy[Grant{GrantIndex, pattern}]...)
+		}
+	}
+	explanation.Granted = len(explanation.GrantedBy) > 0
+	return explanation
+}
+
+// New returns a role with the timestamps set.
+func New(name string) *Role {
+	now := time.Now().Format(time.RFC3339)
+	return &Role{Name: name, CreatedAt: now, UpdatedAt: now}
+}
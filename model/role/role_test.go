@@ -0,0 +1,88 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func lookupFrom(roles ...*Role) Lookup {
+	return func(ctx context.Context, name string) (*Role, error) {
+		for _, r := range roles {
+			if r.Name == name {
+				return r, nil
+			}
+		}
+		return nil, errors.New("not found")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	base := &Role{
+		Name:       "base",
+		Categories: []category.Category{category.Docs},
+		ACLs:       []acl.ACL{acl.Get},
+		Indices:    []string{"logs-*"},
+		Limits:     &permission.Limits{IPLimit: 10},
+	}
+	search := &Role{
+		Name:       "search",
+		Inherits:   []string{"base"},
+		Categories: []category.Category{category.Search, category.Docs},
+		Limits:     &permission.Limits{IPLimit: 5, SearchLimit: 20},
+	}
+	admin := &Role{Name: "admin", Inherits: []string{"search", "base"}, Indices: []string{"*"}}
+	lookup := lookupFrom(base, search, admin)
+
+	Convey("should merge the grants of the ancestors", t, func() {
+		grants, err := Resolve(ctx, lookup, "admin")
+		So(err, ShouldBeNil)
+		So(grants.Categories, ShouldResemble, []category.Category{category.Search, category.Docs})
+		So(grants.Indices, ShouldResemble, []string{"*", "logs-*"})
+		So(grants.Limits.IPLimit, ShouldEqual, 10)
+		So(grants.Limits.SearchLimit, ShouldEqual, 20)
+	})
+	Convey("should explain which roles granted the access", t, func() {
+		grants, err := Resolve(ctx, lookup, "admin")
+		So(err, ShouldBeNil)
+		explanation := grants.Explain(Grant{GrantCategory, category.Docs.String()})
+		So(explanation.Granted, ShouldBeTrue)
+		So(explanation.GrantedBy, ShouldResemble, []string{"search", "base"})
+
+		explanation = grants.Explain(Grant{GrantIndex, "logs-2020"})
+		So(explanation.GrantedBy, ShouldResemble, []string{"admin", "base"})
+
+		// the "." of the index patterns isn't a wildcard
+		dotted := &Grants{Indices: []string{"logs.*"}, GrantedBy: map[Grant][]string{{GrantIndex, "logs.*"}: {"logs"}}}
+		So(dotted.Explain(Grant{GrantIndex, "logs.2020"}).Granted, ShouldBeTrue)
+		So(dotted.Explain(Grant{GrantIndex, "logs-2020"}).Granted, ShouldBeFalse)
+
+		explanation = grants.Explain(Grant{GrantACL, acl.Bulk.String()})
+		So(explanation.Granted, ShouldBeFalse)
+	})
+	Convey("should reject the cycles and the missing roles", t, func() {
+		a := &Role{Name: "a", Inherits: []string{"b"}}
+		b := &Role{Name: "b", Inherits: []string{"a"}}
+		_, err := Resolve(ctx, lookupFrom(a, b), "a")
+		So(err, ShouldNotBeNil)
+		_, err = Resolve(ctx, lookup, "missing")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should apply the grants to a copy of the permission", t, func() {
+		grants, err := Resolve(ctx, lookup, "search")
+		So(err, ShouldBeNil)
+		p := &permission.Permission{Categories: []category.Category{category.Docs}, Indices: []string{"movies"}}
+		applied := grants.ApplyToPermission(p)
+		So(applied.Categories, ShouldResemble, []category.Category{category.Docs, category.Search})
+		So(applied.Indices, ShouldResemble, []string{"movies", "logs-*"})
+		So(applied.Limits.SearchLimit, ShouldEqual, 20)
+		So(p.Indices, ShouldResemble, []string{"movies"})
+		So(p.Limits, ShouldBeNil)
+	})
+}
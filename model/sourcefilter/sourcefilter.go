@@ -5,52 +5,36 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 	"github.com/prometheus/common/log"
 )
 
 const logTag = "[sourcefiltering]"
 
-// To filter the source based on the include and exclude patterns
+// arrayIndex matches the array indices of the dot notated keys.
+var arrayIndex = regexp.MustCompile(`\.[0-9]+\.`)
+
+// To filter the source based on the include and exclude patterns, a pattern
+// matches the fields along with their sub fields and "*" matches any characters
 func ApplySourceFiltering(source map[string]interface{}, include []string, exclude []string) interface{} {
 	// Avoid calculation if source filters are not defined
 	if len(include) == 0 && len(exclude) == 0 {
 		return source
 	}
+	includes := matcher.Fields(include)
+	excludes := matcher.Fields(exclude)
 	var filteredSource = make(map[string]interface{})
 	// Convert map to dot notation
 	mapWithDotNotationKeys := dotNotate(source, nil, "")
 	for key, v := range mapWithDotNotationKeys {
-		isValidKey := true
 		// Remove numbers from key to match the pattern
 		// "a.0.b.1.c" would become "a.b.c"
 		// "a.0.b.1.c.2" would become "a.b.c.2"
-		re := regexp.MustCompile(`\.[0-9]+\.`)
-		keyToCompare := re.ReplaceAllString(key, ".")
-		// Check if key is valid
-		for _, pattern := range include {
-			// If pattern matches then avoid further iterations
-			pattern = strings.Replace(pattern, "*", ".*", -1)
-			matched, err := regexp.MatchString(pattern, keyToCompare)
-			if err != nil {
-				log.Errorln(logTag, ":", err)
-			} else if matched {
-				isValidKey = true
-				break
-			} else {
-				isValidKey = false
-			}
-		}
-
-		for _, pattern := range exclude {
-			// If pattern matches then avoid further iterations
-			pattern = strings.Replace(pattern, "*", ".*", -1)
-			matched, err := regexp.MatchString(pattern, keyToCompare)
-			if err != nil {
-				log.Errorln(logTag, ":", err)
-			} else if matched {
-				isValidKey = false
-				break
-			}
+		keyToCompare := arrayIndex.ReplaceAllString(key, ".")
+		// Check if key is valid, the excludes have priority over the includes
+		isValidKey := len(include) == 0 || includes.Match(keyToCompare)
+		if isValidKey && excludes.Match(keyToCompare) {
+			isValidKey = false
 		}
 		if isValidKey {
 			filteredSource[key] = v
@@ -145,6 +145,17 @@ func TestApplySourceFiltering(t *testing.T) {
 			},
 		})
 	})
+	Convey("Filter by a stored regex pattern", t, func() {
+		output := ApplySourceFiltering(map[string]interface{}{
+			"title":    "value1",
+			"subtitle": "value2",
+			"author":   "value3",
+		}, []string{"^(title|author)$"}, []string{})
+		So(output, ShouldResemble, map[string]interface{}{
+			"title":  "value1",
+			"author": "value3",
+		})
+	})
 }
 
 func TestDotNotate(t *testing.T) {
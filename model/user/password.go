@@ -0,0 +1,240 @@
+package user
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	envPasswordMinLength          = "PASSWORD_MIN_LENGTH"
+	envPasswordRequiredCharacters = "PASSWORD_REQUIRED_CHARACTERS"
+	envPasswordBreachedListFile   = "PASSWORD_BREACHED_LIST_FILE"
+	envPasswordMaxAge             = "PASSWORD_MAX_AGE"
+	envPasswordHistorySize        = "PASSWORD_HISTORY_SIZE"
+)
+
+// Character classes that can be required in a password.
+const (
+	Lowercase = "lowercase"
+	Uppercase = "uppercase"
+	Digit     = "digit"
+	Special   = "special"
+)
+
+// PasswordPolicy defines the requirements for the user passwords.
+type PasswordPolicy struct {
+	MinLength          int           `json:"min_length"`
+	RequiredCharacters []string      `json:"required_characters"`
+	BreachedListFile   string        `json:"breached_list_file,omitempty"`
+	MaxAge             time.Duration `json:"max_age"`
+	HistorySize        int           `json:"history_size"`
+}
+
+// GetPasswordPolicy returns the password policy configured with the env vars,
+// the default policy doesn't put any restriction on the passwords.
+func GetPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		BreachedListFile: os.Getenv(envPasswordBreachedListFile),
+	}
+	if value, err := strconv.Atoi(os.Getenv(envPasswordMinLength)); err == nil {
+		policy.MinLength = value
+	}
+	for _, class := range strings.Split(os.Getenv(envPasswordRequiredCharacters), ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			policy.RequiredCharacters = append(policy.RequiredCharacters, class)
+		}
+	}
+	if value, err := time.ParseDuration(os.Getenv(envPasswordMaxAge)); err == nil {
+		policy.MaxAge = value
+	}
+	if value, err := strconv.Atoi(os.Getenv(envPasswordHistorySize)); err == nil {
+		policy.HistorySize = value
+	}
+	return policy
+}
+
+// Validate checks the password against the policy.
+func (p PasswordPolicy) Validate(username, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if username != "" && strings.EqualFold(username, password) {
+		return fmt.Errorf("password must not be the same as the username")
+	}
+	for _, class := range p.RequiredCharacters {
+		var matches func(r rune) bool
+		switch class {
+		case Lowercase:
+			matches = unicode.IsLower
+		case Uppercase:
+			matches = unicode.IsUpper
+		case Digit:
+			matches = unicode.IsDigit
+		case Special:
+			matches = func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+			}
+		default:
+			return fmt.Errorf(`invalid character class "%s" in the password policy`, class)
+		}
+		if strings.IndexFunc(password, matches) == -1 {
+			return fmt.Errorf("password must contain at least one %s character", class)
+		}
+	}
+	if p.BreachedListFile != "" {
+		breached, err := breachedPasswords.load(p.BreachedListFile)
+		if err != nil {
+			return fmt.Errorf("unable to read the breached password list: %v", err)
+		}
+		if breached.contains(password) {
+			return fmt.Errorf("password has appeared in a data breach, choose a different one")
+		}
+	}
+	return nil
+}
+
+// breachedList holds the SHA-1 digests of the passwords from the list, the
+// list can have the plain passwords as well as the digests in the same format
+// as the Pwned Passwords dumps, i.e. `HASH:count`.
+type breachedList map[string]struct{}
+
+func sha1Hex(password string) string {
+	digest := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+func (b breachedList) contains(password string) bool {
+	_, ok := b[sha1Hex(password)]
+	return ok
+}
+
+type breachedListCache struct {
+	mu    sync.Mutex
+	lists map[string]breachedList
+}
+
+// breachedPasswords caches the breached password lists per file path
+var breachedPasswords = breachedListCache{
+	lists: make(map[string]breachedList),
+}
+
+func (c *breachedListCache) load(path string) (breachedList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if list, ok := c.lists[path]; ok {
+		return list, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(breachedList)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if digest := strings.SplitN(line, ":", 2)[0]; isSHA1Hex(digest) {
+			list[strings.ToUpper(digest)] = struct{}{}
+		} else {
+			list[sha1Hex(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	c.lists[path] = list
+	return list, nil
+}
+
+func isSHA1Hex(value string) bool {
+	if len(value) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+// PasswordChangePath returns the route that changes the password of the user,
+// it remains accessible to the user after the password has expired.
+func PasswordChangePath(username string) string {
+	return "/_user/" + username + "/password"
+}
+
+// IsPasswordExpired checks whether the user must change the password before
+// accessing anything else.
+func (u *User) IsPasswordExpired(maxAge time.Duration, now time.Time) bool {
+	if u.ForcePasswordChange != nil && *u.ForcePasswordChange {
+		return true
+	}
+	if maxAge <= 0 {
+		return false
+	}
+	changedAt := u.PasswordChangedAt
+	if changedAt == "" {
+		changedAt = u.CreatedAt
+	}
+	changed, err := time.Parse(time.RFC3339, changedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(changed) > maxAge
+}
+
+// IsPasswordReused checks whether the password matches the current or one of
+// the last historySize passwords of the user.
+func (u *User) IsPasswordReused(password string, historySize int) bool {
+	if historySize <= 0 {
+		return false
+	}
+	hashes := append([]string{u.Password}, u.PasswordHistory...)
+	for i, hash := range hashes {
+		if i > historySize {
+			break
+		}
+		if u.PasswordHashType == "" && i == 0 {
+			if hash == password {
+				return true
+			}
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPasswordPatch returns the patch that replaces the user password with the
+// hashed password, the current password is moved to the password history.
+func (u *User) GetPasswordPatch(hashedPassword string, historySize int, now time.Time) map[string]interface{} {
+	history := []string{}
+	if historySize > 0 && u.PasswordHashType != "" && u.Password != "" {
+		history = append(history, u.Password)
+		history = append(history, u.PasswordHistory...)
+		if len(history) > historySize {
+			history = history[:historySize]
+		}
+	}
+	return map[string]interface{}{
+		"password":              hashedPassword,
+		"password_hash_type":    "bcrypt",
+		"password_changed_at":   now.Format(time.RFC3339),
+		"password_history":      history,
+		"force_password_change": false,
+		"updated_at":            now.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	Convey("should enforce the length and the character classes", t, func() {
+		policy := PasswordPolicy{
+			MinLength:          8,
+			RequiredCharacters: []string{Lowercase, Uppercase, Digit, Special},
+		}
+		So(policy.Validate("foo", "Ab1!"), ShouldNotBeNil)
+		So(policy.Validate("foo", "abcdefg1!"), ShouldNotBeNil)
+		So(policy.Validate("foo", "Abcdefg1"), ShouldNotBeNil)
+		So(policy.Validate("foo", "Abcdefg1!"), ShouldBeNil)
+		So(PasswordPolicy{}.Validate("foo", "FOO"), ShouldNotBeNil)
+	})
+	Convey("should reject the breached passwords", t, func() {
+		file, err := ioutil.TempFile("", "breached")
+		So(err, ShouldBeNil)
+		defer os.Remove(file.Name())
+		// sha1("hunter2") in the pwned passwords format
+		file.WriteString("password123\nF3BBBD66A63D4BF1747940578EC3D0103530E21D:17\n")
+		file.Close()
+
+		policy := PasswordPolicy{BreachedListFile: file.Name()}
+		So(policy.Validate("foo", "password123"), ShouldNotBeNil)
+		So(policy.Validate("foo", "hunter2"), ShouldNotBeNil)
+		So(policy.Validate("foo", "correct horse"), ShouldBeNil)
+	})
+}
+
+func TestPasswordRotation(t *testing.T) {
+	now := time.Now()
+	Convey("should expire the password after the max age", t, func() {
+		u := User{CreatedAt: now.Add(-48 * time.Hour).Format(time.RFC3339)}
+		So(u.IsPasswordExpired(0, now), ShouldBeFalse)
+		So(u.IsPasswordExpired(24*time.Hour, now), ShouldBeTrue)
+
+		u.PasswordChangedAt = now.Add(-time.Hour).Format(time.RFC3339)
+		So(u.IsPasswordExpired(24*time.Hour, now), ShouldBeFalse)
+
+		force := true
+		u.ForcePasswordChange = &force
+		So(u.IsPasswordExpired(0, now), ShouldBeTrue)
+	})
+	Convey("should keep the history and block the reuse", t, func() {
+		hash := func(password string) string {
+			hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+			return string(hashed)
+		}
+		u := User{Password: hash("second"), PasswordHashType: "bcrypt", PasswordHistory: []string{hash("first")}}
+		So(u.IsPasswordReused("second", 0), ShouldBeFalse)
+		So(u.IsPasswordReused("second", 1), ShouldBeTrue)
+		So(u.IsPasswordReused("first", 1), ShouldBeTrue)
+		So(u.IsPasswordReused("third", 1), ShouldBeFalse)
+
+		patch := u.GetPasswordPatch(hash("third"), 1, now)
+		So(patch["password_history"], ShouldResemble, []string{u.Password})
+		So(patch["force_password_change"], ShouldEqual, false)
+	})
+}
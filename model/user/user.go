@@ -4,14 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/appbaseio/reactivesearch-api/errors"
 	"github.com/appbaseio/reactivesearch-api/model/acl"
 	"github.com/appbaseio/reactivesearch-api/model/category"
-	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 )
 
 type contextKey string
@@ -41,6 +40,15 @@ type User struct {
 	UpdatedAt        string              `json:"updated_at"`
 	Sources          *[]string           `json:"sources"`
 	SourcesXffValue  *int                `json:"sources_xff_value"`
+	// Roles are the names of the roles the user inherits the grants of
+	Roles []string `json:"roles,omitempty"`
+	// Limits are the rate limits of the user per category, a zero limit doesn't limit the category
+	Limits *permission.Limits `json:"limits,omitempty"`
+	// PasswordChangedAt is the time of the last password change, used for the password expiry
+	PasswordChangedAt string `json:"password_changed_at,omitempty"`
+	// PasswordHistory holds the hashes of the previous passwords to prevent their reuse
+	PasswordHistory     []string `json:"password_history,omitempty"`
+	ForcePasswordChange *bool    `json:"force_password_change,omitempty"`
 }
 
 // Options is a function type used to define a user's properties.
@@ -128,6 +136,26 @@ func SetACLs(acls []acl.ACL) Options {
 	}
 }
 
+// SetLimits sets the rate limits of the user.
+func SetLimits(limits *permission.Limits) Options {
+	return func(u *User) error {
+		u.Limits = limits
+		return nil
+	}
+}
+
+// SetRoles sets the roles the user inherits the grants of, exists reports
+// whether a role is defined.
+func SetRoles(roles []string, exists func(name string) (bool, error)) Options {
+	return func(u *User) error {
+		if err := permission.ValidateRoles(roles, exists); err != nil {
+			return err
+		}
+		u.Roles = roles
+		return nil
+	}
+}
+
 // SetEmail sets the user email.
 func SetEmail(email string) Options {
 	return func(u *User) error {
@@ -142,12 +170,7 @@ func SetIndices(indices []string) Options {
 		if indices == nil {
 			return errors.ErrNilIndices
 		}
-		for _, pattern := range indices {
-			pattern = strings.Replace(pattern, "*", ".*", -1)
-			if _, err := regexp.Compile(pattern); err != nil {
-				return err
-			}
-		}
+		matcher.Indices(indices)
 		u.Indices = indices
 		return nil
 	}
@@ -286,33 +309,33 @@ func (u *User) HasACL(acl acl.ACL) bool {
 	return false
 }
 
+// CompileMatchers compiles the sources and the index patterns of the user
+// ahead of its requests.
+func (u *User) CompileMatchers() error {
+	matcher.Indices(u.Indices)
+	if u.Sources == nil {
+		return nil
+	}
+	_, err := matcher.CIDRs(*u.Sources)
+	return err
+}
+
+// ForgetMatchers drops the compiled patterns of the user, e.g. once it's patched.
+func (u *User) ForgetMatchers() {
+	matcher.Forget(u.Indices)
+	if u.Sources != nil {
+		matcher.Forget(*u.Sources)
+	}
+}
+
 // CanAccessCluster checks whether the user can access cluster level routes.
 func (u *User) CanAccessCluster() (bool, error) {
-	for _, pattern := range u.Indices {
-		pattern = strings.Replace(pattern, "*", ".*", -1)
-		matched, err := regexp.MatchString(pattern, "*")
-		if err != nil {
-			return false, err
-		}
-		if matched {
-			return true, nil
-		}
-	}
-	return false, nil
+	return matcher.Indices(u.Indices).Match("*"), nil
 }
 
 // CanAccessIndex checks whether the user has access to the given index or index pattern.
 func (u *User) CanAccessIndex(name string) (bool, error) {
-	for _, pattern := range u.Indices {
-		matched, err := util.ValidateIndex(pattern, name)
-		if err != nil {
-			return false, err
-		}
-		if matched {
-			return true, nil
-		}
-	}
-	return false, nil
+	return matcher.Indices(u.Indices).Match(name), nil
 }
 
 // CanAccessIndices checks whether the user has access to the given indices.
@@ -367,9 +390,24 @@ func (u *User) GetPatch() (map[string]interface{}, error) {
 	if u.Indices != nil {
 		patch["indices"] = u.Indices
 	}
+	if u.Roles != nil {
+		patch["roles"] = u.Roles
+	}
+	if u.Limits != nil {
+		patch["limits"] = u.Limits
+	}
+	if u.ForcePasswordChange != nil {
+		patch["force_password_change"] = *u.ForcePasswordChange
+	}
 	if u.CreatedAt != "" {
 		return nil, errors.NewUnsupportedPatchError("user", "created_at")
 	}
+	if u.PasswordChangedAt != "" {
+		return nil, errors.NewUnsupportedPatchError("user", "password_changed_at")
+	}
+	if u.PasswordHistory != nil {
+		return nil, errors.NewUnsupportedPatchError("user", "password_history")
+	}
 
 	return patch, nil
 }
@@ -11,24 +11,21 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/plugins"
 	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/dgrijalva/jwt-go"
 )
 
 const (
-	logTag                    = "[auth]"
-	envUsersEsIndex           = "USERS_ES_INDEX"
-	defaultUsersEsIndex       = ".users"
-	envEsURL                  = "ES_CLUSTER_URL"
-	envPermissionsEsIndex     = "PERMISSIONS_ES_INDEX"
-	defaultPermissionsEsIndex = ".permissions"
-	envPublicKeyEsIndex       = "PUBLIC_KEY_ES_INDEX"
-	defaultPublicKeyEsIndex   = ".publickey"
-	envJwtRsaPublicKeyLoc     = "JWT_RSA_PUBLIC_KEY_LOC"
-	envJwtRoleKey             = "JWT_ROLE_KEY"
-	settings                  = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
-	publicKeyDocID            = "_public_key"
+	logTag                  = "[auth]"
+	envEsURL                = "ES_CLUSTER_URL"
+	envPublicKeyEsIndex     = "PUBLIC_KEY_ES_INDEX"
+	defaultPublicKeyEsIndex = ".publickey"
+	envJwtRsaPublicKeyLoc   = "JWT_RSA_PUBLIC_KEY_LOC"
+	envJwtRoleKey           = "JWT_ROLE_KEY"
+	settings                = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
+	publicKeyDocID          = "_public_key"
 )
 
 var (
@@ -74,14 +71,6 @@ func (a *Auth) Name() string {
 // only once in the lifetime of the plugin.
 func (a *Auth) InitFunc() error {
 	// fetch vars from env
-	userIndex := os.Getenv(envUsersEsIndex)
-	if userIndex == "" {
-		userIndex = defaultUsersEsIndex
-	}
-	permissionIndex := os.Getenv(envPermissionsEsIndex)
-	if permissionIndex == "" {
-		permissionIndex = defaultPermissionsEsIndex
-	}
 	publicKeyIndex := os.Getenv(envPublicKeyEsIndex)
 	if publicKeyIndex == "" {
 		publicKeyIndex = defaultPublicKeyEsIndex
@@ -89,7 +78,7 @@ func (a *Auth) InitFunc() error {
 	var err error
 
 	// initialize the dao
-	a.es, err = initPlugin(userIndex, permissionIndex)
+	a.es, err = initPlugin()
 	if err != nil {
 		return err
 	}
@@ -97,7 +86,12 @@ func (a *Auth) InitFunc() error {
 	// Create public key index
 	_, err = a.es.createIndex(publicKeyIndex, settings)
 	if err != nil {
-		return err
+		// the credentials don't depend on the cluster with the embedded store,
+		// only the JWT auth remains unavailable
+		if !credentialstore.IsEmbedded() {
+			return err
+		}
+		log.Errorln(logTag, ":", err)
 	}
 
 	// Populate public key from ES
@@ -9,14 +9,15 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/util"
 )
 
 type elasticsearch struct {
-	userIndex, userType             string
-	permissionIndex, permissionType string
+	store credentialstore.CredentialStore
 }
 
 type publicKey struct {
@@ -24,15 +25,15 @@ type publicKey struct {
 	RoleKey   string `json:"role_key"`
 }
 
-func initPlugin(userIndex, permissionIndex string) (*elasticsearch, error) {
-	// auth only has to establish a connection to es, users, permissions
-	// plugin handles the creation of their respective meta indices
-	es := &elasticsearch{
-		userIndex, "_doc",
-		permissionIndex, "_doc",
+func initPlugin() (*elasticsearch, error) {
+	// auth only has to fetch the credential store, users, permissions
+	// plugin handles the initialization of the store
+	store, err := credentialstore.Instance()
+	if err != nil {
+		return nil, err
 	}
 
-	return es, nil
+	return &elasticsearch{store}, nil
 }
 
 func (es *elasticsearch) createIndex(indexName, mapping string) (bool, error) {
@@ -97,22 +98,11 @@ func (es *elasticsearch) getPublicKey(ctx context.Context) (publicKey, error) {
 }
 
 func (es *elasticsearch) getCredential(ctx context.Context, username string) (credential.AuthCredential, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getCredentialEs6(ctx, username)
-	default:
-		return es.getCredentialEs7(ctx, username)
-	}
+	return es.store.GetCredential(ctx, username)
 }
 
 func (es *elasticsearch) putUser(ctx context.Context, u user.User) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Index(es.userIndex).
-		Type(es.userType).
-		Id(u.Username).
-		BodyJson(u).
-		Do(ctx)
-	if err != nil {
+	if err := es.store.PutUser(ctx, u); err != nil {
 		return false, err
 	}
 
@@ -133,31 +123,11 @@ func (es *elasticsearch) getUser(ctx context.Context, username string) (*user.Us
 }
 
 func (es *elasticsearch) getRawUser(ctx context.Context, username string) ([]byte, error) {
-	data, err := util.GetClient7().Get().
-		Index(es.userIndex).
-		Type(es.userType).
-		Id(username).
-		FetchSource(true).
-		Do(ctx)
-	if err != nil {
-		return nil, err
-	}
-	src, err := data.Source.MarshalJSON()
-	if err != nil {
-		return nil, err
-	}
-
-	return src, nil
+	return es.store.GetRawUser(ctx, username)
 }
 
 func (es *elasticsearch) putPermission(ctx context.Context, p permission.Permission) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Index(es.permissionIndex).
-		Type(es.permissionType).
-		Id(p.Username).
-		BodyJson(p).
-		Do(ctx)
-	if err != nil {
+	if err := es.store.PutPermission(ctx, p); err != nil {
 		return false, err
 	}
 
@@ -180,26 +150,11 @@ func (es *elasticsearch) getPermission(ctx context.Context, username string) (*p
 }
 
 func (es *elasticsearch) getRawPermission(ctx context.Context, username string) ([]byte, error) {
-	resp, err := util.GetClient7().Get().
-		Index(es.permissionIndex).
-		Type(es.permissionType).
-		Id(username).
-		FetchSource(true).
-		Do(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	src, err := resp.Source.MarshalJSON()
-	if err != nil {
-		return nil, err
-	}
-
-	return src, nil
+	return es.store.GetRawPermission(ctx, username)
 }
 
 func (es *elasticsearch) getRolePermission(ctx context.Context, role string) (*permission.Permission, error) {
-	data, err := es.getRawRolePermission(ctx, role)
+	data, err := es.store.GetRawRolePermission(ctx, role)
 	if err != nil {
 		return nil, err
 	}
@@ -213,11 +168,15 @@ func (es *elasticsearch) getRolePermission(ctx context.Context, role string) (*p
 	return &p, nil
 }
 
-func (es *elasticsearch) getRawRolePermission(ctx context.Context, role string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawRolePermissionEs6(ctx, role)
-	default:
-		return es.getRawRolePermissionEs7(ctx, role)
+func (es *elasticsearch) getRole(ctx context.Context, name string) (*role.Role, error) {
+	data, err := es.store.GetRawRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var r role.Role
+	err = json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, err
 	}
+	return &r, nil
 }
@@ -194,6 +194,7 @@ func TestRBAC(t *testing.T) {
 	var username string
 	var password string
 	var createdAt string
+	util.SkipWithoutES(t)
 	build := util.BuildArc{}
 	util.StartArc(&build)
 	build.Start()
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/plugins"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/gorilla/mux"
+)
+
+// explainRequest is a hypothetical request to authorize with the credential.
+type explainRequest struct {
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Headers  map[string]string `json:"headers"`
+	Body     json.RawMessage   `json:"body"`
+}
+
+// explainError is the error written back by a middleware that doesn't record
+// its checks, e.g. the authentication or the rate limits.
+type explainError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *Auth) explainAccess() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		var explainReq explainRequest
+		err = json.Unmarshal(body, &explainReq)
+		if err != nil {
+			msg := "can't parse request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if explainReq.Method == "" {
+			explainReq.Method = http.MethodGet
+		}
+		if !strings.HasPrefix(explainReq.Path, "/") {
+			util.WriteBackError(w, `"path" must start with a "/"`, http.StatusBadRequest)
+			return
+		}
+
+		var reqBody []byte
+		if len(explainReq.Body) > 0 {
+			// a string body is sent as is, e.g. the ndjson of _msearch and _bulk
+			var text string
+			if json.Unmarshal(explainReq.Body, &text) == nil {
+				reqBody = []byte(text)
+			} else {
+				reqBody = explainReq.Body
+			}
+		}
+
+		// the dry-run request doesn't inherit the context of the explain request
+		report := explain.NewReport()
+		ctx := explain.NewContext(context.Background(), report)
+		dryRun, err := http.NewRequestWithContext(ctx, strings.ToUpper(explainReq.Method), explainReq.Path, bytes.NewReader(reqBody))
+		if err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dryRun.RequestURI = explainReq.Path
+		dryRun.RemoteAddr = req.RemoteAddr
+		for key, value := range explainReq.Headers {
+			dryRun.Header.Set(key, value)
+		}
+		if explainReq.Username != "" {
+			dryRun.SetBasicAuth(explainReq.Username, explainReq.Password)
+		}
+		dryRun.Header.Set("Content-Type", "application/json")
+		dryRun.Header.Set("X-Enable-Telemetry", "false")
+
+		router := plugins.RouterSwapperInstance().Router()
+		var match mux.RouteMatch
+		if router == nil || !router.Match(dryRun, &match) || match.Route == nil {
+			msg := fmt.Sprintf("no route matches %s %s", dryRun.Method, explainReq.Path)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+		if template, err := match.Route.GetPathTemplate(); err == nil {
+			report.Route = template
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, dryRun)
+
+		// a middleware that doesn't record its checks writes back an error
+		if !report.Allowed && (rec.Code != http.StatusOK || rec.Body.Len() > 0) {
+			var writtenErr explainError
+			msg := rec.Body.String()
+			if json.Unmarshal(rec.Body.Bytes(), &writtenErr) == nil && writtenErr.Error.Message != "" {
+				msg = writtenErr.Error.Message
+			}
+			report.Add(explain.Check{
+				Name:    "request",
+				Passed:  false,
+				Message: fmt.Sprintf("%d %s", rec.Code, msg),
+			})
+		}
+
+		raw, err := json.Marshal(report)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while marshalling the report", http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -160,3 +161,81 @@ func getJWTPublickKey(record publicKey) (*rsa.PublicKey, error) {
 	}
 	return nil, errors.New("public key is missing in the request body")
 }
+
+func (a *Auth) getLockouts() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		now := time.Now()
+		lockouts := failedLogins.list(now)
+		if req.URL.Query().Get("locked") == "true" {
+			active := make([]Lockout, 0)
+			for _, l := range lockouts {
+				if l.isLocked(now) {
+					active = append(active, l)
+				}
+			}
+			lockouts = active
+		}
+		raw, err := json.Marshal(lockouts)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while marshalling lockouts", http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (a *Auth) clearLockouts() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		username := query.Get("username")
+		ip := query.Get("ip")
+		// Only update the local state when proxy API has not been called,
+		// the proxy API clears the state for all the machines.
+		if query.Get("local") != "true" && util.ShouldProxyToACCAPI() {
+			proxyURL := "/_auth/lockouts"
+			if encoded := query.Encode(); encoded != "" {
+				proxyURL += "?" + encoded
+			}
+			res, err := util.ProxyACCAPI(util.ProxyConfig{
+				Method: http.MethodDelete,
+				URL:    proxyURL,
+			})
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Failed to update all nodes, return error response
+			if res != nil {
+				log.Errorln(logTag, ":", "error encountered while clearing lockouts")
+				bodyBytes, err := ioutil.ReadAll(res.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				util.WriteBackRaw(w, bodyBytes, res.StatusCode)
+				return
+			}
+		} else {
+			clearLocalLockouts(username, ip)
+		}
+		util.WriteBackMessage(w, "Lockouts cleared successfully.", http.StatusOK)
+	}
+}
+
+// clearLocalLockouts clears the lockouts of the username and the ip, all the
+// lockouts are cleared if both are empty.
+func clearLocalLockouts(username, ip string) {
+	if username == "" && ip == "" {
+		failedLogins.clear()
+		return
+	}
+	if username != "" {
+		failedLogins.reset(lockoutKeyUsername, username)
+	}
+	if ip != "" {
+		failedLogins.reset(lockoutKeyIP, ip)
+	}
+}
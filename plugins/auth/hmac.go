@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderSignatureKeyID carries the signing key identifier in the form `<username>:<key_id>`.
+	HeaderSignatureKeyID = "X-Signature-Key-Id"
+	// HeaderSignatureTimestamp carries the unix time (in seconds) at which the request was signed.
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	// HeaderSignature carries the hex encoded HMAC-SHA256 signature of the request.
+	HeaderSignature = "X-Signature"
+
+	envSignatureMaxSkew     = "SIGNATURE_MAX_CLOCK_SKEW"
+	defaultSignatureMaxSkew = 5 * time.Minute
+)
+
+// signedRequest represents the signature related values extracted from a request.
+type signedRequest struct {
+	username  string
+	keyID     string
+	timestamp int64
+	signature string
+}
+
+// signatureCache keeps track of the signatures seen within the clock-skew
+// window, so that a captured request can't be replayed.
+type signatureCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var usedSignatures = signatureCache{
+	seen: make(map[string]time.Time),
+}
+
+// hasSignature checks whether the request has been signed with HMAC.
+func hasSignature(req *http.Request) bool {
+	return req.Header.Get(HeaderSignatureKeyID) != ""
+}
+
+// parseSignedRequest extracts the signature headers from the request.
+func parseSignedRequest(req *http.Request) (*signedRequest, error) {
+	keyID := req.Header.Get(HeaderSignatureKeyID)
+	parts := strings.SplitN(keyID, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf(`"%s" header must be of the form "<username>:<key_id>"`, HeaderSignatureKeyID)
+	}
+	rawTimestamp := req.Header.Get(HeaderSignatureTimestamp)
+	if rawTimestamp == "" {
+		return nil, fmt.Errorf(`"%s" header is required for signed requests`, HeaderSignatureTimestamp)
+	}
+	timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf(`"%s" header must be a unix timestamp in seconds`, HeaderSignatureTimestamp)
+	}
+	signature := req.Header.Get(HeaderSignature)
+	if signature == "" {
+		return nil, fmt.Errorf(`"%s" header is required for signed requests`, HeaderSignature)
+	}
+	return &signedRequest{
+		username:  parts[0],
+		keyID:     parts[1],
+		timestamp: timestamp,
+		signature: strings.ToLower(signature),
+	}, nil
+}
+
+// canonicalQuery returns the query string with keys and values sorted.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalRequest builds the string that is signed by the client. Each of
+// the components is separated by a new line:
+//
+//	METHOD
+//	/path
+//	sorted query string
+//	timestamp
+//	hex(sha256(body))
+func canonicalRequest(method, path string, query url.Values, timestamp int64, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		canonicalQuery(query),
+		strconv.FormatInt(timestamp, 10),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// computeSignature returns the hex encoded HMAC-SHA256 of the message.
+func computeSignature(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// getSignatureMaxSkew returns the allowed difference between the signing time and now.
+func getSignatureMaxSkew() time.Duration {
+	skew := os.Getenv(envSignatureMaxSkew)
+	if skew == "" {
+		return defaultSignatureMaxSkew
+	}
+	d, err := time.ParseDuration(skew)
+	if err != nil || d <= 0 {
+		return defaultSignatureMaxSkew
+	}
+	return d
+}
+
+// verify validates the signature of the request against the given secret. The
+// request body is read and restored so that it can be consumed by the handlers.
+func (s *signedRequest) verify(req *http.Request, secret string, now time.Time) error {
+	maxSkew := getSignatureMaxSkew()
+	signedAt := time.Unix(s.timestamp, 0)
+	if signedAt.Before(now.Add(-maxSkew)) || signedAt.After(now.Add(maxSkew)) {
+		return fmt.Errorf("request signature has expired, timestamp is outside of the allowed window of %s", maxSkew)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("can't read request body: %v", err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := computeSignature(secret, canonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.Query(), s.timestamp, body))
+	if !hmac.Equal([]byte(expected), []byte(s.signature)) {
+		return fmt.Errorf("invalid request signature")
+	}
+
+	if !usedSignatures.add(s.keyID+":"+s.signature, now, maxSkew) {
+		return fmt.Errorf("request signature has already been used")
+	}
+	return nil
+}
+
+// add records the signature and returns false if it was seen before within the window.
+func (c *signatureCache) add(signature string, now time.Time, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, seenAt := range c.seen {
+		if now.Sub(seenAt) > 2*window {
+			delete(c.seen, k)
+		}
+	}
+	if _, ok := c.seen[signature]; ok {
+		return false
+	}
+	c.seen[signature] = now
+	return true
+}
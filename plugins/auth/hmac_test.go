@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func signRequest(method, target, body, keyID, secret string, at time.Time) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	message := canonicalRequest(method, req.URL.EscapedPath(), req.URL.Query(), at.Unix(), []byte(body))
+	req.Header.Set(HeaderSignatureKeyID, keyID)
+	req.Header.Set(HeaderSignatureTimestamp, strconv.FormatInt(at.Unix(), 10))
+	req.Header.Set(HeaderSignature, computeSignature(secret, message))
+	return req
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	Convey("should sort keys and values", t, func() {
+		query := url.Values{
+			"size": []string{"10"},
+			"from": []string{"5"},
+			"q":    []string{"b c", "a"},
+		}
+		So(canonicalQuery(query), ShouldEqual, "from=5&q=a&q=b+c&size=10")
+	})
+	Convey("should be empty without query", t, func() {
+		So(canonicalQuery(url.Values{}), ShouldEqual, "")
+	})
+}
+
+func TestSignedRequest(t *testing.T) {
+	secret := "0123456789abcdef"
+	Convey("should verify a valid signature", t, func() {
+		now := time.Now()
+		req := signRequest(http.MethodPost, "/test/_search?b=2&a=1", `{"query":{"match_all":{}}}`, "user:key1", secret, now)
+		signed, err := parseSignedRequest(req)
+		So(err, ShouldBeNil)
+		So(signed.username, ShouldEqual, "user")
+		So(signed.keyID, ShouldEqual, "key1")
+		So(signed.verify(req, secret, now), ShouldBeNil)
+
+		// body must be restored for the handlers
+		body, _ := ioutil.ReadAll(req.Body)
+		So(string(body), ShouldEqual, `{"query":{"match_all":{}}}`)
+
+		// same signature can't be used twice
+		req.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		So(signed.verify(req, secret, now), ShouldNotBeNil)
+	})
+	Convey("should reject a tampered body", t, func() {
+		now := time.Now()
+		req := signRequest(http.MethodPost, "/test/_doc", `{"a":1}`, "user:key1", secret, now)
+		req.Body = ioutil.NopCloser(strings.NewReader(`{"a":2}`))
+		signed, _ := parseSignedRequest(req)
+		So(signed.verify(req, secret, now), ShouldNotBeNil)
+	})
+	Convey("should reject a timestamp outside of the skew window", t, func() {
+		now := time.Now()
+		req := signRequest(http.MethodGet, "/test/_search", "", "user:key1", secret, now.Add(-time.Hour))
+		signed, _ := parseSignedRequest(req)
+		So(signed.verify(req, secret, now), ShouldNotBeNil)
+	})
+	Convey("should reject a malformed key id", t, func() {
+		req := signRequest(http.MethodGet, "/", "", "key1", secret, time.Now())
+		_, err := parseSignedRequest(req)
+		So(err, ShouldNotBeNil)
+	})
+}
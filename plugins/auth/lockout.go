@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/authevent"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+)
+
+const (
+	envLockoutThreshold       = "AUTH_LOCKOUT_THRESHOLD"
+	envLockoutIPThreshold     = "AUTH_LOCKOUT_IP_THRESHOLD"
+	envLockoutDuration        = "AUTH_LOCKOUT_DURATION"
+	envLockoutMaxDuration     = "AUTH_LOCKOUT_MAX_DURATION"
+	defaultLockoutThreshold   = 5
+	defaultLockoutIPThreshold = 20
+	defaultLockoutDuration    = time.Minute
+	defaultLockoutMaxDuration = time.Hour
+
+	lockoutKeyUsername = "username"
+	lockoutKeyIP       = "ip"
+
+	// maxTrackedLockouts is the number of records after which the expired ones get pruned,
+	// the least recent ones are evicted if the records still exceed it
+	maxTrackedLockouts = 10000
+)
+
+// Lockout represents the failed login attempts recorded against a username or an IP.
+type Lockout struct {
+	Type        string     `json:"type"`
+	Value       string     `json:"value"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+func (l *Lockout) isLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+type lockoutPolicy struct {
+	threshold   int
+	duration    time.Duration
+	maxDuration time.Duration
+}
+
+// lockDuration returns the lockout duration for the given number of failures,
+// the duration doubles for each failure after the threshold is reached.
+func (p lockoutPolicy) lockDuration(failures int) time.Duration {
+	if p.threshold <= 0 || failures < p.threshold {
+		return 0
+	}
+	exp := failures - p.threshold
+	if exp > 30 {
+		return p.maxDuration
+	}
+	d := time.Duration(float64(p.duration) * math.Pow(2, float64(exp)))
+	if d > p.maxDuration {
+		return p.maxDuration
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getLockoutPolicy returns the policy for the given key type. A threshold of 0
+// disables the lockout for that key type.
+func getLockoutPolicy(keyType string) lockoutPolicy {
+	threshold := getEnvInt(envLockoutThreshold, defaultLockoutThreshold)
+	if keyType == lockoutKeyIP {
+		threshold = getEnvInt(envLockoutIPThreshold, defaultLockoutIPThreshold)
+	}
+	return lockoutPolicy{
+		threshold:   threshold,
+		duration:    getEnvDuration(envLockoutDuration, defaultLockoutDuration),
+		maxDuration: getEnvDuration(envLockoutMaxDuration, defaultLockoutMaxDuration),
+	}
+}
+
+type lockoutTracker struct {
+	mu       sync.Mutex
+	lockouts map[string]*Lockout
+}
+
+// failedLogins tracks the failed login attempts per username and per source IP
+var failedLogins = lockoutTracker{
+	lockouts: make(map[string]*Lockout),
+}
+
+func lockoutKey(keyType, value string) string {
+	return keyType + ":" + value
+}
+
+// lockedUntil returns the time until which the username or the IP is locked out.
+func (t *lockoutTracker) lockedUntil(username, ip string, now time.Time) *time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var until *time.Time
+	for _, key := range []string{lockoutKey(lockoutKeyUsername, username), lockoutKey(lockoutKeyIP, ip)} {
+		if l, ok := t.lockouts[key]; ok && l.isLocked(now) {
+			if until == nil || l.LockedUntil.After(*until) {
+				until = l.LockedUntil
+			}
+		}
+	}
+	return until
+}
+
+// recordFailure increments the failures for the given key and returns the
+// updated record along with whether the failure caused a new lockout.
+func (t *lockoutTracker) recordFailure(keyType, value string, now time.Time) (Lockout, bool) {
+	policy := getLockoutPolicy(keyType)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.lockouts) >= maxTrackedLockouts {
+		t.prune(now)
+		t.evict(now, maxTrackedLockouts*9/10)
+	}
+	key := lockoutKey(keyType, value)
+	l, ok := t.lockouts[key]
+	// forget the earlier failures once the max lockout duration has passed
+	if !ok || now.Sub(l.LastFailure) > policy.maxDuration {
+		l = &Lockout{Type: keyType, Value: value}
+		t.lockouts[key] = l
+	}
+	l.Failures++
+	l.LastFailure = now
+	var locked bool
+	if d := policy.lockDuration(l.Failures); d > 0 {
+		until := now.Add(d)
+		l.LockedUntil = &until
+		locked = true
+	}
+	return *l, locked
+}
+
+// reset clears the failures recorded for the given key.
+func (t *lockoutTracker) reset(keyType, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.lockouts, lockoutKey(keyType, value))
+}
+
+// clear removes all the recorded failures.
+func (t *lockoutTracker) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lockouts = make(map[string]*Lockout)
+}
+
+// prune drops the records which are neither locked nor relevant anymore,
+// the caller must hold the lock.
+func (t *lockoutTracker) prune(now time.Time) {
+	for key, l := range t.lockouts {
+		if !l.isLocked(now) && now.Sub(l.LastFailure) > getLockoutPolicy(l.Type).maxDuration {
+			delete(t.lockouts, key)
+		}
+	}
+}
+
+// evict drops the least recent records until at most size of them are left,
+// the records which aren't locked are evicted first. The caller must hold the lock.
+func (t *lockoutTracker) evict(now time.Time, size int) {
+	if len(t.lockouts) <= size {
+		return
+	}
+	keys := make([]string, 0, len(t.lockouts))
+	for key := range t.lockouts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := t.lockouts[keys[i]], t.lockouts[keys[j]]
+		if a.isLocked(now) != b.isLocked(now) {
+			return !a.isLocked(now)
+		}
+		return a.LastFailure.Before(b.LastFailure)
+	})
+	for _, key := range keys[:len(keys)-size] {
+		delete(t.lockouts, key)
+	}
+}
+
+// list returns the recorded failures, the expired ones are dropped.
+func (t *lockoutTracker) list(now time.Time) []Lockout {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	result := make([]Lockout, 0, len(t.lockouts))
+	for _, l := range t.lockouts {
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastFailure.After(result[j].LastFailure)
+	})
+	return result
+}
+
+// onLoginFailure records a failed login attempt against the username and the
+// source IP, the lockouts caused by it are recorded as auth events.
+func onLoginFailure(ctx context.Context, username, ip string) {
+	// the explained requests count as well, otherwise they could be used
+	// to guess the passwords without ever getting locked out
+	now := time.Now()
+	authevent.Record(ctx, authevent.Event{
+		Type:     authevent.LoginFailed,
+		Username: username,
+		IP:       ip,
+	})
+	for _, key := range [][2]string{{lockoutKeyUsername, username}, {lockoutKeyIP, ip}} {
+		if key[1] == "" {
+			continue
+		}
+		l, locked := failedLogins.recordFailure(key[0], key[1], now)
+		if !locked {
+			continue
+		}
+		log.Warnln(logTag, ": locked out", key[0], key[1], "until", l.LockedUntil.Format(time.RFC3339), "after", l.Failures, "failed attempts")
+		event := authevent.Event{
+			Type:        authevent.LockedOut,
+			Failures:    l.Failures,
+			LockedUntil: l.LockedUntil,
+		}
+		if key[0] == lockoutKeyUsername {
+			event.Username = username
+		} else {
+			event.IP = ip
+		}
+		authevent.Record(ctx, event)
+	}
+}
+
+// onLoginSuccess clears the failed login attempts of the username. The
+// failures of the source IP are kept until their window ends, so that a valid
+// credential can't be used to reset them between the guesses of the others.
+func onLoginSuccess(username string) {
+	failedLogins.reset(lockoutKeyUsername, username)
+}
+
+// checkLockout writes back an error response if the username or the source IP
+// is locked out, it returns false in that case.
+func checkLockout(w http.ResponseWriter, req *http.Request, username, ip string) bool {
+	until := failedLogins.lockedUntil(username, ip, time.Now())
+	if until == nil {
+		return true
+	}
+	authevent.Record(req.Context(), authevent.Event{
+		Type:        authevent.Rejected,
+		Username:    username,
+		IP:          ip,
+		LockedUntil: until,
+	})
+	retryAfter := int(math.Ceil(time.Until(*until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	msg := fmt.Sprintf("too many failed login attempts, try again after %d seconds", retryAfter)
+	telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusTooManyRequests)
+	return false
+}
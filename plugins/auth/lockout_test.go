@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLockDuration(t *testing.T) {
+	policy := lockoutPolicy{threshold: 3, duration: time.Minute, maxDuration: 10 * time.Minute}
+	Convey("should not lock before the threshold", t, func() {
+		So(policy.lockDuration(2), ShouldEqual, 0)
+	})
+	Convey("should double the duration after the threshold", t, func() {
+		So(policy.lockDuration(3), ShouldEqual, time.Minute)
+		So(policy.lockDuration(4), ShouldEqual, 2*time.Minute)
+		So(policy.lockDuration(5), ShouldEqual, 4*time.Minute)
+	})
+	Convey("should not exceed the max duration", t, func() {
+		So(policy.lockDuration(10), ShouldEqual, 10*time.Minute)
+		So(policy.lockDuration(100), ShouldEqual, 10*time.Minute)
+	})
+	Convey("should be disabled with zero threshold", t, func() {
+		So(lockoutPolicy{threshold: 0, duration: time.Minute, maxDuration: time.Hour}.lockDuration(100), ShouldEqual, 0)
+	})
+}
+
+func TestLockoutTracker(t *testing.T) {
+	Convey("should lock out a username after the threshold", t, func() {
+		tracker := lockoutTracker{lockouts: make(map[string]*Lockout)}
+		now := time.Now()
+		for i := 0; i < defaultLockoutThreshold-1; i++ {
+			_, locked := tracker.recordFailure(lockoutKeyUsername, "foo", now)
+			So(locked, ShouldBeFalse)
+		}
+		So(tracker.lockedUntil("foo", "1.1.1.1", now), ShouldBeNil)
+		_, locked := tracker.recordFailure(lockoutKeyUsername, "foo", now)
+		So(locked, ShouldBeTrue)
+		So(tracker.lockedUntil("foo", "1.1.1.1", now), ShouldNotBeNil)
+		So(tracker.lockedUntil("bar", "1.1.1.1", now), ShouldBeNil)
+		So(tracker.lockedUntil("foo", "1.1.1.1", now.Add(defaultLockoutDuration)), ShouldBeNil)
+
+		tracker.reset(lockoutKeyUsername, "foo")
+		So(tracker.lockedUntil("foo", "1.1.1.1", now), ShouldBeNil)
+		So(tracker.list(now), ShouldBeEmpty)
+	})
+	Convey("should keep the failures of the IP after a successful login", t, func() {
+		now := time.Now()
+		for i := 0; i < defaultLockoutIPThreshold-1; i++ {
+			failedLogins.recordFailure(lockoutKeyIP, "2.2.2.2", now)
+		}
+		onLoginSuccess("valid")
+		_, locked := failedLogins.recordFailure(lockoutKeyIP, "2.2.2.2", now)
+		So(locked, ShouldBeTrue)
+		failedLogins.reset(lockoutKeyIP, "2.2.2.2")
+	})
+	Convey("should evict the least recent records beyond the cap", t, func() {
+		tracker := lockoutTracker{lockouts: make(map[string]*Lockout)}
+		now := time.Now()
+		for i := 0; i < defaultLockoutThreshold; i++ {
+			tracker.recordFailure(lockoutKeyUsername, "locked", now)
+		}
+		for i := 0; i < maxTrackedLockouts; i++ {
+			tracker.recordFailure(lockoutKeyIP, fmt.Sprint(i), now.Add(time.Duration(i)*time.Millisecond))
+		}
+		So(len(tracker.lockouts), ShouldBeLessThanOrEqualTo, maxTrackedLockouts)
+		So(tracker.lockedUntil("locked", "", now), ShouldNotBeNil)
+		So(tracker.lockouts[lockoutKey(lockoutKeyIP, "0")], ShouldBeNil)
+		So(tracker.lockouts[lockoutKey(lockoutKeyIP, fmt.Sprint(maxTrackedLockouts-1))], ShouldNotBeNil)
+	})
+}
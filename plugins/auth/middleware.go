@@ -2,23 +2,28 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
+	"github.com/appbaseio/reactivesearch-api/model/authevent"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/model/op"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/trackplugin"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/gorilla/mux"
@@ -92,6 +97,38 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 		}
 
 		username, password, hasBasicAuth := req.BasicAuth()
+
+		// signed requests carry the username in the signature key id
+		var signed *signedRequest
+		isSigned := !hasBasicAuth && hasSignature(req)
+		if isSigned {
+			signed, err = parseSignedRequest(req)
+			if err != nil {
+				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			username = signed.username
+		}
+
+		// scoped tokens minted from a permission carry the username in the claims
+		var scopedToken string
+		isScoped := false
+		if !hasBasicAuth && !isSigned {
+			if token, err := request.AuthorizationHeaderExtractor.ExtractToken(req); err == nil {
+				if tokenUsername, ok := permission.IsScopedToken(token); ok {
+					scopedToken, isScoped = token, true
+					username = tokenUsername
+				}
+			}
+		}
+
+		// reject the requests from locked out usernames or source IPs early
+		clientIP := iplookup.FromRequest(req)
+		if (hasBasicAuth || isSigned || isScoped) && !checkLockout(w, req, username, clientIP) {
+			return
+		}
+
 		jwtToken, err := request.ParseFromRequest(req, request.AuthorizationHeaderExtractor, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
@@ -101,7 +138,7 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			}
 			return a.jwtRsaPublicKey, nil
 		})
-		if !hasBasicAuth && err != nil {
+		if !hasBasicAuth && !isSigned && !isScoped && err != nil {
 			var msg string
 			if err == request.ErrNoTokenInRequest {
 				msg = "Basic Auth or JWT is required"
@@ -114,8 +151,13 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 		}
 
 		role := ""
-		if !hasBasicAuth {
+		// subject of the JWT, the {{user}} of the document filter
+		var jwtSubject string
+		if !hasBasicAuth && !isSigned && !isScoped {
 			if claims, ok := jwtToken.Claims.(jwt.MapClaims); ok && jwtToken.Valid {
+				if sub, ok := claims["sub"].(string); ok {
+					jwtSubject = sub
+				}
 				if a.jwtRoleKey != "" && claims[a.jwtRoleKey] != nil {
 					role = claims[a.jwtRoleKey].(string)
 				} else if u, ok := claims["role"]; ok {
@@ -147,12 +189,24 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			if err != nil || obj == nil {
 				msg := fmt.Sprintf("No API credentials match with provided username: %s", username)
 				log.Warnln(logTag, ":", err)
+				onLoginFailure(ctx, username, clientIP)
 				w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 				telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
 				return
 			}
 		}
 
+		// the cache holds the stored credential, the grants of the roles are
+		// applied to a copy so that the role changes take effect immediately
+		stored := obj
+		obj, err = a.applyRoles(ctx, stored)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+			telemetry.WriteBackErrorWithTelemetry(req, w, "unable to resolve the roles of the credential", http.StatusUnauthorized)
+			return
+		}
+
 		var authenticated bool
 		var errorMsg = "invalid credentials provided"
 
@@ -164,18 +218,36 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 
 				reqUser := obj.(*user.User)
 
+				if isSigned || isScoped {
+					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+					telemetry.WriteBackErrorWithTelemetry(req, w, "signed requests and scoped tokens are only supported for API credentials", http.StatusUnauthorized)
+					return
+				}
+
 				// track `user` middleware
 				ctx := trackplugin.TrackPlugin(ctx, "au")
 				req = req.WithContext(ctx)
 
 				// No need to validate if already validated before
 				if hasBasicAuth && !IsPasswordExist(reqUser.Username, password) && bcrypt.CompareHashAndPassword([]byte(reqUser.Password), []byte(password)) != nil {
+					onLoginFailure(ctx, username, clientIP)
 					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 					telemetry.WriteBackErrorWithTelemetry(req, w, "invalid password", http.StatusUnauthorized)
 					return
 				}
-				// Save validated username to avoid the bcrypt comparison
-				SavePassword(reqUser.Username, password)
+				if hasBasicAuth {
+					onLoginSuccess(username)
+					// Save validated username to avoid the bcrypt comparison
+					SavePassword(reqUser.Username, password)
+				}
+
+				// an expired password must be changed before accessing anything else
+				if reqUser.IsPasswordExpired(user.GetPasswordPolicy().MaxAge, time.Now()) &&
+					!(req.Method == http.MethodPatch && req.URL.Path == user.PasswordChangePath(reqUser.Username)) {
+					msg := fmt.Sprintf("password has expired, change it with PATCH %s", user.PasswordChangePath(reqUser.Username))
+					telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusForbidden)
+					return
+				}
 
 				// ignore es auth for root route to fetch the cluster details
 				if (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.RequestURI == "/" {
@@ -205,7 +277,7 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 
 				// cache the user
 				if _, ok := GetCachedCredential(username); !ok {
-					SaveCredentialToCache(username, reqUser)
+					SaveCredentialToCache(username, stored)
 				}
 
 				// store request user and credential identifier in the context
@@ -220,11 +292,42 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 				req = req.WithContext(ctx)
 
 				reqPermission := obj.(*permission.Permission)
-				if hasBasicAuth && reqPermission.Password != password {
+				if hasBasicAuth && subtle.ConstantTimeCompare([]byte(reqPermission.Password), []byte(password)) != 1 {
+					onLoginFailure(ctx, username, clientIP)
 					w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
 					telemetry.WriteBackErrorWithTelemetry(req, w, "invalid password", http.StatusUnauthorized)
 					return
 				}
+				if isSigned {
+					secret, ok := reqPermission.GetSigningSecret(signed.keyID)
+					if !ok {
+						onLoginFailure(ctx, username, clientIP)
+						w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+						telemetry.WriteBackErrorWithTelemetry(req, w, fmt.Sprintf("No signing key matches with provided key id: %s", signed.keyID), http.StatusUnauthorized)
+						return
+					}
+					if err := signed.verify(req, secret, time.Now()); err != nil {
+						log.Warnln(logTag, ":", err)
+						onLoginFailure(ctx, username, clientIP)
+						w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+						telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusUnauthorized)
+						return
+					}
+				}
+				var scope *permission.ScopedTokenClaims
+				if isScoped {
+					scope, err = reqPermission.ParseScopedToken(scopedToken)
+					if err != nil {
+						log.Warnln(logTag, ":", err)
+						onLoginFailure(ctx, username, clientIP)
+						w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+						telemetry.WriteBackErrorWithTelemetry(req, w, fmt.Sprintf("Invalid scoped token: %v", err), http.StatusUnauthorized)
+						return
+					}
+				}
+				if hasBasicAuth || isSigned || isScoped {
+					onLoginSuccess(username)
+				}
 				// ignore es auth for root route to fetch the cluster details
 				if req.Method == http.MethodGet && req.RequestURI == "/" {
 					authenticated = true
@@ -237,7 +340,15 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 
 				// cache the permission
 				if _, ok := GetCachedCredential(username); !ok {
-					SaveCredentialToCache(username, reqPermission)
+					SaveCredentialToCache(username, stored)
+				}
+
+				// a scoped token gets a narrowed copy of the cached permission
+				if scope != nil {
+					reqPermission = reqPermission.Narrow(scope.TokenScope)
+				}
+				if jwtSubject != "" && len(reqPermission.Filter) > 0 {
+					reqPermission = reqPermission.WithFilterUser(jwtSubject)
 				}
 
 				// store the request permission and credential identifier in the context
@@ -261,6 +372,8 @@ func (a *Auth) basicAuth(h http.HandlerFunc) http.HandlerFunc {
 			RemoveCredentialFromCache(username)
 		}
 
+		explain.Record(req.Context(), "auth", true, "credential is authenticated")
+		authevent.SetUsername(req.Context(), username)
 		h(w, req)
 	}
 }
@@ -307,6 +420,12 @@ func SaveCredentialToCache(username string, c credential.AuthCredential) {
 		log.Println(logTag, ": cannot cache 'nil' credential, skipping...")
 		return
 	}
+	// compile the patterns of the credential ahead of its requests
+	if compiled, ok := c.(compiledCredential); ok {
+		if err := compiled.CompileMatchers(); err != nil {
+			log.Errorln(logTag, ": error while compiling the patterns of", username, ":", err)
+		}
+	}
 	CredentialCache.mu.Lock()
 	CredentialCache.cache[username] = c
 	CredentialCache.mu.Unlock()
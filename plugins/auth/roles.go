@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/appbaseio/reactivesearch-api/model/credential"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+)
+
+// roleCache holds the effective grants of the role sets referenced by the credentials
+var roleCache = role.NewCache()
+
+// ClearLocalRoles removes the resolved grants of the roles on the current node,
+// it must be called whenever a role is created, updated or deleted.
+func ClearLocalRoles() {
+	roleCache.Clear()
+}
+
+// ResolveRoles returns the effective grants of the roles.
+func (a *Auth) ResolveRoles(ctx context.Context, names ...string) (*role.Grants, error) {
+	return roleCache.Resolve(ctx, a.es.getRole, names...)
+}
+
+// applyRoles returns a copy of the credential with the grants of its roles,
+// the credential itself is returned as is when it doesn't reference a role.
+func (a *Auth) applyRoles(ctx context.Context, obj credential.AuthCredential) (credential.AuthCredential, error) {
+	switch c := obj.(type) {
+	case *user.User:
+		if len(c.Roles) == 0 {
+			return obj, nil
+		}
+		grants, err := a.ResolveRoles(ctx, c.Roles...)
+		if err != nil {
+			return nil, err
+		}
+		return grants.ApplyToUser(c), nil
+	case *permission.Permission:
+		if len(c.Roles) == 0 {
+			return obj, nil
+		}
+		grants, err := a.ResolveRoles(ctx, c.Roles...)
+		if err != nil {
+			return nil, err
+		}
+		return grants.ApplyToPermission(c), nil
+	}
+	return obj, nil
+}
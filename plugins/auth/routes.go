@@ -23,6 +23,27 @@ func (a *Auth) routes() []plugins.Route {
 			HandlerFunc: middleware(a.setPublicKey()),
 			Description: "Create or Update the public key",
 		},
+		{
+			Name:        "Get lockouts",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_auth/lockouts",
+			HandlerFunc: middleware(a.getLockouts()),
+			Description: "Returns the failed login attempts and the active lockouts",
+		},
+		{
+			Name:        "Clear lockouts",
+			Methods:     []string{http.MethodDelete},
+			Path:        "/_auth/lockouts",
+			HandlerFunc: middleware(a.clearLockouts()),
+			Description: "Clears the lockouts for a username or an IP, or all of them if none is specified",
+		},
+		{
+			Name:        "Explain access",
+			Methods:     []string{http.MethodPost},
+			Path:        "/_auth/explain",
+			HandlerFunc: middleware(a.explainAccess()),
+			Description: "Dry-runs the authorization of a request with a credential and reports the outcome of each check",
+		},
 	}
 	return routes
 }
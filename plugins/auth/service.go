@@ -5,6 +5,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/model/credential"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 )
 
@@ -17,6 +18,7 @@ type authService interface {
 	getPermission(ctx context.Context, username string) (*permission.Permission, error)
 	getRawPermission(ctx context.Context, username string) ([]byte, error)
 	getRolePermission(ctx context.Context, role string) (*permission.Permission, error)
+	getRole(ctx context.Context, name string) (*role.Role, error)
 	createIndex(indexName, mapping string) (bool, error)
 	savePublicKey(ctx context.Context, indexName string, record publicKey) (interface{}, error)
 	getPublicKey(ctx context.Context) (publicKey, error)
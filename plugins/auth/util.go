@@ -1,19 +1,22 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"sync"
 )
 
-// UserToPasswordCache represents a map of bcrypt validated users
-var UserToPasswordCache = make(map[string]string)
+// UserToPasswordCache represents a map of bcrypt validated users, the value
+// is a sha256 digest of the validated password and never the plaintext.
+var UserToPasswordCache = make(map[string][sha256.Size]byte)
 
 // CurrentProcessMutex to stop concurrent writes on map
 var CurrentProcessMutex = sync.RWMutex{}
 
-// SavePassword saved the password in the cache
+// SavePassword saved the password digest in the cache
 func SavePassword(username string, password string) {
 	CurrentProcessMutex.Lock()
-	UserToPasswordCache[username] = password
+	UserToPasswordCache[username] = sha256.Sum256([]byte(password))
 	CurrentProcessMutex.Unlock()
 }
 
@@ -32,11 +35,24 @@ func IsPasswordExist(username string, password string) bool {
 	if !ok {
 		return false
 	}
-	return cachedPassword == password
+	digest := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(cachedPassword[:], digest[:]) == 1
+}
+
+// compiledCredential is a credential with patterns compiled ahead of its requests.
+type compiledCredential interface {
+	CompileMatchers() error
+	ForgetMatchers()
 }
 
 // deletes the user record from local state
 func ClearLocalUser(username string) {
+	// Clear the compiled patterns of the stale record
+	if c, ok := GetCachedCredential(username); ok {
+		if compiled, ok := c.(compiledCredential); ok {
+			compiled.ForgetMatchers()
+		}
+	}
 	// Clear username record from the cache
 	ClearPassword(username)
 	// Clear user record from the user cache
@@ -21,6 +21,7 @@ type NodeResponse struct {
 }
 
 func TestElasticsearch(t *testing.T) {
+	util.SkipWithoutES(t)
 	build := util.BuildArc{}
 	util.StartArc(&build)
 	build.Start()
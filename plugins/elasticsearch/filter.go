@@ -0,0 +1,117 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// unfilteredACLs are the acls that read documents without a query, the
+// permissions with a document filter are not allowed to access them.
+var unfilteredACLs = []acl.ACL{
+	acl.Get,
+	acl.Mget,
+	acl.Source,
+	acl.Explain,
+	acl.Termvectors,
+	acl.Mtermvectors,
+}
+
+// filterDocuments applies the filter clauses of the permission to the search
+// and the by query requests, so that only the matching documents can be
+// retrieved, updated or deleted.
+func filterDocuments(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil {
+			h(w, req)
+			return
+		}
+		clauses := reqPermission.GetFilterClauses()
+		if len(clauses) == 0 {
+			h(w, req)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error occurred while applying the document filter", http.StatusInternalServerError)
+			return
+		}
+		for _, a := range unfilteredACLs {
+			if *reqACL == a {
+				msg := fmt.Sprintf(`credential with a document filter is not allowed to access "%s" acl`, a)
+				telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+				return
+			}
+		}
+		isMsearch := *reqACL == acl.Msearch
+		isSearch := *reqACL == acl.Search || *reqACL == acl.Count ||
+			*reqACL == acl.DeleteByQuery || *reqACL == acl.UpdateByQuery
+		// the query of a search template is rendered by elasticsearch, so
+		// the filter can't be applied to it
+		if (isSearch || isMsearch) && isTemplateRequest(req) {
+			telemetry.WriteBackErrorWithTelemetry(req, w, "search templates are not allowed for a credential with a document filter", http.StatusUnauthorized)
+			return
+		}
+		// scroll requests continue the search context created with the filter
+		if (!isSearch && !isMsearch) || isScrollRequest(req) {
+			h(w, req)
+			return
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var modifiedBody []byte
+		if isMsearch {
+			modifiedBodyString, err := util.ApplyFilterClausesToMsearch(string(body), clauses)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			modifiedBody = []byte(modifiedBodyString)
+		} else {
+			reqBody := make(map[string]interface{})
+			if len(bytes.TrimSpace(body)) > 0 {
+				if err := json.Unmarshal(body, &reqBody); err != nil {
+					log.Errorln(logTag, ":", err)
+					telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			// query string searches can't be combined with the filter
+			if req.URL.Query().Get("q") != "" {
+				telemetry.WriteBackErrorWithTelemetry(req, w, `"q" parameter is not allowed for a credential with a document filter`, http.StatusBadRequest)
+				return
+			}
+			filteredBody, err := util.ApplyFilterClauses(reqBody, clauses)
+			if err != nil {
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			modifiedBody, err = json.Marshal(filteredBody)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(modifiedBody))
+		req.ContentLength = int64(len(modifiedBody))
+		h(w, req)
+	}
+}
@@ -0,0 +1,59 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIsScrollRequest(t *testing.T) {
+	router := mux.NewRouter()
+	var scroll bool
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		scroll = isScrollRequest(req)
+	}
+	router.HandleFunc("/_search/scroll", handler)
+	router.HandleFunc("/_search/scroll/{scroll_id}", handler)
+	router.HandleFunc("/{index}/_search", handler)
+
+	Convey("should match the scroll routes by their templates", t, func() {
+		for path, expected := range map[string]bool{
+			"/_search/scroll":       true,
+			"/_search/scroll/abc":   true,
+			"/scroll/_search":       false,
+			"/scroll-logs/_search":  false,
+			"/books_scroll/_search": false,
+		} {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+			So(scroll, ShouldEqual, expected)
+		}
+	})
+}
+
+func TestIsTemplateRequest(t *testing.T) {
+	router := mux.NewRouter()
+	var template bool
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		template = isTemplateRequest(req)
+	}
+	router.HandleFunc("/_search/template", handler)
+	router.HandleFunc("/{index}/_search/template", handler)
+	router.HandleFunc("/{index}/_msearch/template", handler)
+	router.HandleFunc("/{index}/_search", handler)
+
+	Convey("should match the search template routes by their templates", t, func() {
+		for path, expected := range map[string]bool{
+			"/_search/template":        true,
+			"/books/_search/template":  true,
+			"/books/_msearch/template": true,
+			"/template/_search":        false,
+			"/books_template/_search":  false,
+		} {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+			So(template, ShouldEqual, expected)
+		}
+	})
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/middleware/guard"
 	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
 	"github.com/appbaseio/reactivesearch-api/model/acl"
@@ -45,6 +46,7 @@ func (c *chain) Wrap(mw []middleware.Middleware, h http.HandlerFunc) http.Handle
 
 func list() []middleware.Middleware {
 	return []middleware.Middleware{
+		guard.Body(),
 		classifyCategory,
 		classifyACL,
 		classifyOp,
@@ -59,6 +61,9 @@ func list() []middleware.Middleware {
 		validate.ACL(),
 		validate.Operation(),
 		validate.PermissionExpiry(),
+		guard.Permission(),
+		filterDocuments,
+		protectFields,
 		intercept,
 	}
 }
@@ -140,6 +145,34 @@ func shouldApplyFilters(reqPermission *permission.Permission) bool {
 	return !isEmpty && (!isDefaultInclude || isExcludesPresent)
 }
 
+// isScrollRequest checks whether the request matched one of the
+// /_search/scroll routes, which continue an existing search context.
+func isScrollRequest(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	return template == "/_search/scroll" || strings.HasPrefix(template, "/_search/scroll/")
+}
+
+// isTemplateRequest checks whether the request matched one of the
+// /_search/template or /_msearch/template routes.
+func isTemplateRequest(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(template, "/_search/template") || strings.HasSuffix(template, "/_msearch/template")
+}
+
 func intercept(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
@@ -149,7 +182,7 @@ func intercept(h http.HandlerFunc) http.HandlerFunc {
 		}
 		isMsearch := *reqACL == acl.Msearch
 		isSearch := *reqACL == acl.Search
-		if (isSearch || isMsearch) && !strings.Contains(req.URL.Path, "/scroll") {
+		if (isSearch || isMsearch) && !isScrollRequest(req) {
 			// Apply source filters
 			// /_search/scroll is a special case that doesn't support source filtering
 			reqPermission, err := permission.FromContext(ctx)
@@ -0,0 +1,331 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/gorilla/mux"
+)
+
+// unprotectedWriteACLs are the acls that write documents without a body the
+// protected fields can be checked against, e.g. the scripts of update by query.
+var unprotectedWriteACLs = []acl.ACL{
+	acl.UpdateByQuery,
+	acl.Reindex,
+}
+
+// protectFields checks the documents written with a permission against its
+// write include and exclude fields, the writes to the protected fields are
+// either rejected or stripped.
+func protectFields(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil || !reqPermission.HasWriteFilter() {
+			h(w, req)
+			return
+		}
+		reqOp, err := op.FromContext(ctx)
+		if err != nil || *reqOp != op.Write {
+			h(w, req)
+			return
+		}
+		reqACL, err := acl.FromContext(ctx)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error occurred while checking the write fields", http.StatusInternalServerError)
+			return
+		}
+		for _, a := range unprotectedWriteACLs {
+			if *reqACL == a {
+				msg := fmt.Sprintf(`credential with write protected fields is not allowed to access "%s" acl`, a)
+				denyWrite(h, w, req, msg)
+				return
+			}
+		}
+
+		switch *reqACL {
+		case acl.Index, acl.Create, acl.Doc:
+			protectDocument(h, w, req, reqPermission, false)
+		case acl.Update:
+			protectDocument(h, w, req, reqPermission, true)
+		case acl.Bulk:
+			protectBulk(h, w, req, reqPermission)
+		default:
+			h(w, req)
+		}
+	}
+}
+
+// denyWrite rejects the write, an explained request records the failure instead.
+func denyWrite(h http.HandlerFunc, w http.ResponseWriter, req *http.Request, msg string) {
+	if explain.IsDryRun(req.Context()) {
+		explain.Record(req.Context(), "write_fields", false, msg)
+		h(w, req)
+		return
+	}
+	telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusForbidden)
+}
+
+func protectedFieldsMessage(fields []string) string {
+	return fmt.Sprintf("credential is not allowed to write the fields: %s", strings.Join(fields, ", "))
+}
+
+// checkSource checks the document source of an index request or the source of
+// an update request, i.e. its partial doc and upsert. It returns the protected
+// fields, they are removed from the source if the permission strips them.
+func checkSource(p *permission.Permission, source map[string]interface{}, isUpdate bool) ([]string, error) {
+	if !isUpdate {
+		if p.StripsProtectedFields() {
+			return p.StripProtectedFields(source), nil
+		}
+		return p.ProtectedFields(source), nil
+	}
+	if _, ok := source["script"]; ok {
+		return nil, fmt.Errorf("credential with write protected fields is not allowed to update with a script")
+	}
+	var fields []string
+	for _, key := range []string{"doc", "upsert"} {
+		doc, ok := source[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if p.StripsProtectedFields() {
+			fields = append(fields, p.StripProtectedFields(doc)...)
+		} else {
+			fields = append(fields, p.ProtectedFields(doc)...)
+		}
+	}
+	return fields, nil
+}
+
+func protectDocument(h http.HandlerFunc, w http.ResponseWriter, req *http.Request, p *permission.Permission, isUpdate bool) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var source map[string]interface{}
+	if err := json.Unmarshal(body, &source); err != nil {
+		telemetry.WriteBackErrorWithTelemetry(req, w, "can't parse request body", http.StatusBadRequest)
+		return
+	}
+	fields, err := checkSource(p, source, isUpdate)
+	if err != nil {
+		denyWrite(h, w, req, err.Error())
+		return
+	}
+	if len(fields) > 0 && !p.StripsProtectedFields() {
+		denyWrite(h, w, req, protectedFieldsMessage(fields))
+		return
+	}
+	if len(fields) > 0 {
+		body, err = json.Marshal(source)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Debugln(logTag, ": stripped the protected fields", fields)
+	}
+	explain.Record(req.Context(), "write_fields", true, "")
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	h(w, req)
+}
+
+// bulkItem is an action of a bulk request along with its source, if any.
+type bulkItem struct {
+	action string
+	meta   map[string]interface{}
+	lines  []string
+	// rejection is the reason the item isn't forwarded to elasticsearch
+	rejection string
+}
+
+func parseBulk(body string) ([]bulkItem, error) {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	var items []bulkItem
+	for i := 0; i < len(lines); i++ {
+		var header map[string]map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &header); err != nil || len(header) != 1 {
+			return nil, fmt.Errorf("malformed bulk action at line %d", i+1)
+		}
+		item := bulkItem{lines: []string{lines[i]}}
+		for action, meta := range header {
+			item.action = action
+			item.meta = meta
+		}
+		if item.action != "delete" {
+			if i+1 >= len(lines) {
+				return nil, fmt.Errorf("bulk action at line %d is missing the source", i+1)
+			}
+			i++
+			item.lines = append(item.lines, lines[i])
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func protectBulk(h http.HandlerFunc, w http.ResponseWriter, req *http.Request, p *permission.Permission) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items, err := parseBulk(string(body))
+	if err != nil {
+		telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var forwarded []string
+	rejected := 0
+	for i := range items {
+		item := &items[i]
+		if len(item.lines) < 2 {
+			forwarded = append(forwarded, item.lines...)
+			continue
+		}
+		var source map[string]interface{}
+		if err := json.Unmarshal([]byte(item.lines[1]), &source); err != nil {
+			item.rejection = "can't parse the source of the bulk item"
+			rejected++
+			continue
+		}
+		fields, err := checkSource(p, source, item.action == "update")
+		if err != nil {
+			item.rejection = err.Error()
+			rejected++
+			continue
+		}
+		if len(fields) > 0 && !p.StripsProtectedFields() {
+			item.rejection = protectedFieldsMessage(fields)
+			rejected++
+			continue
+		}
+		if len(fields) > 0 {
+			raw, err := json.Marshal(source)
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			item.lines[1] = string(raw)
+		}
+		forwarded = append(forwarded, item.lines...)
+	}
+
+	if explain.IsDryRun(req.Context()) {
+		msg := ""
+		if rejected > 0 {
+			msg = fmt.Sprintf("%d of %d bulk items write protected fields", rejected, len(items))
+		}
+		explain.Record(req.Context(), "write_fields", rejected == 0, msg)
+		h(w, req)
+		return
+	}
+	if rejected == 0 {
+		forwardedBody := []byte(strings.Join(forwarded, "\n") + "\n")
+		req.Body = ioutil.NopCloser(bytes.NewReader(forwardedBody))
+		req.ContentLength = int64(len(forwardedBody))
+		h(w, req)
+		return
+	}
+
+	// the response of elasticsearch only holds the forwarded items, the
+	// rejected items are put back at their position with an error
+	var esItems []json.RawMessage
+	response := map[string]json.RawMessage{"took": json.RawMessage("0")}
+	if len(forwarded) > 0 {
+		forwardedBody := []byte(strings.Join(forwarded, "\n") + "\n")
+		req.Body = ioutil.NopCloser(bytes.NewReader(forwardedBody))
+		req.ContentLength = int64(len(forwardedBody))
+		resp := httptest.NewRecorder()
+		h(resp, req)
+		if resp.Code != http.StatusOK {
+			for k, v := range resp.Header() {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(resp.Code)
+			w.Write(resp.Body.Bytes())
+			return
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error un-marshalling bulk response", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(response["items"], &esItems); err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error un-marshalling bulk response", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	index := mux.Vars(req)["index"]
+	merged := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if item.rejection == "" {
+			if len(esItems) == 0 {
+				log.Errorln(logTag, ": bulk response has fewer items than forwarded")
+				telemetry.WriteBackErrorWithTelemetry(req, w, "error merging bulk response", http.StatusInternalServerError)
+				return
+			}
+			merged = append(merged, esItems[0])
+			esItems = esItems[1:]
+			continue
+		}
+		result := map[string]interface{}{
+			"_index": index,
+			"status": http.StatusForbidden,
+			"error": map[string]interface{}{
+				"type":   "security_exception",
+				"reason": item.rejection,
+			},
+		}
+		if itemIndex, ok := item.meta["_index"]; ok {
+			result["_index"] = itemIndex
+		}
+		if id, ok := item.meta["_id"]; ok {
+			result["_id"] = id
+		}
+		merged = append(merged, map[string]interface{}{item.action: result})
+	}
+	rawItems, err := json.Marshal(merged)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response["items"] = rawItems
+	response["errors"] = json.RawMessage("true")
+	raw, err := json.Marshal(response)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	util.WriteBackRaw(w, raw, http.StatusOK)
+}
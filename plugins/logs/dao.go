@@ -4,9 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
-	"sort"
-	"strings"
+	"os"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 
@@ -16,15 +15,41 @@ import (
 	es7 "github.com/olivere/elastic/v7"
 )
 
+// settingsIndexConfig is the config of the indices storing the settings of the
+// logs changed with the API.
+const settingsIndexConfig = `
+	{
+	  "settings": {
+		%s
+	    "index.number_of_shards": 1,
+	    "index.number_of_replicas": %d
+	  },
+	  "mappings": %s
+	}`
+
 type elasticsearch struct {
-	indexName string
+	indexName      string
+	samplingIndex  string
+	retentionIndex string
+	retentionMu    sync.Mutex
+	// lifecycle applies the retention policy
+	lifecycle        string
+	appliedRetention *RetentionPolicy
 }
 
 func initPlugin(alias, config string) (*elasticsearch, error) {
 
 	ctx := context.Background()
 
-	var es = &elasticsearch{alias}
+	retentionIndex := os.Getenv(envRetentionEsIndex)
+	if retentionIndex == "" {
+		retentionIndex = defaultRetentionEsIndex
+	}
+	samplingIndex := os.Getenv(envSamplingEsIndex)
+	if samplingIndex == "" {
+		samplingIndex = defaultSamplingEsIndex
+	}
+	var es = &elasticsearch{indexName: alias, samplingIndex: samplingIndex, retentionIndex: retentionIndex}
 
 	// Check if alias exists instead of index and create first index if not exists with `${alias}-000001`
 	res, err := util.GetClient7().Aliases().Do(ctx)
@@ -39,6 +64,16 @@ func initPlugin(alias, config string) (*elasticsearch, error) {
 
 	if exists {
 		log.Println(logTag, ": index named", alias, "already exists, skipping ...")
+		// the fields added to the records since the index got created
+		if err := es.putRecordMapping(ctx); err != nil {
+			log.Warnln(logTag, ": error while updating the mappings of", alias, ":", err)
+		}
+		if err := es.initSampling(ctx); err != nil {
+			return nil, err
+		}
+		if err := es.initRetention(ctx); err != nil {
+			return nil, err
+		}
 		return es, nil
 	}
 
@@ -101,28 +136,90 @@ func initPlugin(alias, config string) (*elasticsearch, error) {
 	classify.SetIndexAlias(indexName, alias)
 	classify.SetAliasIndex(alias, indexName)
 
-	rolloverConditions := make(map[string]interface{})
-
-	rolloverConfiguration := fmt.Sprintf(rolloverConfig, "7d", 10000, "1gb")
-	if util.IsProductionPlan() {
-		rolloverConfiguration = fmt.Sprintf(rolloverConfig, "30d", 1000000, "10gb")
+	if err := es.initSampling(ctx); err != nil {
+		return nil, err
+	}
+	if err := es.initRetention(ctx); err != nil {
+		return nil, err
 	}
-	json.Unmarshal([]byte(rolloverConfiguration), &rolloverConditions)
 	return es, nil
 }
 
-func (es *elasticsearch) indexRecord(ctx context.Context, rec record) {
-	bulkIndex := es7.NewBulkIndexRequest().
-		Index(es.indexName).
-		Type("_doc").
-		Doc(rec)
+// createSettingsIndex creates the index of the settings if it doesn't exist,
+// the settings are stored as is without being indexed.
+func createSettingsIndex(ctx context.Context, name string) error {
+	exists, err := util.GetClient7().IndexExists(name).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while checking if index already exists: %v", err)
+	}
+	if exists {
+		return nil
+	}
+	mappings := `{"dynamic": false, "properties": {}}`
+	if util.GetVersion() == 6 {
+		mappings = fmt.Sprintf(`{"_doc": %s}`, mappings)
+	}
+	settings := fmt.Sprintf(settingsIndexConfig, util.HiddenIndexSettings(), util.GetReplicas(), mappings)
+	// this works for ES6 client as well
+	_, err = util.GetClient7().CreateIndex(name).Body(settings).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("error while creating index named \"%s\" %v", name, err)
+	}
+	log.Println(logTag, ": successfully created index name", name)
+	return nil
+}
+
+// recordMapping maps the username, the client IP and the id of the records.
+var recordMapping = map[string]interface{}{
+	"properties": map[string]interface{}{
+		"username": map[string]interface{}{
+			"type": "keyword",
+		},
+		"record_id": map[string]interface{}{
+			"type": "keyword",
+		},
+		"client_ip": map[string]interface{}{
+			"type":             "ip",
+			"ignore_malformed": true,
+		},
+	},
+}
 
-	_, err := util.GetClient7().Bulk().
-		Add(bulkIndex).
+func (es *elasticsearch) putRecordMapping(ctx context.Context) error {
+	switch util.GetVersion() {
+	case 6:
+		_, err := util.GetClient6().PutMapping().
+			Index(es.indexName).
+			Type("_doc").
+			BodyJson(recordMapping).
+			Do(ctx)
+		return err
+	}
+	_, err := util.GetClient7().PutMapping().
+		Index(es.indexName).
+		BodyJson(recordMapping).
 		Do(ctx)
+	return err
+}
+
+// indexRecords bulk indexes the marshalled records, it fails if any of them
+// can't be indexed.
+func (es *elasticsearch) indexRecords(batch [][]byte) error {
+	bulk := util.GetClient7().Bulk()
+	for _, rec := range batch {
+		bulk.Add(es7.NewBulkIndexRequest().
+			Index(es.indexName).
+			Type("_doc").
+			Doc(json.RawMessage(rec)))
+	}
+	res, err := bulk.Do(context.Background())
 	if err != nil {
-		log.Errorln(logTag, ": error indexing log record :", err)
+		return err
 	}
+	if failed := res.Failed(); len(failed) > 0 {
+		return fmt.Errorf("%d records failed to index: %v", len(failed), failed[0].Error)
+	}
+	return nil
 }
 
 type logsFilter struct {
@@ -135,6 +232,15 @@ type logsFilter struct {
 	Size           int
 	Filter         string
 	Indices        []string
+	StatusRanges   []statusRange
+	Categories     []string
+	Methods        []string
+	Usernames      []string
+	IPs            []string
+	// Text is matched against the uri and the body of the requests
+	Text        string
+	Sort        []logsSort
+	SearchAfter []interface{}
 }
 
 func (es *elasticsearch) getRawLogs(ctx context.Context, logsFilter logsFilter) ([]byte, error) {
@@ -149,78 +255,3 @@ func (es *elasticsearch) getRawLogs(ctx context.Context, logsFilter logsFilter)
 func (es *elasticsearch) getRawLog(ctx context.Context, ID string, parseDiffs bool) ([]byte, *LogError) {
 	return es.getRawLogES7(ctx, ID, parseDiffs)
 }
-
-func (es *elasticsearch) rolloverIndexJob(alias string) {
-	ctx := context.Background()
-	rolloverConditions := make(map[string]interface{})
-	rolloverConfiguration := fmt.Sprintf(rolloverConfig, "7d", 10000, "1gb")
-	if util.IsProductionPlan() {
-		rolloverConfiguration = fmt.Sprintf(rolloverConfig, "30d", 1000000, "10gb")
-	}
-	json.Unmarshal([]byte(rolloverConfiguration), &rolloverConditions)
-	settingsString := fmt.Sprintf(`{%s "index.number_of_shards": 1, "index.number_of_replicas": %d}`, util.HiddenIndexSettings(), util.GetReplicas())
-	settings := make(map[string]interface{})
-	json.Unmarshal([]byte(settingsString), &settings)
-
-	mappingString := LogsMappings
-	if util.GetVersion() == 6 {
-		mappingString = fmt.Sprintf(`{"_doc": %s}`, LogsMappings)
-	}
-
-	mappings := make(map[string]interface{})
-	json.Unmarshal([]byte(mappingString), &mappings)
-	rolloverService, err := es7.NewIndicesRolloverService(util.GetClient7()).
-		Alias(alias).
-		Conditions(rolloverConditions).
-		Settings(settings).
-		Mappings(mappings).
-		Do(ctx)
-	if err != nil {
-		log.Println(logTag, "error while creating a rollover service", alias, err)
-	}
-	log.Println(logTag, ": rollover res oldIndex", rolloverService.OldIndex)
-	log.Println(logTag, ": rollover res newIndex", rolloverService.NewIndex)
-	log.Println(logTag, ": rollover res isRolledover", rolloverService.RolledOver)
-
-	if rolloverService.RolledOver {
-		classify.SetIndexAlias(rolloverService.NewIndex, alias)
-		classify.SetAliasIndex(alias, rolloverService.NewIndex)
-	}
-
-	// We cannot rely on rollover service response here,
-	// Because it returns rollover as false when we restart ReactiveSearch.
-	// To preserve the last 2 index and delete others:
-	// -> cat all the indices with .logs-*
-	// -> if count is > 2
-	//   -> sort them based on -[Number]
-	//   -> preserve last 2 and delete all
-	// -> else do not delete any index
-
-	// cat all the indices starting with `${alias}-Number` pattern
-	indices, err := util.GetClient7().CatIndices().Index(alias + "-*").
-		Do(ctx)
-	if err != nil {
-		log.Errorln(logTag, ": rollover cronjob error getting indices", err)
-	}
-
-	if len(indices) > 2 {
-		rolloverIndices := []string{}
-		r, _ := regexp.Compile(fmt.Sprintf("%s-[0-9]+", alias))
-		for _, catResRow := range indices {
-			if r.MatchString(catResRow.Index) {
-				rolloverIndices = append(rolloverIndices, catResRow.Index)
-			}
-		}
-
-		sort.Strings(rolloverIndices)
-
-		// ignore last 2 indices
-		rolloverIndices = rolloverIndices[:len(rolloverIndices)-2]
-
-		log.Println(logTag, ": rollover cronjob, indices to delete", rolloverIndices)
-		_, err = util.GetClient7().DeleteIndex(strings.Join(rolloverIndices, ",")).Do(ctx)
-		if err != nil {
-			log.Errorln(logTag, ": rollover cronjob, error while deleting indices", err)
-		}
-	}
-}
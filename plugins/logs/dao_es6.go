@@ -57,19 +57,18 @@ func (es *elasticsearch) getRawLogsES6(ctx context.Context, logsFilter logsFilte
 		query.Filter(latencyRangeQuery)
 	}
 
+	applyStructuredFiltersES6(query, logsFilter)
+
 	searchQuery := util.GetClient6().Search(es.indexName).
 		Query(query).
 		From(logsFilter.Offset).
 		Size(logsFilter.Size)
-	if logsFilter.OrderByLatency != "" {
-		ascending := false
-		if logsFilter.OrderByLatency == "asc" {
-			ascending = true
-		}
-		// sort by latency
-		searchQuery.SortWithInfo(es6.SortInfo{Field: "response.took", UnmappedType: "int", Ascending: ascending})
+	for _, sort := range logsFilter.sortKeys() {
+		searchQuery.SortWithInfo(es6.SortInfo{Field: sort.Field, UnmappedType: sortUnmappedType(sort.Field), Ascending: sort.Ascending})
+	}
+	if len(logsFilter.SearchAfter) > 0 {
+		searchQuery.SearchAfter(logsFilter.SearchAfter...)
 	}
-	searchQuery.SortWithInfo(es6.SortInfo{Field: "timestamp", UnmappedType: "date", Ascending: false})
 	response, err := searchQuery.Do(ctx)
 
 	if err != nil {
@@ -77,7 +76,9 @@ func (es *elasticsearch) getRawLogsES6(ctx context.Context, logsFilter logsFilte
 	}
 
 	hits := []*json.RawMessage{}
+	var lastSort []interface{}
 	for _, hit := range response.Hits.Hits {
+		lastSort = hit.Sort
 		var source map[string]interface{}
 		err := json.Unmarshal(*hit.Source, &source)
 		if err != nil {
@@ -104,6 +105,14 @@ func (es *elasticsearch) getRawLogsES6(ctx context.Context, logsFilter logsFilte
 	logs["logs"] = hits
 	logs["total"] = len(hits)
 	logs["took"] = response.TookInMillis
+	// the cursor of the next page, passed as the search_after param
+	if len(response.Hits.Hits) == logsFilter.Size && len(lastSort) > 0 {
+		cursor, err := encodeCursor(lastSort)
+		if err != nil {
+			return nil, err
+		}
+		logs["search_after"] = cursor
+	}
 
 	raw, err := json.Marshal(logs)
 	if err != nil {
@@ -112,3 +121,31 @@ func (es *elasticsearch) getRawLogsES6(ctx context.Context, logsFilter logsFilte
 
 	return raw, nil
 }
+
+// applyStructuredFiltersES6 filters the logs by their status, category,
+// method, username, client IP and text.
+func applyStructuredFiltersES6(query *es6.BoolQuery, logsFilter logsFilter) {
+	if len(logsFilter.StatusRanges) > 0 {
+		statusQuery := es6.NewBoolQuery().MinimumNumberShouldMatch(1)
+		for _, status := range logsFilter.StatusRanges {
+			statusQuery.Should(es6.NewRangeQuery("response.code").Gte(status.From).Lte(status.To))
+		}
+		query.Filter(statusQuery)
+	}
+	for field, values := range map[string][]string{
+		"category.keyword":       logsFilter.Categories,
+		"request.method.keyword": logsFilter.Methods,
+		"username":               logsFilter.Usernames,
+		"client_ip":              logsFilter.IPs,
+	} {
+		if len(values) > 0 {
+			query.Filter(es6.NewTermsQuery(field, toInterfaces(values)...))
+		}
+	}
+	if logsFilter.Text != "" {
+		query.Filter(es6.NewSimpleQueryStringQuery(logsFilter.Text).
+			Field("request.uri").
+			Field("request.body").
+			DefaultOperator("and"))
+	}
+}
@@ -62,26 +62,27 @@ func (es *elasticsearch) getRawLogsES7(ctx context.Context, logsFilter logsFilte
 		query.Filter(latencyRangeQuery)
 	}
 
+	applyStructuredFiltersES7(query, logsFilter)
+
 	searchQuery := util.GetClient7().Search(es.indexName).
 		Query(query).
 		From(logsFilter.Offset).
 		Size(logsFilter.Size)
-	if logsFilter.OrderByLatency != "" {
-		ascending := false
-		if logsFilter.OrderByLatency == "asc" {
-			ascending = true
-		}
-		// sort by latency
-		searchQuery.SortWithInfo(es7.SortInfo{Field: "response.took", UnmappedType: "int", Ascending: ascending})
+	for _, sort := range logsFilter.sortKeys() {
+		searchQuery.SortWithInfo(es7.SortInfo{Field: sort.Field, UnmappedType: sortUnmappedType(sort.Field), Ascending: sort.Ascending})
+	}
+	if len(logsFilter.SearchAfter) > 0 {
+		searchQuery.SearchAfter(logsFilter.SearchAfter...)
 	}
-	searchQuery.SortWithInfo(es7.SortInfo{Field: "timestamp", UnmappedType: "date", Ascending: false})
 	response, err := searchQuery.Do(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	hits := make([]map[string]interface{}, 0)
+	var lastSort []interface{}
 	for _, hit := range response.Hits.Hits {
+		lastSort = hit.Sort
 		var source map[string]interface{}
 		err := json.Unmarshal(hit.Source, &source)
 		if err != nil {
@@ -98,6 +99,14 @@ func (es *elasticsearch) getRawLogsES7(ctx context.Context, logsFilter logsFilte
 	logs["logs"] = hits
 	logs["total"] = response.Hits.TotalHits.Value
 	logs["took"] = response.TookInMillis
+	// the cursor of the next page, passed as the search_after param
+	if len(hits) == logsFilter.Size && len(lastSort) > 0 {
+		cursor, err := encodeCursor(lastSort)
+		if err != nil {
+			return nil, err
+		}
+		logs["search_after"] = cursor
+	}
 
 	raw, err := json.Marshal(logs)
 	if err != nil {
@@ -106,6 +115,34 @@ func (es *elasticsearch) getRawLogsES7(ctx context.Context, logsFilter logsFilte
 	return raw, nil
 }
 
+// applyStructuredFiltersES7 filters the logs by their status, category,
+// method, username, client IP and text.
+func applyStructuredFiltersES7(query *es7.BoolQuery, logsFilter logsFilter) {
+	if len(logsFilter.StatusRanges) > 0 {
+		statusQuery := es7.NewBoolQuery().MinimumNumberShouldMatch(1)
+		for _, status := range logsFilter.StatusRanges {
+			statusQuery.Should(es7.NewRangeQuery("response.code").Gte(status.From).Lte(status.To))
+		}
+		query.Filter(statusQuery)
+	}
+	for field, values := range map[string][]string{
+		"category.keyword":       logsFilter.Categories,
+		"request.method.keyword": logsFilter.Methods,
+		"username":               logsFilter.Usernames,
+		"client_ip":              logsFilter.IPs,
+	} {
+		if len(values) > 0 {
+			query.Filter(es7.NewTermsQuery(field, toInterfaces(values)...))
+		}
+	}
+	if logsFilter.Text != "" {
+		query.Filter(es7.NewSimpleQueryStringQuery(logsFilter.Text).
+			Field("request.uri").
+			Field("request.body").
+			DefaultOperator("and"))
+	}
+}
+
 // getRawLogES7 will get the raw log for the log with passed ID.
 // If we don't find a match, we will raise a 404 error.
 func (es *elasticsearch) getRawLogES7(ctx context.Context, ID string, parseDiffs bool) ([]byte, *LogError) {
@@ -0,0 +1,206 @@
+package logs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// statusRange is an inclusive range of response status codes.
+type statusRange struct {
+	From int
+	To   int
+}
+
+// logsSort sorts the logs by a field of the records.
+type logsSort struct {
+	Field     string
+	Ascending bool
+}
+
+// sortFields are the fields the logs can be sorted by, keyed by their names in the sort param.
+var sortFields = map[string]string{
+	"timestamp": "timestamp",
+	"took":      "response.took",
+	"code":      "response.code",
+}
+
+// parseStatusRanges parses the comma separated status codes, classes and
+// ranges, e.g. "404,5xx,200-299".
+func parseStatusRanges(value string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, item := range splitList(value) {
+		lower := strings.ToLower(item)
+		switch {
+		case len(lower) == 3 && strings.HasSuffix(lower, "xx") && lower[0] >= '1' && lower[0] <= '5':
+			class := int(lower[0]-'0') * 100
+			ranges = append(ranges, statusRange{From: class, To: class + 99})
+		case strings.Contains(lower, "-"):
+			bounds := strings.SplitN(lower, "-", 2)
+			from, err1 := strconv.Atoi(bounds[0])
+			to, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || from > to {
+				return nil, fmt.Errorf(`invalid status range "%s"`, item)
+			}
+			ranges = append(ranges, statusRange{From: from, To: to})
+		default:
+			code, err := strconv.Atoi(lower)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid status "%s", must be a code, a class such as "5xx" or a range such as "500-503"`, item)
+			}
+			ranges = append(ranges, statusRange{From: code, To: code})
+		}
+	}
+	return ranges, nil
+}
+
+// parseSort parses the comma separated sort fields with their optional
+// order, e.g. "took:desc,timestamp".
+func parseSort(value string) ([]logsSort, error) {
+	var sorts []logsSort
+	for _, item := range splitList(value) {
+		parts := strings.SplitN(item, ":", 2)
+		field, ok := sortFields[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf(`invalid sort field "%s", must be one of "timestamp", "took" or "code"`, parts[0])
+		}
+		sort := logsSort{Field: field}
+		if len(parts) == 2 {
+			switch parts[1] {
+			case "asc":
+				sort.Ascending = true
+			case "desc":
+			default:
+				return nil, fmt.Errorf(`invalid sort order "%s", must be "asc" or "desc"`, parts[1])
+			}
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+// sortKeys returns the sort of the logs, the timestamp and the record_id always
+// come last so that the order is total and the search_after cursor neither
+// skips nor repeats the logs of the same sort values. Unlike the _id, the
+// record_id is a keyword with doc values, so sorting on it doesn't need the
+// fielddata.
+func (f logsFilter) sortKeys() []logsSort {
+	keys := append([]logsSort{}, f.Sort...)
+	if len(keys) == 0 && f.OrderByLatency != "" {
+		keys = append(keys, logsSort{Field: "response.took", Ascending: f.OrderByLatency == "asc"})
+	}
+	hasTimestamp := false
+	for _, key := range keys {
+		if key.Field == "timestamp" {
+			hasTimestamp = true
+		}
+	}
+	if !hasTimestamp {
+		keys = append(keys, logsSort{Field: "timestamp"})
+	}
+	return append(keys, logsSort{Field: "record_id"})
+}
+
+// validateCursor checks that the search_after cursor holds a value for each of
+// the sort keys, i.e. it was returned for the same sort.
+func (f logsFilter) validateCursor() error {
+	if len(f.SearchAfter) > 0 && len(f.SearchAfter) != len(f.sortKeys()) {
+		return fmt.Errorf(`invalid "search_after" cursor for the sort of the logs`)
+	}
+	return nil
+}
+
+// encodeCursor returns the opaque cursor of the sort values of a log.
+func encodeCursor(sortValues []interface{}) (string, error) {
+	raw, err := json.Marshal(sortValues)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) ([]interface{}, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid "search_after" cursor`)
+	}
+	var sortValues []interface{}
+	if err := json.Unmarshal(raw, &sortValues); err != nil || len(sortValues) == 0 {
+		return nil, fmt.Errorf(`invalid "search_after" cursor`)
+	}
+	return sortValues, nil
+}
+
+// parseLogsFilter parses the structured filters of the query params into the
+// logs filter.
+func parseLogsFilter(values url.Values, filter *logsFilter) error {
+	var err error
+	if value := values.Get("status"); value != "" {
+		if filter.StatusRanges, err = parseStatusRanges(value); err != nil {
+			return err
+		}
+	}
+	filter.Categories = splitList(values.Get("category"))
+	for _, method := range splitList(values.Get("method")) {
+		filter.Methods = append(filter.Methods, strings.ToUpper(method))
+	}
+	filter.Usernames = splitList(values.Get("username"))
+	for _, ip := range splitList(values.Get("ip")) {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Errorf(`invalid "ip" value "%s", must be an IP or a CIDR`, ip)
+			}
+		}
+		filter.IPs = append(filter.IPs, ip)
+	}
+	filter.Text = strings.TrimSpace(values.Get("q"))
+	for _, param := range []struct {
+		name  string
+		value **int
+	}{{"start_latency", &filter.StartLatency}, {"end_latency", &filter.EndLatency}} {
+		if value := values.Get(param.name); value != "" {
+			latency, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf(`invalid value "%v" for query param "%s"`, value, param.name)
+			}
+			*param.value = &latency
+		}
+	}
+	if value := values.Get("sort"); value != "" {
+		if filter.Sort, err = parseSort(value); err != nil {
+			return err
+		}
+	}
+	if value := values.Get("search_after"); value != "" {
+		if filter.SearchAfter, err = decodeCursor(value); err != nil {
+			return err
+		}
+		// the cursor replaces the offset
+		filter.Offset = 0
+	}
+	return nil
+}
+
+// sortUnmappedType is the type of the sort field for the indices without the
+// field, so that sorting doesn't fail on the older logs indices.
+func sortUnmappedType(field string) string {
+	if field == "record_id" {
+		return "keyword"
+	}
+	if field == "timestamp" {
+		return "date"
+	}
+	return "long"
+}
+
+func toInterfaces(values []string) []interface{} {
+	items := make([]interface{}, len(values))
+	for i, value := range values {
+		items[i] = value
+	}
+	return items
+}
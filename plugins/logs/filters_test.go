@@ -0,0 +1,50 @@
+package logs
+
+import (
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogsFilter(t *testing.T) {
+	Convey("should parse the structured filters", t, func() {
+		values, _ := url.ParseQuery("status=5xx,404,200-201&category=search,reactivesearch&method=post&username=jane&ip=10.0.0.0/8&q=harry&start_latency=100&sort=took:desc,timestamp")
+		var filter logsFilter
+		So(parseLogsFilter(values, &filter), ShouldBeNil)
+		So(filter.StatusRanges, ShouldResemble, []statusRange{{500, 599}, {404, 404}, {200, 201}})
+		So(filter.Categories, ShouldResemble, []string{"search", "reactivesearch"})
+		So(filter.Methods, ShouldResemble, []string{"POST"})
+		So(filter.Usernames, ShouldResemble, []string{"jane"})
+		So(filter.IPs, ShouldResemble, []string{"10.0.0.0/8"})
+		So(filter.Text, ShouldEqual, "harry")
+		So(*filter.StartLatency, ShouldEqual, 100)
+		So(filter.EndLatency, ShouldBeNil)
+		So(filter.Sort, ShouldResemble, []logsSort{{Field: "response.took"}, {Field: "timestamp"}})
+	})
+	Convey("should reject the invalid filters", t, func() {
+		for _, query := range []string{"status=6xx", "status=500-400", "ip=10.0.0", "sort=uri", "sort=took:up", "end_latency=slow", "search_after=nope"} {
+			values, _ := url.ParseQuery(query)
+			So(parseLogsFilter(values, &logsFilter{}), ShouldNotBeNil)
+		}
+	})
+	Convey("should round trip the search_after cursor and reset the offset", t, func() {
+		cursor, err := encodeCursor([]interface{}{1690000000000.0, 12.0})
+		So(err, ShouldBeNil)
+		filter := logsFilter{Offset: 100}
+		So(parseLogsFilter(url.Values{"search_after": {cursor}}, &filter), ShouldBeNil)
+		So(filter.SearchAfter, ShouldResemble, []interface{}{1690000000000.0, 12.0})
+		So(filter.Offset, ShouldEqual, 0)
+		So(filter.validateCursor(), ShouldBeNil)
+		filter.Sort = []logsSort{{Field: "response.code"}}
+		So(filter.validateCursor(), ShouldNotBeNil)
+		filter.SearchAfter = []interface{}{500.0, 1690000000000.0, "log-id"}
+		So(filter.validateCursor(), ShouldBeNil)
+	})
+	Convey("should end the sort with the timestamp and the record_id", t, func() {
+		So(logsFilter{}.sortKeys(), ShouldResemble, []logsSort{{Field: "timestamp"}, {Field: "record_id"}})
+		So(logsFilter{OrderByLatency: "asc"}.sortKeys(), ShouldResemble, []logsSort{{Field: "response.took", Ascending: true}, {Field: "timestamp"}, {Field: "record_id"}})
+		So(logsFilter{Sort: []logsSort{{Field: "timestamp", Ascending: true}, {Field: "response.code"}}}.sortKeys(), ShouldResemble,
+			[]logsSort{{Field: "timestamp", Ascending: true}, {Field: "response.code"}, {Field: "record_id"}})
+	})
+}
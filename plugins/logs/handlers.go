@@ -1,7 +1,10 @@
 package logs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -122,30 +125,14 @@ func (l *Logs) logsHandler(w http.ResponseWriter, req *http.Request, isSearchLog
 		Indices:   indices,
 	}
 
+	if err := parseLogsFilter(req.URL.Query(), &logsFilterConfig); err != nil {
+		log.Errorln(logTag, ": ", err)
+		util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Apply Search request filters
 	if isSearchLogs {
-		startLatency := req.URL.Query().Get("start_latency")
-		if startLatency != "" {
-			startLatencyAsInt, err := strconv.Atoi(startLatency)
-			if err != nil {
-				errMsg := fmt.Errorf(`invalid value "%v" for query param "start_latency"`, startLatency)
-				log.Errorln(logTag, ": ", errMsg)
-				util.WriteBackError(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			logsFilterConfig.StartLatency = &startLatencyAsInt
-		}
-		endLatency := req.URL.Query().Get("end_latency")
-		if endLatency != "" {
-			endLatencyAsInt, err := strconv.Atoi(endLatency)
-			if err != nil {
-				errMsg := fmt.Errorf(`invalid value "%v" for query param "end_latency"`, endLatency)
-				log.Errorln(logTag, ": ", errMsg)
-				util.WriteBackError(w, errMsg.Error(), http.StatusBadRequest)
-				return
-			}
-			logsFilterConfig.EndLatency = &endLatencyAsInt
-		}
 		orderBy := req.URL.Query().Get("order_by_latency")
 		if orderBy != "" {
 			if !(orderBy == "asc" || orderBy == "desc") {
@@ -162,6 +149,11 @@ func (l *Logs) logsHandler(w http.ResponseWriter, req *http.Request, isSearchLog
 		// Use search filter to always get search requests
 		logsFilterConfig.Filter = "search"
 	}
+	if err := logsFilterConfig.validateCursor(); err != nil {
+		log.Errorln(logTag, ": ", err)
+		util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	raw, err := l.es.getRawLogs(req.Context(), logsFilterConfig)
 	if err != nil {
@@ -207,3 +199,202 @@ func (l *Logs) getLogById() http.HandlerFunc {
 		util.WriteBackRaw(rw, raw, http.StatusOK)
 	}
 }
+
+func (l *Logs) getInsights() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		values := req.URL.Query()
+		rangeParams := RangeQueryParams(values)
+
+		filter := insightsFilter{
+			StartDate: rangeParams.StartDate,
+			EndDate:   rangeParams.EndDate,
+			Interval:  values.Get("interval"),
+			Size:      defaultInsightsSize,
+			Indices:   util.IndicesFromRequest(req),
+		}
+		if values.Get("size") != "" {
+			filter.Size = rangeParams.Size
+			if filter.Size > maxInsightsSize {
+				filter.Size = maxInsightsSize
+			}
+		}
+		if filter.Interval == "" {
+			filter.Interval = autoInterval(filter.StartDate, filter.EndDate)
+		} else if !intervalPattern.MatchString(filter.Interval) {
+			errMsg := fmt.Errorf(`invalid value "%v" for query param "interval", must be a duration such as "30m", "1h" or "1d"`, filter.Interval)
+			log.Errorln(logTag, ": ", errMsg)
+			util.WriteBackError(w, errMsg.Error(), http.StatusBadRequest)
+			return
+		}
+		if buckets := intervalBuckets(filter.StartDate, filter.EndDate, filter.Interval); buckets > maxInsightsBuckets {
+			errMsg := fmt.Errorf(`the "interval" of "%v" gives %d time buckets, the maximum is %d, use a longer interval or a shorter date range`,
+				filter.Interval, buckets, maxInsightsBuckets)
+			log.Errorln(logTag, ": ", errMsg)
+			util.WriteBackError(w, errMsg.Error(), http.StatusBadRequest)
+			return
+		}
+
+		raw, err := l.es.getInsights(req.Context(), filter)
+		if err != nil {
+			log.Errorln(logTag, ": error fetching logs insights :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (l *Logs) getSampling() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		raw, err := json.Marshal(l.sampler.current().config)
+		if err != nil {
+			log.Errorln(logTag, ": error marshalling the sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (l *Logs) putSampling() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var config SamplingConfig
+		if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+			log.Errorln(logTag, ": can't parse the sampling config :", err)
+			util.WriteBackError(w, "can't parse request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := config.compile(); err != nil {
+			log.Errorln(logTag, ": invalid sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := l.es.putSampling(req.Context(), config); err != nil {
+			log.Errorln(logTag, ": error saving the sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Only update local state when proxy API has not been called
+		// If proxy API would get called then it would automatically reload the
+		// sampling config for all machines
+		if util.ShouldProxyToACCAPI() {
+			res, err := util.ProxyACCAPI(util.ProxyConfig{
+				Method: http.MethodPost,
+				URL:    "/_logs/sampling/_reload",
+				Body:   nil,
+			})
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Failed to update all nodes, return error response
+			if res != nil {
+				log.Errorln(logTag, ":", "error encountered reloading the sampling config")
+				bodyBytes, err := ioutil.ReadAll(res.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				util.WriteBackRaw(w, bodyBytes, res.StatusCode)
+				return
+			}
+		} else if err := l.sampler.set(config); err != nil {
+			log.Errorln(logTag, ": invalid sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		raw, err := json.Marshal(config)
+		if err != nil {
+			log.Errorln(logTag, ": error marshalling the sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (l *Logs) reloadSampling() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		saved, err := l.es.getSampling(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ": error fetching the saved sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := l.sampler.reload(saved); err != nil {
+			log.Errorln(logTag, ": error reloading the sampling config :", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.getSampling()(w, req)
+	}
+}
+
+func (l *Logs) getSinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		stats := make([]SinkStats, 0)
+		for _, sink := range l.currentSinks() {
+			stats = append(stats, sink.Stats())
+		}
+		raw, err := json.Marshal(stats)
+		if err != nil {
+			log.Errorln(logTag, ": error marshalling the sink stats :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func writeRetention(w http.ResponseWriter, status *retentionStatus) {
+	raw, err := json.Marshal(status)
+	if err != nil {
+		log.Errorln(logTag, ": error marshalling the retention policy :", err)
+		util.WriteBackError(w, "error occurred while marshalling the retention policy", http.StatusInternalServerError)
+		return
+	}
+	util.WriteBackRaw(w, raw, http.StatusOK)
+}
+
+func (l *Logs) getRetention() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		status, err := l.es.getRetention(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ": error fetching the retention policy :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeRetention(w, status)
+	}
+}
+
+func (l *Logs) putRetention() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var policy RetentionPolicy
+		if err := json.NewDecoder(req.Body).Decode(&policy); err != nil {
+			log.Errorln(logTag, ": can't parse the retention policy :", err)
+			util.WriteBackError(w, "can't parse request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := policy.validate(); err != nil {
+			log.Errorln(logTag, ": invalid retention policy :", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, err := l.es.putRetention(req.Context(), policy)
+		if errors.Is(err, errUnsupportedLifecycle) {
+			log.Errorln(logTag, ": invalid retention policy :", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Errorln(logTag, ": error updating the retention policy :", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeRetention(w, status)
+	}
+}
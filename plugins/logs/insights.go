@@ -0,0 +1,264 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/util"
+	es7 "github.com/olivere/elastic/v7"
+)
+
+const (
+	defaultInsightsSize = 10
+	maxInsightsSize     = 100
+	// errorStatus is the status from which a request counts as an error
+	errorStatus = 400
+	// maxInsightsBuckets is the maximum number of the time buckets of the range
+	maxInsightsBuckets = 1000
+)
+
+var (
+	intervalPattern = regexp.MustCompile(`^([1-9][0-9]*)([smhd])$`)
+	intervalUnits   = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+)
+
+// insightsFilter selects the logs the insights are computed from.
+type insightsFilter struct {
+	StartDate string
+	EndDate   string
+	// Interval of the time buckets, e.g. "1h"
+	Interval string
+	// Size is the number of the top slow queries and the top indices
+	Size    int
+	Indices []string
+}
+
+// intervalBuckets returns the number of the time buckets of the interval over
+// the range, 0 if the range or the interval can't be parsed.
+func intervalBuckets(startDate, endDate, interval string) int64 {
+	start, err1 := time.Parse(time.RFC3339, startDate)
+	end, err2 := time.Parse(time.RFC3339, endDate)
+	match := intervalPattern.FindStringSubmatch(interval)
+	if err1 != nil || err2 != nil || match == nil || end.Before(start) {
+		return 0
+	}
+	count, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || count > int64(math.MaxInt64/intervalUnits[match[2]]) {
+		return 0
+	}
+	step := time.Duration(count) * intervalUnits[match[2]]
+	return int64(end.Sub(start)/step) + 1
+}
+
+// autoInterval returns the interval of the time buckets of a range: hourly
+// up to a day, every 6 hours up to a week and daily beyond, the days are
+// coarsened to keep the buckets under the maximum.
+func autoInterval(startDate, endDate string) string {
+	start, err1 := time.Parse(time.RFC3339, startDate)
+	end, err2 := time.Parse(time.RFC3339, endDate)
+	if err1 != nil || err2 != nil {
+		return "1d"
+	}
+	switch duration := end.Sub(start); {
+	case duration <= 24*time.Hour:
+		return "1h"
+	case duration <= 7*24*time.Hour:
+		return "6h"
+	default:
+		days := int64(duration/(24*time.Hour))/maxInsightsBuckets + 1
+		return fmt.Sprintf("%dd", days)
+	}
+}
+
+// Latency are the percentiles of the latency of the requests in milliseconds,
+// they are nil without any request.
+type Latency struct {
+	P50 *float64 `json:"p50"`
+	P95 *float64 `json:"p95"`
+	P99 *float64 `json:"p99"`
+}
+
+// InsightsBucket is the count of the requests of a bucket along with their
+// errors and latency.
+type InsightsBucket struct {
+	Key       string   `json:"key"`
+	Count     int64    `json:"count"`
+	Errors    int64    `json:"errors"`
+	ErrorRate float64  `json:"error_rate"`
+	Latency   *Latency `json:"latency,omitempty"`
+}
+
+// Insights are the aggregated stats of the logs.
+type Insights struct {
+	Took      int64   `json:"took"`
+	Total     int64   `json:"total"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	Latency   Latency `json:"latency"`
+	Interval  string  `json:"interval"`
+	// Histogram are the time buckets of the requests
+	Histogram []InsightsBucket `json:"histogram"`
+	// Status are the requests by status class, e.g. "5xx"
+	Status []InsightsBucket `json:"status"`
+	// Categories are the requests by category along with their error rates
+	Categories     []InsightsBucket         `json:"categories"`
+	TopIndices     []InsightsBucket         `json:"top_indices"`
+	TopSlowQueries []map[string]interface{} `json:"top_slow_queries"`
+}
+
+func errorRate(errors, count int64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(errors) / float64(count)
+}
+
+// latencyAggregation and errorsAggregation are the sub aggregations of every
+// bucket of the insights.
+func latencyAggregation() *es7.PercentilesAggregation {
+	return es7.NewPercentilesAggregation().Field("response.took").Percentiles(50, 95, 99)
+}
+
+func errorsAggregation() *es7.FilterAggregation {
+	return es7.NewFilterAggregation().Filter(es7.NewRangeQuery("response.code").Gte(errorStatus))
+}
+
+func parseLatency(aggs es7.Aggregations) *Latency {
+	percentiles, ok := aggs.Percentiles("latency")
+	if !ok {
+		return nil
+	}
+	value := func(key string) *float64 {
+		v, ok := percentiles.Values[key]
+		if !ok || math.IsNaN(v) {
+			return nil
+		}
+		return &v
+	}
+	return &Latency{P50: value("50.0"), P95: value("95.0"), P99: value("99.0")}
+}
+
+func parseBucket(key string, count int64, aggs es7.Aggregations) InsightsBucket {
+	bucket := InsightsBucket{Key: key, Count: count}
+	// the percentiles of an empty bucket are null, which decode as 0
+	if count > 0 {
+		bucket.Latency = parseLatency(aggs)
+	}
+	if errors, ok := aggs.Filter("errors"); ok {
+		bucket.Errors = errors.DocCount
+	}
+	bucket.ErrorRate = errorRate(bucket.Errors, bucket.Count)
+	return bucket
+}
+
+func (es *elasticsearch) getInsights(ctx context.Context, filter insightsFilter) ([]byte, error) {
+	duration := es7.NewRangeQuery("timestamp").
+		From(filter.StartDate).
+		To(filter.EndDate)
+	query := es7.NewBoolQuery().Filter(duration)
+	util.GetIndexFilterQueryEs7(query, filter.Indices...)
+
+	histogram := es7.NewDateHistogramAggregation().
+		Field("timestamp").
+		MinDocCount(0).
+		ExtendedBounds(filter.StartDate, filter.EndDate).
+		SubAggregation("errors", errorsAggregation()).
+		SubAggregation("latency", latencyAggregation())
+	// fixed_interval replaces interval from elasticsearch 7.2
+	if util.GetVersion() == 6 {
+		histogram.Interval(filter.Interval)
+	} else {
+		histogram.FixedInterval(filter.Interval)
+	}
+	status := es7.NewRangeAggregation().Field("response.code")
+	for class := 1; class <= 5; class++ {
+		status.AddRangeWithKey(fmt.Sprintf("%dxx", class), class*100, (class+1)*100)
+	}
+	categories := es7.NewTermsAggregation().
+		Field("category.keyword").
+		Size(maxInsightsSize).
+		SubAggregation("errors", errorsAggregation()).
+		SubAggregation("latency", latencyAggregation())
+	indices := es7.NewTermsAggregation().
+		Field("indices.keyword").
+		Size(filter.Size).
+		SubAggregation("errors", errorsAggregation()).
+		SubAggregation("latency", latencyAggregation())
+
+	response, err := util.GetClient7().Search(es.indexName).
+		Query(query).
+		Size(filter.Size).
+		SortWithInfo(es7.SortInfo{Field: "response.took", UnmappedType: "long", Ascending: false}).
+		FetchSourceContext(es7.NewFetchSourceContext(true).Include(
+			"timestamp", "indices", "category", "request.uri", "request.method", "request.body",
+			"response.code", "response.took", "username")).
+		TrackTotalHits(true).
+		Aggregation("errors", errorsAggregation()).
+		Aggregation("latency", latencyAggregation()).
+		Aggregation("histogram", histogram).
+		Aggregation("status", status).
+		Aggregation("categories", categories).
+		Aggregation("indices", indices).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overall := parseBucket("", response.TotalHits(), response.Aggregations)
+	insights := Insights{
+		Took:           response.TookInMillis,
+		Total:          overall.Count,
+		Errors:         overall.Errors,
+		ErrorRate:      overall.ErrorRate,
+		Interval:       filter.Interval,
+		Histogram:      make([]InsightsBucket, 0),
+		Status:         make([]InsightsBucket, 0),
+		Categories:     make([]InsightsBucket, 0),
+		TopIndices:     make([]InsightsBucket, 0),
+		TopSlowQueries: make([]map[string]interface{}, 0),
+	}
+	if overall.Latency != nil {
+		insights.Latency = *overall.Latency
+	}
+	if agg, ok := response.Aggregations.DateHistogram("histogram"); ok {
+		for _, item := range agg.Buckets {
+			key := fmt.Sprint(int64(item.Key))
+			if item.KeyAsString != nil {
+				key = *item.KeyAsString
+			}
+			insights.Histogram = append(insights.Histogram, parseBucket(key, item.DocCount, item.Aggregations))
+		}
+	}
+	if agg, ok := response.Aggregations.Range("status"); ok {
+		for _, item := range agg.Buckets {
+			bucket := InsightsBucket{Key: item.Key, Count: item.DocCount}
+			if item.Key[0] >= '4' {
+				bucket.Errors = item.DocCount
+			}
+			// the share of the class in all the requests
+			bucket.ErrorRate = errorRate(bucket.Errors, insights.Total)
+			insights.Status = append(insights.Status, bucket)
+		}
+	}
+	for name, target := range map[string]*[]InsightsBucket{"categories": &insights.Categories, "indices": &insights.TopIndices} {
+		if agg, ok := response.Aggregations.Terms(name); ok {
+			for _, item := range agg.Buckets {
+				*target = append(*target, parseBucket(fmt.Sprint(item.Key), item.DocCount, item.Aggregations))
+			}
+		}
+	}
+	for _, hit := range response.Hits.Hits {
+		var source map[string]interface{}
+		if err := json.Unmarshal(hit.Source, &source); err != nil {
+			return nil, err
+		}
+		source["id"] = hit.Id
+		insights.TopSlowQueries = append(insights.TopSlowQueries, source)
+	}
+	return json.Marshal(insights)
+}
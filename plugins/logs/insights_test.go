@@ -0,0 +1,42 @@
+package logs
+
+import (
+	"encoding/json"
+	"testing"
+
+	es7 "github.com/olivere/elastic/v7"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInsights(t *testing.T) {
+	Convey("should pick the interval from the date range", t, func() {
+		So(autoInterval("2021-01-01T00:00:00Z", "2021-01-01T23:59:59Z"), ShouldEqual, "1h")
+		So(autoInterval("2021-01-01T00:00:00Z", "2021-01-07T23:59:59Z"), ShouldEqual, "6h")
+		So(autoInterval("2021-01-01T00:00:00Z", "2021-01-31T23:59:59Z"), ShouldEqual, "1d")
+		So(intervalPattern.MatchString("30m"), ShouldBeTrue)
+		So(intervalPattern.MatchString("1w"), ShouldBeFalse)
+		// the days are coarsened past the maximum number of buckets
+		So(autoInterval("2000-01-01T00:00:00Z", "2021-01-01T00:00:00Z"), ShouldEqual, "8d")
+		So(intervalBuckets("2000-01-01T00:00:00Z", "2021-01-01T00:00:00Z", autoInterval("2000-01-01T00:00:00Z", "2021-01-01T00:00:00Z")), ShouldBeLessThanOrEqualTo, maxInsightsBuckets)
+	})
+	Convey("should count the time buckets of the interval", t, func() {
+		So(intervalBuckets("2021-01-01T00:00:00Z", "2021-01-01T23:59:59Z", "1h"), ShouldEqual, 24)
+		So(intervalBuckets("2021-01-01T00:00:00Z", "2021-01-31T23:59:59Z", "1s"), ShouldBeGreaterThan, maxInsightsBuckets)
+		So(intervalBuckets("2021-01-01T00:00:00Z", "2021-01-31T23:59:59Z", "99999999999999d"), ShouldEqual, 0)
+	})
+	Convey("should parse the errors and the latency of a bucket", t, func() {
+		var aggs es7.Aggregations
+		So(json.Unmarshal([]byte(`{"errors":{"doc_count":5},"latency":{"values":{"50.0":12,"95.0":80,"99.0":120}}}`), &aggs), ShouldBeNil)
+		bucket := parseBucket("search", 20, aggs)
+		So(bucket.Errors, ShouldEqual, 5)
+		So(bucket.ErrorRate, ShouldEqual, 0.25)
+		So(*bucket.Latency.P95, ShouldEqual, 80)
+	})
+	Convey("should leave the latency of an empty bucket null", t, func() {
+		var aggs es7.Aggregations
+		So(json.Unmarshal([]byte(`{"errors":{"doc_count":0},"latency":{"values":{"50.0":null,"95.0":null,"99.0":null}}}`), &aggs), ShouldBeNil)
+		bucket := parseBucket("", 0, aggs)
+		So(bucket.ErrorRate, ShouldEqual, 0)
+		So(bucket.Latency, ShouldBeNil)
+	})
+}
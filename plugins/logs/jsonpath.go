@@ -0,0 +1,115 @@
+package logs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a step of a JSONPath, it selects the children of the
+// current values, or all their descendants if recursive.
+type jsonPathStep struct {
+	recursive bool
+	// wildcard selects all the keys or the items
+	wildcard bool
+	key      string
+	// index is the selected item of an array, -1 selects none
+	index int
+}
+
+// jsonPath is a compiled JSONPath, e.g. "$.query[*].value" or "$..password".
+// Only the dot and the bracket notation of the keys, the indices and the
+// wildcards are supported, along with the recursive descent.
+type jsonPath []jsonPathStep
+
+func compileJSONPath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf(`invalid JSONPath "%s": must start with "$"`, path)
+	}
+	var steps jsonPath
+	rest := path[1:]
+	for rest != "" {
+		step := jsonPathStep{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] == '[':
+		default:
+			return nil, fmt.Errorf(`invalid JSONPath "%s": unexpected "%s"`, path, rest)
+		}
+		if strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf(`invalid JSONPath "%s": unclosed bracket`, path)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				step.wildcard = true
+			case len(selector) > 1 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				step.key = selector[1 : len(selector)-1]
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf(`invalid JSONPath "%s": invalid index "%s"`, path, selector)
+				}
+				step.index = index
+			}
+		} else {
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			step.key, rest = rest[:end], rest[end:]
+			if step.key == "" {
+				return nil, fmt.Errorf(`invalid JSONPath "%s": empty key`, path)
+			}
+			step.wildcard = step.key == "*"
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// replace replaces the values selected by the path in the decoded JSON value,
+// it returns the number of replaced values.
+func (p jsonPath) replace(value interface{}, with interface{}) int {
+	if len(p) == 0 {
+		return 0
+	}
+	step, rest := p[0], p[1:]
+	replaced := 0
+	set := func(child interface{}, assign func(interface{})) {
+		if len(rest) == 0 {
+			assign(with)
+			replaced++
+			return
+		}
+		replaced += rest.replace(child, with)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			key := key
+			if step.wildcard || (step.index < 0 && key == step.key) {
+				set(child, func(w interface{}) { v[key] = w })
+			} else if step.recursive {
+				replaced += p.replace(child, with)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			i := i
+			if step.wildcard || i == step.index {
+				set(child, func(w interface{}) { v[i] = w })
+			} else if step.recursive {
+				replaced += p.replace(child, with)
+			}
+		}
+	}
+	return replaced
+}
@@ -1,6 +1,7 @@
 package logs
 
 import (
+	"context"
 	"os"
 	"sync"
 
@@ -32,11 +33,6 @@ const (
 	  },
 	  "mappings": %s
 	}`
-	rolloverConfig = `{
-		"max_age":  "%s",
-		"max_docs": %d,
-		"max_size": "%s"
-	}`
 )
 
 var (
@@ -49,6 +45,10 @@ type Logs struct {
 	es            logsService
 	lumberjack    lumberjack.Logger
 	enableDiffing bool
+	redaction     *redaction
+	sampler       *sampler
+	sinksMu       sync.RWMutex
+	sinks         []LogSink
 }
 
 // Instance returns the singleton instance of Logs plugin.
@@ -97,6 +97,23 @@ func (l *Logs) InitFunc() error {
 		MaxAge:     30, //days
 	}
 
+	l.sinks, err = l.initSinks()
+	if err != nil {
+		return err
+	}
+	l.redaction, err = initRedaction()
+	if err != nil {
+		return err
+	}
+	saved, err := l.es.getSampling(context.Background())
+	if err != nil {
+		return err
+	}
+	l.sampler, err = newSampler(saved)
+	if err != nil {
+		return err
+	}
+
 	// init cron job
 	cronjob := cron.New()
 	cronjob.AddFunc("@midnight", func() { l.es.rolloverIndexJob(indexName) })
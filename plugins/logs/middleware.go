@@ -12,16 +12,21 @@ import (
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
+	"github.com/appbaseio/reactivesearch-api/model/authevent"
 	"github.com/appbaseio/reactivesearch-api/model/category"
 	"github.com/appbaseio/reactivesearch-api/model/console"
 	"github.com/appbaseio/reactivesearch-api/model/difference"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/model/request"
 	"github.com/appbaseio/reactivesearch-api/model/requestlogs"
+	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
 	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/iplookup"
 	"github.com/buger/jsonparser"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -73,6 +78,21 @@ func classifyCategory(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// isAdmin allows only the admin users to access the routes changing the
+// logs settings, the requests of the permissions are rejected as well.
+func isAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		reqUser, err := user.FromContext(req.Context())
+		if err != nil || reqUser.IsAdmin == nil || !*reqUser.IsAdmin {
+			msg := "only the admin users are allowed to access the route"
+			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+			telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusUnauthorized)
+			return
+		}
+		h(w, req)
+	}
+}
+
 type Request struct {
 	URI     string              `json:"uri"`
 	Method  string              `json:"method"`
@@ -98,6 +118,11 @@ type record struct {
 	Timestamp       time.Time               `json:"timestamp"`
 	Console         []string                `json:"console_logs"`
 	DiffLogs        bool                    `json:"diffLogs"`
+	AuthEvents      []authevent.Event       `json:"auth_events,omitempty"`
+	Username        string                  `json:"username,omitempty"`
+	ClientIP        string                  `json:"client_ip,omitempty"`
+	// RecordID is unique to the record, it breaks the ties of the sort
+	RecordID string `json:"record_id"`
 }
 
 // Recorder records a log "record" for every request.
@@ -107,8 +132,16 @@ func Recorder() middleware.Middleware {
 
 func (l *Logs) recorder(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// skip logs from streams and blacklisted paths
-		if r.Header.Get("X-Request-Category") == "streams" || isPathBlacklisted(r.URL.Path) {
+		// skip logs from streams, dropped requests and explained requests
+		sampled := sampledRequest{path: r.URL.Path}
+		if reqCategory, err := category.FromContext(r.Context()); err == nil {
+			sampled.category = reqCategory.String()
+		}
+		if reqIndices, err := index.FromContext(r.Context()); err == nil {
+			sampled.indices = reqIndices
+		}
+		sampling := l.sampler.current()
+		if r.Header.Get("X-Request-Category") == "streams" || sampling.dropped(sampled) || explain.IsDryRun(r.Context()) {
 			h(w, r)
 			return
 		}
@@ -123,17 +156,26 @@ func (l *Logs) recorder(h http.HandlerFunc) http.HandlerFunc {
 		consoleLogsCtx := console.NewContext(r.Context(), &consoleLogs)
 		r = r.WithContext(consoleLogsCtx)
 
+		// Init the auth events holder, lockouts are recorded by the auth middleware
+		authEventsCtx := authevent.NewContext(r.Context(), &authevent.Events{})
+		r = r.WithContext(authEventsCtx)
+
 		// Serve using response recorder
 		respRecorder := httptest.NewRecorder()
+		start := time.Now()
 		h(respRecorder, r)
+		sampled.took = time.Since(start)
+		sampled.status = respRecorder.Code
 		// Copy the response to writer
 		for k, v := range respRecorder.Header() {
 			w.Header()[k] = v
 		}
 		w.WriteHeader(respRecorder.Code)
 		w.Write(respRecorder.Body.Bytes())
-		// Record the document
-
+		// Record the document if sampled
+		if !sampling.sample(sampled, l.sampler.random) {
+			return
+		}
 		go l.recordResponse(respRecorder, r, dumpRequest)
 	}
 }
@@ -178,6 +220,8 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 		}
 	}
 
+	redactor := l.redaction.forIndices(reqIndices)
+
 	var rec record
 	rec.Indices = reqIndices
 	rec.Category = reqCategory.String()
@@ -206,6 +250,7 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 		}
 	}
 	rec.Timestamp = time.Now()
+	rec.RecordID = uuid.New().String()
 
 	// record response
 	response := w.Result()
@@ -229,10 +274,12 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 			rec.Response.Took = &resBody.Took
 		}
 	}
-	requestBodyToStore := string(parsedBody[:util.Min(len(parsedBody), 1000000)])
+	// the bodies are redacted as a whole, the truncated ones can't be parsed
+	requestBodyToStore := truncateBody(redactor.redactBody(string(parsedBody)))
+	responseBodyToStore := truncateBody(redactor.redactBody(string(responseBody)))
 	if *reqCategory == category.ReactiveSearch {
 		rec.Request = Request{
-			URI:     r.URL.Path,
+			URI:     r.URL.RequestURI(),
 			Headers: headers,
 			Body:    requestBodyToStore,
 			Method:  r.Method,
@@ -246,16 +293,16 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 			rec.Response.Took = &tookValue
 		}
 		// read error response from response recorder body
-		rec.Response.Body = string(responseBody[:util.Min(len(responseBody), 1000000)])
+		rec.Response.Body = responseBodyToStore
 	} else {
 		// record request
 		rec.Request = Request{
-			URI:     r.URL.Path,
+			URI:     r.URL.RequestURI(),
 			Headers: headers,
 			Body:    requestBodyToStore,
 			Method:  r.Method,
 		}
-		rec.Response.Body = string(responseBody[:util.Min(len(responseBody), 1000000)])
+		rec.Response.Body = responseBodyToStore
 	}
 
 	// Extract the request changes from context
@@ -271,6 +318,7 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 			responseChangesByStage := make(map[string]RequestChange)
 
 			for result := range rl.Output {
+				result.Data = redactor.redactData(result.Data)
 				if result.LogType == "request" {
 					requestChange := RequestChange{}
 					rc, ok := requestChangesByStage[result.Stage]
@@ -313,19 +361,29 @@ func (l *Logs) recordResponse(w *httptest.ResponseRecorder, r *http.Request, req
 		rec.Console = *consoleStr
 	}
 
+	// Extract the auth events and the authenticated username
+	if authEvents, err := authevent.FromContext(ctx); err == nil {
+		rec.AuthEvents = authEvents.List()
+		rec.Username = authEvents.Username()
+	}
+	rec.ClientIP = iplookup.FromRequest(r)
+
+	redactor.redactRecord(&rec)
 	marshalledLog, err := json.Marshal(rec)
 	if err != nil {
 		log.Warningln(logTag, "error encountered while marshalling record :", err)
 		return
 	}
-	n, err := l.lumberjack.Write(marshalledLog)
-	if err != nil {
-		log.Errorln(logTag, "error encountered while writing logs :", err)
-		return
-	}
-	// Add new line character so filebeat can sync it with ES
-	l.lumberjack.Write([]byte("\n"))
-	log.Println(logTag, "logged request successfully", n)
+	l.write(marshalledLog)
+	log.Println(logTag, "logged request successfully", len(marshalledLog))
+}
+
+// maxBodySize is the number of bytes of a body the record keeps.
+const maxBodySize = 1000000
+
+// truncateBody cuts the body to the size a record keeps.
+func truncateBody(body string) string {
+	return body[:util.Min(len(body), maxBodySize)]
 }
 
 // captureStageChanges will capture the stage changes on a per-stage
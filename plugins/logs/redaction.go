@@ -0,0 +1,289 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/model/requestlogs"
+	"github.com/appbaseio/reactivesearch-api/plugins/auth"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+)
+
+const (
+	envRedactHeaders    = "LOGS_REDACT_HEADERS"
+	envRedactFields     = "LOGS_REDACT_FIELDS"
+	envRedactPII        = "LOGS_REDACT_PII"
+	envRedactionIndices = "LOGS_REDACTION_INDICES"
+	// redacted replaces the redacted values
+	redacted = "[REDACTED]"
+)
+
+// defaultRedactedHeaders are redacted unless LOGS_REDACT_HEADERS is set.
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"X-Api-Key",
+	auth.HeaderSignature,
+	auth.HeaderSignatureKeyID,
+	auth.HeaderSignatureTimestamp,
+}
+
+// PII kinds scrubbed from the bodies and the header values.
+const (
+	PIIEmail = "email"
+	PIICard  = "card"
+)
+
+var piiPatterns = map[string]*regexp.Regexp{
+	PIIEmail: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	PIICard:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+}
+
+// RedactionPolicy is the redaction applied to the logs of a request before
+// they get recorded.
+type RedactionPolicy struct {
+	// Headers are the names of the request and response headers to redact
+	Headers []string `json:"headers,omitempty"`
+	// Fields are the JSONPaths of the body fields to mask, e.g. "$.query[*].value"
+	Fields []string `json:"fields,omitempty"`
+	// PII are the kinds of PII to scrub from the bodies, "email" and "card"
+	PII []string `json:"pii,omitempty"`
+}
+
+// redactor is a compiled redaction policy.
+type redactor struct {
+	headers map[string]bool
+	fields  []jsonPath
+	pii     []string
+}
+
+func (p RedactionPolicy) compile() (*redactor, error) {
+	r := &redactor{headers: make(map[string]bool)}
+	for _, header := range p.Headers {
+		r.headers[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+	for _, field := range p.Fields {
+		path, err := compileJSONPath(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		r.fields = append(r.fields, path)
+	}
+	for _, kind := range p.PII {
+		kind = strings.TrimSpace(kind)
+		if _, ok := piiPatterns[kind]; !ok {
+			return nil, fmt.Errorf(`invalid PII kind "%s", must be one of "%s" or "%s"`, kind, PIIEmail, PIICard)
+		}
+		r.pii = append(r.pii, kind)
+	}
+	return r, nil
+}
+
+// merge returns a redactor applying both redactors.
+func (r *redactor) merge(other *redactor) *redactor {
+	merged := &redactor{headers: make(map[string]bool)}
+	for _, each := range []*redactor{r, other} {
+		for header := range each.headers {
+			merged.headers[header] = true
+		}
+		merged.fields = append(merged.fields, each.fields...)
+		merged.pii = append(merged.pii, each.pii...)
+	}
+	return merged
+}
+
+// redaction holds the global redaction policy and the policies of the indices.
+type redaction struct {
+	global *redactor
+	// indices are the policies keyed by the index patterns
+	indices map[string]*redactor
+}
+
+// initRedaction reads the redaction policies from the env vars.
+func initRedaction() (*redaction, error) {
+	global := RedactionPolicy{
+		Headers: defaultRedactedHeaders,
+		Fields:  splitList(os.Getenv(envRedactFields)),
+		PII:     splitList(os.Getenv(envRedactPII)),
+	}
+	// an empty value turns off the default redacted headers
+	if headers, ok := os.LookupEnv(envRedactHeaders); ok {
+		global.Headers = splitList(headers)
+	}
+	r := &redaction{indices: make(map[string]*redactor)}
+	var err error
+	if r.global, err = global.compile(); err != nil {
+		return nil, fmt.Errorf("invalid global redaction policy: %v", err)
+	}
+	if value := os.Getenv(envRedactionIndices); value != "" {
+		var policies map[string]RedactionPolicy
+		if err := json.Unmarshal([]byte(value), &policies); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", envRedactionIndices, err)
+		}
+		for pattern, policy := range policies {
+			compiled, err := policy.compile()
+			if err != nil {
+				return nil, fmt.Errorf(`invalid redaction policy of "%s": %v`, pattern, err)
+			}
+			r.indices[pattern] = compiled
+		}
+	}
+	return r, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// forIndices returns the global policy along with the policies of the indices
+// of the request.
+func (r *redaction) forIndices(indices []string) *redactor {
+	policy := r.global
+	for pattern, indexPolicy := range r.indices {
+		patterns := matcher.Globs([]string{pattern})
+		for _, index := range indices {
+			if patterns.Match(index) {
+				policy = policy.merge(indexPolicy)
+				break
+			}
+		}
+	}
+	return policy
+}
+
+// redactRecord redacts the headers of the request and the response of the
+// record, the bodies are redacted by redactBody before they are truncated.
+func (r *redactor) redactRecord(rec *record) {
+	rec.Request.Headers = r.redactHeaders(rec.Request.Headers)
+	rec.Response.Headers = r.redactHeaders(rec.Response.Headers)
+}
+
+// redactData redacts the request or the response of a stage.
+func (r *redactor) redactData(data requestlogs.RequestData) requestlogs.RequestData {
+	data.Headers = r.redactHeaders(data.Headers)
+	data.Body = r.redactBody(data.Body)
+	return data
+}
+
+// redactHeaders returns a copy of the headers with the values of the denied
+// headers replaced, and the PII scrubbed from the other values.
+func (r *redactor) redactHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	copied := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			if r.headers[http.CanonicalHeaderKey(key)] {
+				redactedValues[i] = redacted
+			} else {
+				redactedValues[i] = r.scrub(value)
+			}
+		}
+		copied[key] = redactedValues
+	}
+	return copied
+}
+
+// redactBody masks the fields of a JSON or a newline delimited JSON body, and
+// scrubs the PII from the body. The body that can't be parsed to mask the
+// fields is dropped as a whole.
+func (r *redactor) redactBody(body string) string {
+	if len(r.fields) > 0 && body != "" {
+		if masked, ok := r.maskFields(body); ok {
+			body = masked
+		} else {
+			// the _msearch and the _bulk bodies are masked line by line
+			lines := strings.Split(body, "\n")
+			for i, line := range lines {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				masked, ok := r.maskFields(line)
+				if !ok {
+					return redacted
+				}
+				lines[i] = masked
+			}
+			body = strings.Join(lines, "\n")
+		}
+	}
+	return r.scrub(body)
+}
+
+// maskFields returns the JSON body with the fields replaced, or false if the
+// body isn't JSON.
+func (r *redactor) maskFields(body string) (string, bool) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return "", false
+	}
+	replaced := 0
+	for _, path := range r.fields {
+		replaced += path.replace(value, redacted)
+	}
+	if replaced == 0 {
+		return body, true
+	}
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// scrub replaces the PII of the value.
+func (r *redactor) scrub(value string) string {
+	for _, kind := range r.pii {
+		pattern := piiPatterns[kind]
+		if kind == PIICard {
+			value = pattern.ReplaceAllStringFunc(value, func(match string) string {
+				if luhnValid(match) {
+					return redacted
+				}
+				return match
+			})
+			continue
+		}
+		value = pattern.ReplaceAllString(value, redacted)
+	}
+	return value
+}
+
+// luhnValid returns true if the digits of the number pass the Luhn checksum of
+// the card numbers, the other long numbers such as timestamps mostly don't.
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
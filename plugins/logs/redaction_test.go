@@ -0,0 +1,51 @@
+package logs
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedaction(t *testing.T) {
+	Convey("should redact the denied headers and keep the others", t, func() {
+		r, err := RedactionPolicy{Headers: defaultRedactedHeaders}.compile()
+		So(err, ShouldBeNil)
+		headers := r.redactHeaders(map[string][]string{
+			"authorization": {"Basic Zm9vOmJhcg=="},
+			"X-Api-Key":     {"secret"},
+			"Content-Type":  {"application/json"},
+		})
+		So(headers["authorization"], ShouldResemble, []string{redacted})
+		So(headers["X-Api-Key"], ShouldResemble, []string{redacted})
+		So(headers["Content-Type"], ShouldResemble, []string{"application/json"})
+	})
+	Convey("should mask the fields selected by the JSONPaths", t, func() {
+		r, err := RedactionPolicy{Fields: []string{"$.query[*].value", "$..password", "$.settings['userId']"}}.compile()
+		So(err, ShouldBeNil)
+		body := `{"query":[{"id":"search","value":"harry"},{"id":"result","size":10}],"settings":{"userId":"jane"},"meta":{"user":{"password":"hunter2"}}}`
+		So(r.redactBody(body), ShouldEqual, `{"meta":{"user":{"password":"[REDACTED]"}},"query":[{"id":"search","value":"[REDACTED]"},{"id":"result","size":10}],"settings":{"userId":"[REDACTED]"}}`)
+		ndjson := "{\"index\":\"books\"}\n{\"query\":[{\"value\":\"harry\"}]}\n"
+		So(r.redactBody(ndjson), ShouldEqual, "{\"index\":\"books\"}\n{\"query\":[{\"value\":\"[REDACTED]\"}]}\n")
+		// the bodies that can't be parsed, e.g. the truncated ones, are dropped
+		So(r.redactBody(`{"query":[{"value":"harry"`), ShouldEqual, redacted)
+		So(r.redactBody("{\"index\":\"books\"}\n{\"query\":[{\"value\":\"harry\""), ShouldEqual, redacted)
+		_, err = compileJSONPath("query.value")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should scrub the emails and the valid card numbers", t, func() {
+		r, err := RedactionPolicy{PII: []string{PIIEmail, PIICard}}.compile()
+		So(err, ShouldBeNil)
+		So(r.redactBody(`{"value":"jane.doe@example.com paid with 4111 1111 1111 1111"}`), ShouldEqual, `{"value":"[REDACTED] paid with [REDACTED]"}`)
+		So(r.redactBody(`{"timestamp":1690000000001}`), ShouldEqual, `{"timestamp":1690000000001}`)
+		_, err = RedactionPolicy{PII: []string{"ssn"}}.compile()
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should apply the policies of the indices along with the global one", t, func() {
+		global, _ := RedactionPolicy{Headers: defaultRedactedHeaders}.compile()
+		books, _ := RedactionPolicy{PII: []string{PIIEmail}}.compile()
+		policies := &redaction{global: global, indices: map[string]*redactor{"books-*": books}}
+		So(policies.forIndices([]string{"books-2021"}).redactBody("jane@example.com"), ShouldEqual, redacted)
+		So(policies.forIndices([]string{"movies"}).redactBody("jane@example.com"), ShouldEqual, "jane@example.com")
+		So(policies.forIndices([]string{"books-2021"}).headers["Authorization"], ShouldBeTrue)
+	})
+}
@@ -0,0 +1,277 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/plugins"
+	"github.com/appbaseio/reactivesearch-api/plugins/auth"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// replayRequest are the options of a replay, the log is always replayed as the
+// admin user replaying it.
+type replayRequest struct {
+	// DryRun stops the replayed request before it reaches elasticsearch
+	DryRun bool `json:"dryRun"`
+	// AllowWrites replays the logs of the requests that aren't read-only, e.g.
+	// the document writes, the _bulk requests and the deletes
+	AllowWrites bool `json:"allowWrites"`
+}
+
+// replayedResponse is a side of the diff of a replay.
+type replayedResponse struct {
+	Code int    `json:"code"`
+	Body string `json:"body"`
+	// Took is the time taken in milliseconds
+	Took *float64 `json:"took,omitempty"`
+}
+
+// replayResult compares the original response of a log with its replay.
+type replayResult struct {
+	ID       string            `json:"id"`
+	DryRun   bool              `json:"dryRun"`
+	Method   string            `json:"method"`
+	URI      string            `json:"uri"`
+	Original replayedResponse  `json:"original"`
+	Replayed *replayedResponse `json:"replayed"`
+	// Diff is the delta of the replayed body from the original one
+	Diff        string          `json:"diff"`
+	CodeChanged bool            `json:"codeChanged"`
+	Report      *explain.Report `json:"report,omitempty"`
+}
+
+// replayRouteName is the name of the replay route, which can't be replayed.
+const replayRouteName = "Replay log"
+
+// skippedReplayHeaders are set from the replayed request instead of the log,
+// the logged credentials are never replayed.
+var skippedReplayHeaders = []string{
+	"Content-Length", "Connection", "Accept-Encoding", "Authorization",
+	auth.HeaderSignatureKeyID, auth.HeaderSignatureTimestamp, auth.HeaderSignature,
+}
+
+// readOnlySegments are the path segments of the endpoints that read the data
+// whatever the method of the request.
+var readOnlySegments = map[string]bool{
+	"_search": true, "_msearch": true, "_count": true, "_validate": true, "_explain": true,
+	"_mget": true, "_field_caps": true, "_termvectors": true, "_mtermvectors": true,
+	"_rank_eval": true, "_analyze": true, "_reactivesearch": true, "_reactivesearch.v3": true,
+}
+
+// isReadOnlyRequest returns true if the logged request only reads the data,
+// i.e. it's a GET or a HEAD request or it's sent to a search endpoint.
+func isReadOnlyRequest(method, uri string) bool {
+	if method == http.MethodGet || method == http.MethodHead {
+		return true
+	}
+	if method != http.MethodPost {
+		return false
+	}
+	path := strings.SplitN(uri, "?", 2)[0]
+	for _, segment := range strings.Split(path, "/") {
+		if readOnlySegments[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedReplayOptions would replay the log as another credential, which
+// isn't supported.
+var unsupportedReplayOptions = []string{"username", "password"}
+
+// checkReplayOptions rejects the options replaying the log as another credential
+// than the caller.
+func checkReplayOptions(body []byte) error {
+	var options map[string]interface{}
+	if err := json.Unmarshal(body, &options); err != nil {
+		return err
+	}
+	for _, option := range unsupportedReplayOptions {
+		if _, ok := options[option]; ok {
+			return fmt.Errorf(`"%s" isn't supported, the log is always replayed as the caller`, option)
+		}
+	}
+	return nil
+}
+
+// checkReplayableBody checks that the logged request body is the one that was
+// sent, the redacted and the truncated bodies would replay a different request.
+func checkReplayableBody(body string) error {
+	if len(body) >= maxBodySize {
+		return fmt.Errorf("the request body of the log is truncated, it can't be replayed")
+	}
+	if strings.Contains(body, redacted) {
+		return fmt.Errorf("the request body of the log is redacted, it can't be replayed")
+	}
+	return nil
+}
+
+// newReplayRequest returns the request of the log authenticated with the basic
+// auth of the verified caller replaying the log.
+func newReplayRequest(ctx context.Context, rec record, username, password string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, rec.Request.Method, rec.Request.URI, strings.NewReader(rec.Request.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = rec.Request.URI
+	for key, values := range rec.Request.Headers {
+		// the redacted headers are dropped rather than sent with the placeholder
+		if util.Contains(values, redacted) {
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	for _, key := range skippedReplayHeaders {
+		req.Header.Del(key)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	req.Header.Set("X-Enable-Telemetry", "false")
+	return req, nil
+}
+
+func (l *Logs) replayLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logID := mux.Vars(req)["id"]
+
+		var replay replayRequest
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &replay); err != nil {
+				msg := "can't parse request body"
+				log.Errorln(logTag, ":", msg, ":", err)
+				util.WriteBackError(w, msg, http.StatusBadRequest)
+				return
+			}
+			if err := checkReplayOptions(body); err != nil {
+				util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		raw, logErr := l.es.getRawLog(req.Context(), logID, false)
+		if logErr != nil {
+			log.Warnln(logTag, logErr.Err.Error())
+			telemetry.WriteBackErrorWithTelemetry(req, w, logErr.Err.Error(), logErr.Code)
+			return
+		}
+		var rec record
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while parsing the log", http.StatusInternalServerError)
+			return
+		}
+
+		if !replay.AllowWrites && !isReadOnlyRequest(rec.Request.Method, rec.Request.URI) {
+			msg := fmt.Sprintf(`%s %s isn't a read-only request, set "allowWrites" to replay it`, rec.Request.Method, rec.Request.URI)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if err := checkReplayableBody(rec.Request.Body); err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the admin users can only be authenticated with their basic auth, the
+		// replayed request is authenticated as the same user
+		reqUser, err := user.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while replaying the log", http.StatusInternalServerError)
+			return
+		}
+		username, password, ok := req.BasicAuth()
+		if !ok || username != reqUser.Username {
+			util.WriteBackError(w, "the logs can only be replayed with the basic auth of an admin user", http.StatusUnauthorized)
+			return
+		}
+
+		// the replayed request doesn't inherit the context of the replay request
+		ctx := context.Background()
+		var report *explain.Report
+		if replay.DryRun {
+			report = explain.NewReport()
+			ctx = explain.NewContext(ctx, report)
+		}
+		replayed, err := newReplayRequest(ctx, rec, username, password)
+		if err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		replayed.RemoteAddr = req.RemoteAddr
+
+		router := plugins.RouterSwapperInstance().Router()
+		var match mux.RouteMatch
+		if router == nil || !router.Match(replayed, &match) || match.Route == nil {
+			msg := fmt.Sprintf("no route matches %s %s", replayed.Method, rec.Request.URI)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+		if match.Route.GetName() == replayRouteName {
+			util.WriteBackError(w, "a replay can't be replayed", http.StatusBadRequest)
+			return
+		}
+
+		result := replayResult{
+			ID:     logID,
+			DryRun: replay.DryRun,
+			Method: rec.Request.Method,
+			URI:    rec.Request.URI,
+			Original: replayedResponse{
+				Code: rec.Response.Code,
+				Body: rec.Response.Body,
+				Took: rec.Response.Took,
+			},
+			Report: report,
+		}
+		if template, err := match.Route.GetPathTemplate(); err == nil && report != nil {
+			report.Route = template
+		}
+
+		respRecorder := httptest.NewRecorder()
+		start := time.Now()
+		router.ServeHTTP(respRecorder, replayed)
+		took := float64(time.Since(start).Milliseconds())
+
+		// a dry run doesn't have a response unless a middleware rejected it
+		if !replay.DryRun || respRecorder.Body.Len() > 0 {
+			result.Replayed = &replayedResponse{
+				Code: respRecorder.Code,
+				Body: respRecorder.Body.String(),
+				Took: &took,
+			}
+			result.CodeChanged = result.Replayed.Code != result.Original.Code
+			result.Diff = util.CalculateBodyStringDiff(result.Original.Body, result.Replayed.Body)
+		}
+
+		raw, err = json.Marshal(result)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while marshalling the replay", http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
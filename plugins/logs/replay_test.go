@@ -0,0 +1,70 @@
+package logs
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplay(t *testing.T) {
+	rec := record{Request: Request{
+		URI:    "/books/_search",
+		Method: "POST",
+		Headers: map[string][]string{
+			"authorization":  {redacted},
+			"Content-Length": {"42"},
+			"X-Search-Id":    {"abc"},
+			"Cookie":         {redacted},
+		},
+		Body: `{"query":{"match_all":{}}}`,
+	}}
+	Convey("should replay the log with the credential of the caller", t, func() {
+		req, err := newReplayRequest(context.Background(), rec, "foo", "bar")
+		So(err, ShouldBeNil)
+		So(req.Method, ShouldEqual, "POST")
+		So(req.URL.Path, ShouldEqual, "/books/_search")
+		So(req.Header.Get("Authorization"), ShouldEqual, "Basic Zm9vOmJhcg==")
+		So(req.Header.Get("X-Search-Id"), ShouldEqual, "abc")
+		So(req.Header.Get("Content-Length"), ShouldEqual, "")
+		So(req.Header.Get("Cookie"), ShouldEqual, "")
+		body, _ := ioutil.ReadAll(req.Body)
+		So(string(body), ShouldEqual, rec.Request.Body)
+	})
+	Convey("should never replay the logged credential", t, func() {
+		logged := rec
+		logged.Request.Headers = map[string][]string{
+			"Authorization":         {"Basic c3RvcmVkOmNyZWRlbnRpYWw="},
+			"X-Signature-Key-Id":    {"jane:key"},
+			"X-Signature-Timestamp": {"1690000000"},
+			"X-Signature":           {"abc"},
+		}
+		req, err := newReplayRequest(context.Background(), logged, "", "")
+		So(err, ShouldBeNil)
+		So(req.Header.Get("Authorization"), ShouldEqual, "")
+		So(req.Header.Get("X-Signature-Key-Id"), ShouldEqual, "")
+		So(req.Header.Get("X-Signature"), ShouldEqual, "")
+	})
+	Convey("should reject the options replaying as another credential", t, func() {
+		So(checkReplayOptions([]byte(`{"dryRun":true}`)), ShouldBeNil)
+		So(checkReplayOptions([]byte(`{"username":"jane","password":"secret"}`)), ShouldNotBeNil)
+	})
+	Convey("should refuse the redacted and the truncated bodies", t, func() {
+		So(checkReplayableBody(rec.Request.Body), ShouldBeNil)
+		So(checkReplayableBody(`{"query":{"match":{"email":"[REDACTED]"}}}`), ShouldNotBeNil)
+		So(checkReplayableBody(strings.Repeat("a", maxBodySize)), ShouldNotBeNil)
+	})
+	Convey("should tell apart the read-only requests", t, func() {
+		So(isReadOnlyRequest("POST", "/books/_search?size=10"), ShouldBeTrue)
+		So(isReadOnlyRequest("POST", "/_msearch"), ShouldBeTrue)
+		So(isReadOnlyRequest("POST", "/books/_reactivesearch.v3"), ShouldBeTrue)
+		So(isReadOnlyRequest("GET", "/books/_doc/1"), ShouldBeTrue)
+		So(isReadOnlyRequest("POST", "/books/_doc"), ShouldBeFalse)
+		So(isReadOnlyRequest("POST", "/_bulk"), ShouldBeFalse)
+		So(isReadOnlyRequest("POST", "/books/_delete_by_query"), ShouldBeFalse)
+		So(isReadOnlyRequest("DELETE", "/books"), ShouldBeFalse)
+		So(isReadOnlyRequest("PUT", "/books/_search"), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,694 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/util"
+	es7 "github.com/olivere/elastic/v7"
+)
+
+const (
+	envRetentionEsIndex     = "LOGS_RETENTION_ES_INDEX"
+	defaultRetentionEsIndex = ".logs_retention"
+	retentionDocID          = "_retention"
+)
+
+// The lifecycles applying the retention policy of the logs.
+const (
+	// lifecycleAuto uses ILM or ISM when available and the cron job otherwise
+	lifecycleAuto = "auto"
+	lifecycleILM  = "ilm"
+	lifecycleISM  = "ism"
+	lifecycleCron = "cron"
+)
+
+// errUnsupportedLifecycle is returned when the cluster doesn't support the
+// lifecycle of the retention policy.
+var errUnsupportedLifecycle = errors.New("unsupported lifecycle")
+
+var (
+	agePattern  = regexp.MustCompile(`^([1-9][0-9]*)(d|h|m|s)$`)
+	sizePattern = regexp.MustCompile(`^([1-9][0-9]*)(b|kb|mb|gb|tb)$`)
+	sizeUnits   = map[string]int64{"b": 1, "kb": 1 << 10, "mb": 1 << 20, "gb": 1 << 30, "tb": 1 << 40}
+)
+
+// RolloverConditions roll the logs over to a new index once any of them is met.
+type RolloverConditions struct {
+	MaxAge  string `json:"max_age,omitempty"`
+	MaxDocs int64  `json:"max_docs,omitempty"`
+	MaxSize string `json:"max_size,omitempty"`
+}
+
+// RetentionPolicy sets when the logs roll over to a new index and how long
+// the rolled over indices are kept. The write index is never deleted.
+type RetentionPolicy struct {
+	Rollover RolloverConditions `json:"rollover"`
+	// MaxAge deletes the indices older than it, e.g. "90d"
+	MaxAge string `json:"max_age,omitempty"`
+	// MaxSize deletes the oldest indices once all the indices are larger, e.g. "50gb"
+	MaxSize string `json:"max_size,omitempty"`
+	// MaxIndices is the number of indices kept, including the write index, 0 keeps all of them
+	MaxIndices int `json:"max_indices,omitempty"`
+	// Lifecycle is "ilm", "ism", "cron" or "auto"
+	Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// backingIndex is an index of the logs alias.
+type backingIndex struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	// Size is the store size in bytes
+	Size int64 `json:"size"`
+}
+
+// retentionStatus is the retention policy along with the lifecycle applying
+// it and the indices of the logs.
+type retentionStatus struct {
+	Policy    RetentionPolicy `json:"policy"`
+	Lifecycle string          `json:"lifecycle"`
+	Indices   []backingIndex  `json:"indices"`
+}
+
+// parseAge parses the elasticsearch time units supported for the ages, e.g. "30d".
+func parseAge(value string) (time.Duration, error) {
+	matches := agePattern.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf(`invalid age "%s", must be a number followed by "d", "h", "m" or "s"`, value)
+	}
+	amount, _ := strconv.Atoi(matches[1])
+	unit := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour, "m": time.Minute, "s": time.Second}[matches[2]]
+	return time.Duration(amount) * unit, nil
+}
+
+// parseSize parses the elasticsearch byte units, e.g. "10gb".
+func parseSize(value string) (int64, error) {
+	matches := sizePattern.FindStringSubmatch(strings.ToLower(value))
+	if matches == nil {
+		return 0, fmt.Errorf(`invalid size "%s", must be a number followed by "b", "kb", "mb", "gb" or "tb"`, value)
+	}
+	amount, _ := strconv.ParseInt(matches[1], 10, 64)
+	return amount * sizeUnits[matches[2]], nil
+}
+
+// defaultRetention returns the retention policy of the LOGS_ROLLOVER_* and
+// LOGS_RETENTION_* env vars, which defaults to the rollover conditions of the
+// plan and the last 2 indices.
+func defaultRetention() (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		Rollover:   RolloverConditions{MaxAge: "7d", MaxDocs: 10000, MaxSize: "1gb"},
+		MaxIndices: 2,
+		Lifecycle:  lifecycleAuto,
+	}
+	if util.IsProductionPlan() {
+		policy.Rollover = RolloverConditions{MaxAge: "30d", MaxDocs: 1000000, MaxSize: "10gb"}
+	}
+	if err := policy.readEnv(); err != nil {
+		return policy, err
+	}
+	return policy, policy.validate()
+}
+
+// readEnv overrides the policy with the LOGS_ROLLOVER_* and LOGS_RETENTION_* env vars.
+func (p *RetentionPolicy) readEnv() error {
+	for name, value := range map[string]*string{
+		"LOGS_ROLLOVER_MAX_AGE":    &p.Rollover.MaxAge,
+		"LOGS_ROLLOVER_MAX_SIZE":   &p.Rollover.MaxSize,
+		"LOGS_RETENTION_MAX_AGE":   &p.MaxAge,
+		"LOGS_RETENTION_MAX_SIZE":  &p.MaxSize,
+		"LOGS_RETENTION_LIFECYCLE": &p.Lifecycle,
+	} {
+		if env, ok := os.LookupEnv(name); ok {
+			*value = strings.TrimSpace(env)
+		}
+	}
+	if env := os.Getenv("LOGS_ROLLOVER_MAX_DOCS"); env != "" {
+		maxDocs, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid LOGS_ROLLOVER_MAX_DOCS %s: %v", env, err)
+		}
+		p.Rollover.MaxDocs = maxDocs
+	}
+	if env := os.Getenv("LOGS_RETENTION_MAX_INDICES"); env != "" {
+		maxIndices, err := strconv.Atoi(env)
+		if err != nil {
+			return fmt.Errorf("invalid LOGS_RETENTION_MAX_INDICES %s: %v", env, err)
+		}
+		p.MaxIndices = maxIndices
+	}
+	return nil
+}
+
+// validate checks the units of the policy, the lifecycle defaults to "auto".
+func (p *RetentionPolicy) validate() error {
+	if p.Rollover.MaxAge == "" && p.Rollover.MaxDocs == 0 && p.Rollover.MaxSize == "" {
+		return fmt.Errorf(`"rollover" must have at least one of "max_age", "max_docs" or "max_size"`)
+	}
+	for _, age := range []string{p.Rollover.MaxAge, p.MaxAge} {
+		if age != "" {
+			if _, err := parseAge(age); err != nil {
+				return err
+			}
+		}
+	}
+	for _, size := range []string{p.Rollover.MaxSize, p.MaxSize} {
+		if size != "" {
+			if _, err := parseSize(size); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Rollover.MaxDocs < 0 {
+		return fmt.Errorf(`"max_docs" can't be negative`)
+	}
+	if p.MaxIndices < 0 {
+		return fmt.Errorf(`"max_indices" can't be negative`)
+	}
+	switch p.Lifecycle {
+	case "":
+		p.Lifecycle = lifecycleAuto
+	case lifecycleAuto, lifecycleILM, lifecycleISM, lifecycleCron:
+	default:
+		return fmt.Errorf(`invalid lifecycle "%s", must be "auto", "ilm", "ism" or "cron"`, p.Lifecycle)
+	}
+	return nil
+}
+
+// conditions returns the rollover conditions of the rollover API and ILM.
+func (c RolloverConditions) conditions() map[string]interface{} {
+	conditions := make(map[string]interface{})
+	if c.MaxAge != "" {
+		conditions["max_age"] = c.MaxAge
+	}
+	if c.MaxDocs > 0 {
+		conditions["max_docs"] = c.MaxDocs
+	}
+	if c.MaxSize != "" {
+		conditions["max_size"] = c.MaxSize
+	}
+	return conditions
+}
+
+// expiredIndices returns the indices to delete, from the oldest to the most
+// recent one. The age of the indices is only checked when the lifecycle
+// doesn't delete them.
+func expiredIndices(indices []backingIndex, policy RetentionPolicy, now time.Time, checkAge bool) []string {
+	sorted := make([]backingIndex, len(indices))
+	copy(sorted, indices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	expired := make([]bool, len(sorted))
+	if policy.MaxIndices > 0 {
+		for i := 0; i < len(sorted)-policy.MaxIndices; i++ {
+			expired[i] = true
+		}
+	}
+	if maxAge, err := parseAge(policy.MaxAge); err == nil && checkAge {
+		for i, index := range sorted {
+			if now.Sub(index.Created) > maxAge {
+				expired[i] = true
+			}
+		}
+	}
+	if maxSize, err := parseSize(policy.MaxSize); err == nil {
+		var total int64
+		for i, index := range sorted {
+			if !expired[i] {
+				total += index.Size
+			}
+		}
+		for i := 0; i < len(sorted) && total > maxSize; i++ {
+			if !expired[i] {
+				expired[i] = true
+				total -= sorted[i].Size
+			}
+		}
+	}
+
+	names := make([]string, 0)
+	// the last index is the write index
+	for i := 0; i < len(sorted)-1; i++ {
+		if expired[i] {
+			names = append(names, sorted[i].Name)
+		}
+	}
+	return names
+}
+
+// lifecycleName is the name of the ILM and ISM policies and of the index template.
+func (es *elasticsearch) lifecycleName() string {
+	return strings.TrimPrefix(es.indexName, ".") + "-retention"
+}
+
+func (es *elasticsearch) performRequest(ctx context.Context, method, path string, body interface{}) (*es7.Response, error) {
+	return util.GetClient7().PerformRequest(ctx, es7.PerformRequestOptions{
+		Method: method,
+		Path:   path,
+		Body:   body,
+	})
+}
+
+// ilmAvailable returns true if the cluster has index lifecycle management.
+func (es *elasticsearch) ilmAvailable(ctx context.Context) bool {
+	_, err := es.performRequest(ctx, http.MethodGet, "/_ilm/status", nil)
+	return err == nil
+}
+
+// ismPath returns the path of the ISM API of OpenSearch or of Open Distro,
+// or an empty string if the cluster doesn't have index state management.
+func (es *elasticsearch) ismPath(ctx context.Context) string {
+	for _, path := range []string{"/_plugins/_ism", "/_opendistro/_ism"} {
+		if _, err := es.performRequest(ctx, http.MethodGet, path+"/explain/"+es.indexName, nil); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// initRetention creates the index of the retention policy and applies it.
+func (es *elasticsearch) initRetention(ctx context.Context) error {
+	if err := createSettingsIndex(ctx, es.retentionIndex); err != nil {
+		return err
+	}
+	policy, err := es.getRetentionPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := es.applyRetention(ctx, policy); err != nil {
+		log.Warnln(logTag, ": error while applying the retention policy, the cron job applies it instead:", err)
+	}
+	return nil
+}
+
+// getRetentionPolicy returns the saved retention policy, or the default one.
+func (es *elasticsearch) getRetentionPolicy(ctx context.Context) (RetentionPolicy, error) {
+	response, err := util.GetClient7().Get().
+		Index(es.retentionIndex).
+		Id(retentionDocID).
+		Do(ctx)
+	if es7.IsNotFound(err) {
+		return defaultRetention()
+	}
+	if err != nil {
+		return RetentionPolicy{}, err
+	}
+	var policy RetentionPolicy
+	if err := json.Unmarshal(response.Source, &policy); err != nil {
+		return RetentionPolicy{}, err
+	}
+	return policy, nil
+}
+
+// retentionIndices returns the indices of the logs alias.
+func (es *elasticsearch) retentionIndices(ctx context.Context) ([]backingIndex, error) {
+	rows, err := util.GetClient7().CatIndices().
+		Index(es.indexName + "-*").
+		Bytes("b").
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pattern := regexp.MustCompile(fmt.Sprintf("^%s-[0-9]+$", regexp.QuoteMeta(es.indexName)))
+	indices := make([]backingIndex, 0)
+	for _, row := range rows {
+		if !pattern.MatchString(row.Index) {
+			continue
+		}
+		size, _ := strconv.ParseInt(row.StoreSize, 10, 64)
+		indices = append(indices, backingIndex{
+			Name:    row.Index,
+			Created: time.Unix(0, row.CreationDate*int64(time.Millisecond)).UTC(),
+			Size:    size,
+		})
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Name < indices[j].Name })
+	return indices, nil
+}
+
+func (es *elasticsearch) getRetention(ctx context.Context) (*retentionStatus, error) {
+	policy, err := es.getRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := es.retentionIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	es.retentionMu.Lock()
+	lifecycle := es.lifecycle
+	es.retentionMu.Unlock()
+	return &retentionStatus{Policy: policy, Lifecycle: lifecycle, Indices: indices}, nil
+}
+
+// putRetention applies the retention policy and saves it once applied, the
+// previous policy is applied again if either fails.
+func (es *elasticsearch) putRetention(ctx context.Context, policy RetentionPolicy) (*retentionStatus, error) {
+	previous, err := es.getRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := es.applyRetention(ctx, policy); err != nil {
+		es.rollbackRetention(ctx, previous)
+		return nil, err
+	}
+	_, err = util.GetClient7().Index().
+		Index(es.retentionIndex).
+		Id(retentionDocID).
+		BodyJson(policy).
+		Refresh("wait_for").
+		Do(ctx)
+	if err != nil {
+		es.rollbackRetention(ctx, previous)
+		return nil, err
+	}
+	return es.getRetention(ctx)
+}
+
+// rollbackRetention applies the previous retention policy again, e.g. after
+// the ILM policy of a new one was put but not its index template.
+func (es *elasticsearch) rollbackRetention(ctx context.Context, previous RetentionPolicy) {
+	if _, err := es.applyRetention(ctx, previous); err != nil {
+		log.Errorln(logTag, ": error while applying the previous retention policy again:", err)
+	}
+}
+
+// applyRetention puts the ILM or ISM policy along with the index template of
+// the logs indices, or removes them when the cron job applies the policy.
+func (es *elasticsearch) applyRetention(ctx context.Context, policy RetentionPolicy) (string, error) {
+	lifecycle := policy.Lifecycle
+	ismPath := ""
+	if lifecycle == lifecycleAuto || lifecycle == lifecycleISM {
+		ismPath = es.ismPath(ctx)
+	}
+	if lifecycle == lifecycleAuto {
+		switch {
+		case es.ilmAvailable(ctx):
+			lifecycle = lifecycleILM
+		case ismPath != "":
+			lifecycle = lifecycleISM
+		default:
+			lifecycle = lifecycleCron
+		}
+	}
+
+	var err error
+	switch lifecycle {
+	case lifecycleILM:
+		if policy.Lifecycle == lifecycleILM && !es.ilmAvailable(ctx) {
+			return "", fmt.Errorf("%w: the cluster doesn't support index lifecycle management", errUnsupportedLifecycle)
+		}
+		err = es.applyILM(ctx, policy)
+	case lifecycleISM:
+		if ismPath == "" {
+			return "", fmt.Errorf("%w: the cluster doesn't support index state management", errUnsupportedLifecycle)
+		}
+		err = es.applyISM(ctx, ismPath, policy)
+	default:
+		es.removeLifecycle(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	es.retentionMu.Lock()
+	es.lifecycle = lifecycle
+	es.appliedRetention = &policy
+	es.retentionMu.Unlock()
+	log.Println(logTag, ": retention policy applied with", lifecycle)
+	return lifecycle, nil
+}
+
+func (es *elasticsearch) applyILM(ctx context.Context, policy RetentionPolicy) error {
+	phases := map[string]interface{}{
+		"hot": map[string]interface{}{
+			"actions": map[string]interface{}{
+				"rollover": policy.Rollover.conditions(),
+			},
+		},
+	}
+	if policy.MaxAge != "" {
+		phases["delete"] = map[string]interface{}{
+			"min_age": policy.MaxAge,
+			"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+		}
+	}
+	body := map[string]interface{}{"policy": map[string]interface{}{"phases": phases}}
+	if _, err := es.performRequest(ctx, http.MethodPut, "/_ilm/policy/"+es.lifecycleName(), body); err != nil {
+		return err
+	}
+	settings := map[string]interface{}{
+		"index.lifecycle.name":           es.lifecycleName(),
+		"index.lifecycle.rollover_alias": es.indexName,
+	}
+	if err := es.putLifecycleTemplate(ctx, settings); err != nil {
+		return err
+	}
+	_, err := es.performRequest(ctx, http.MethodPut, "/"+es.indexName+"-*/_settings?expand_wildcards=all", settings)
+	return err
+}
+
+func (es *elasticsearch) applyISM(ctx context.Context, ismPath string, policy RetentionPolicy) error {
+	conditions := make(map[string]interface{})
+	if policy.Rollover.MaxAge != "" {
+		conditions["min_index_age"] = policy.Rollover.MaxAge
+	}
+	if policy.Rollover.MaxDocs > 0 {
+		conditions["min_doc_count"] = policy.Rollover.MaxDocs
+	}
+	if policy.Rollover.MaxSize != "" {
+		conditions["min_size"] = policy.Rollover.MaxSize
+	}
+	hot := map[string]interface{}{
+		"name":        "hot",
+		"actions":     []interface{}{map[string]interface{}{"rollover": conditions}},
+		"transitions": []interface{}{},
+	}
+	states := []interface{}{hot}
+	if policy.MaxAge != "" {
+		hot["transitions"] = []interface{}{map[string]interface{}{
+			"state_name": "delete",
+			"conditions": map[string]interface{}{"min_index_age": policy.MaxAge},
+		}}
+		states = append(states, map[string]interface{}{
+			"name":        "delete",
+			"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+			"transitions": []interface{}{},
+		})
+	}
+	body := map[string]interface{}{
+		"policy": map[string]interface{}{
+			"description":   "Retention of the " + es.indexName + " indices",
+			"default_state": "hot",
+			"states":        states,
+			"ism_template": []interface{}{map[string]interface{}{
+				"index_patterns": []string{es.indexName + "-*"},
+				"priority":       100,
+			}},
+		},
+	}
+
+	// an existing policy is only updated with its sequence number
+	path := ismPath + "/policies/" + es.lifecycleName()
+	response, err := es.performRequest(ctx, http.MethodGet, path, nil)
+	if err == nil {
+		var existing struct {
+			SeqNo       int64 `json:"_seq_no"`
+			PrimaryTerm int64 `json:"_primary_term"`
+		}
+		if err := json.Unmarshal(response.Body, &existing); err != nil {
+			return err
+		}
+		path = fmt.Sprintf("%s?if_seq_no=%d&if_primary_term=%d", path, existing.SeqNo, existing.PrimaryTerm)
+	} else if !es7.IsNotFound(err) {
+		return err
+	}
+	if _, err := es.performRequest(ctx, http.MethodPut, path, body); err != nil {
+		return err
+	}
+
+	prefix := strings.TrimPrefix(strings.Split(ismPath, "/")[1], "_")
+	settings := map[string]interface{}{
+		prefix + ".index_state_management.rollover_alias": es.indexName,
+	}
+	if err := es.putLifecycleTemplate(ctx, settings); err != nil {
+		return err
+	}
+	if _, err := es.performRequest(ctx, http.MethodPut, "/"+es.indexName+"-*/_settings?expand_wildcards=all", settings); err != nil {
+		return err
+	}
+	// the indices already managed by the policy are reported as failures
+	_, err = es.performRequest(ctx, http.MethodPost, ismPath+"/add/"+es.indexName+"-*", map[string]interface{}{
+		"policy_id": es.lifecycleName(),
+	})
+	return err
+}
+
+// putLifecycleTemplate puts the index template of the indices created by the
+// rollovers of ILM and ISM, along with the lifecycle settings.
+func (es *elasticsearch) putLifecycleTemplate(ctx context.Context, lifecycleSettings map[string]interface{}) error {
+	settingsString := fmt.Sprintf(`{%s "index.number_of_shards": 1, "index.number_of_replicas": %d}`, util.HiddenIndexSettings(), util.GetReplicas())
+	settings := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(settingsString), &settings); err != nil {
+		return err
+	}
+	for key, value := range lifecycleSettings {
+		settings[key] = value
+	}
+	mappings := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(LogsMappings), &mappings); err != nil {
+		return err
+	}
+	patterns := []string{es.indexName + "-*"}
+
+	if util.GetVersion() == 6 {
+		_, err := util.GetClient7().IndexPutTemplate(es.lifecycleName()).BodyJson(map[string]interface{}{
+			"index_patterns": patterns,
+			"settings":       settings,
+			"mappings":       map[string]interface{}{"_doc": mappings},
+			"order":          30,
+		}).Do(ctx)
+		return err
+	}
+	// the composable template takes precedence over the system template
+	_, err := util.GetClient7().IndexPutIndexTemplate(es.lifecycleName()).BodyJson(map[string]interface{}{
+		"index_patterns": patterns,
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": mappings,
+		},
+		"priority": 200,
+	}).Do(ctx)
+	if err != nil {
+		// Index template is supported from v7.8
+		log.Debug(logTag, ": error while creating the index template, trying to create legacy template: ", err)
+		_, err = util.GetClient7().IndexPutTemplate(es.lifecycleName()).BodyJson(map[string]interface{}{
+			"index_patterns": patterns,
+			"settings":       settings,
+			"mappings":       mappings,
+			"order":          30,
+		}).Do(ctx)
+	}
+	return err
+}
+
+// removeLifecycle removes the index template and detaches the indices from
+// the ILM and ISM policies, so that the cron job is the only one rolling the
+// logs over.
+func (es *elasticsearch) removeLifecycle(ctx context.Context) {
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodDelete, "/_index_template/" + es.lifecycleName()},
+		{http.MethodDelete, "/_template/" + es.lifecycleName()},
+		{http.MethodPost, "/" + es.indexName + "-*/_ilm/remove"},
+		{http.MethodPost, "/_plugins/_ism/remove/" + es.indexName + "-*"},
+		{http.MethodPost, "/_opendistro/_ism/remove/" + es.indexName + "-*"},
+	}
+	for _, request := range requests {
+		if _, err := es.performRequest(ctx, request.method, request.path, nil); err != nil {
+			log.Debugln(logTag, ": ", request.method, request.path, ":", err)
+		}
+	}
+}
+
+// rolloverIndexJob rolls the logs over when the cron job applies the
+// retention policy and deletes the expired indices.
+func (es *elasticsearch) rolloverIndexJob(alias string) {
+	ctx := context.Background()
+
+	policy, err := es.getRetentionPolicy(ctx)
+	if err != nil {
+		log.Errorln(logTag, ": rollover cronjob error getting the retention policy", err)
+		return
+	}
+	// the policy may have been changed on another node
+	es.retentionMu.Lock()
+	lifecycle, applied := es.lifecycle, es.appliedRetention
+	es.retentionMu.Unlock()
+	if applied == nil || *applied != policy {
+		if lifecycle, err = es.applyRetention(ctx, policy); err != nil {
+			log.Errorln(logTag, ": rollover cronjob error applying the retention policy", err)
+			lifecycle = lifecycleCron
+		}
+	}
+
+	if lifecycle == lifecycleCron {
+		es.rollover(ctx, alias, policy.Rollover)
+	} else {
+		// the lifecycle rolls the logs over
+		res, err := util.GetClient7().Aliases().Index(alias).Do(ctx)
+		if err != nil {
+			log.Errorln(logTag, ": rollover cronjob error getting the write index", err)
+		} else {
+			for index, indexAliases := range res.Indices {
+				for _, indexAlias := range indexAliases.Aliases {
+					if indexAlias.AliasName == alias && indexAlias.IsWriteIndex {
+						classify.SetIndexAlias(index, alias)
+						classify.SetAliasIndex(alias, index)
+					}
+				}
+			}
+		}
+	}
+
+	// We cannot rely on rollover service response here,
+	// Because it returns rollover as false when we restart ReactiveSearch.
+	indices, err := es.retentionIndices(ctx)
+	if err != nil {
+		log.Errorln(logTag, ": rollover cronjob error getting indices", err)
+		return
+	}
+	expired := expiredIndices(indices, policy, time.Now(), lifecycle == lifecycleCron)
+	if len(expired) > 0 {
+		log.Println(logTag, ": rollover cronjob, indices to delete", expired)
+		_, err = util.GetClient7().DeleteIndex(strings.Join(expired, ",")).Do(ctx)
+		if err != nil {
+			log.Errorln(logTag, ": rollover cronjob, error while deleting indices", err)
+		}
+	}
+}
+
+func (es *elasticsearch) rollover(ctx context.Context, alias string, conditions RolloverConditions) {
+	settingsString := fmt.Sprintf(`{%s "index.number_of_shards": 1, "index.number_of_replicas": %d}`, util.HiddenIndexSettings(), util.GetReplicas())
+	settings := make(map[string]interface{})
+	json.Unmarshal([]byte(settingsString), &settings)
+
+	mappingString := LogsMappings
+	if util.GetVersion() == 6 {
+		mappingString = fmt.Sprintf(`{"_doc": %s}`, LogsMappings)
+	}
+
+	mappings := make(map[string]interface{})
+	json.Unmarshal([]byte(mappingString), &mappings)
+	rolloverService, err := es7.NewIndicesRolloverService(util.GetClient7()).
+		Alias(alias).
+		Conditions(conditions.conditions()).
+		Settings(settings).
+		Mappings(mappings).
+		Do(ctx)
+	if err != nil {
+		log.Println(logTag, "error while creating a rollover service", alias, err)
+		return
+	}
+	log.Println(logTag, ": rollover res oldIndex", rolloverService.OldIndex)
+	log.Println(logTag, ": rollover res newIndex", rolloverService.NewIndex)
+	log.Println(logTag, ": rollover res isRolledover", rolloverService.RolledOver)
+
+	if rolloverService.RolledOver {
+		classify.SetIndexAlias(rolloverService.NewIndex, alias)
+		classify.SetAliasIndex(alias, rolloverService.NewIndex)
+	}
+}
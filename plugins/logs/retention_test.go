@@ -0,0 +1,56 @@
+package logs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetention(t *testing.T) {
+	Convey("should validate the retention policy", t, func() {
+		policy := RetentionPolicy{Rollover: RolloverConditions{MaxAge: "7d"}, MaxSize: "50GB"}
+		So(policy.validate(), ShouldBeNil)
+		So(policy.Lifecycle, ShouldEqual, lifecycleAuto)
+		for _, invalid := range []RetentionPolicy{
+			{},
+			{Rollover: RolloverConditions{MaxAge: "1w"}},
+			{Rollover: RolloverConditions{MaxDocs: 10}, MaxSize: "10"},
+			{Rollover: RolloverConditions{MaxDocs: 10}, MaxIndices: -1},
+			{Rollover: RolloverConditions{MaxDocs: 10}, Lifecycle: "slm"},
+		} {
+			So(invalid.validate(), ShouldNotBeNil)
+		}
+	})
+	Convey("should read the policy from the env", t, func() {
+		for name, value := range map[string]string{
+			"LOGS_ROLLOVER_MAX_DOCS":     "500",
+			"LOGS_RETENTION_MAX_AGE":     "90d",
+			"LOGS_RETENTION_MAX_INDICES": "0",
+		} {
+			os.Setenv(name, value)
+			defer os.Unsetenv(name)
+		}
+		policy := RetentionPolicy{Rollover: RolloverConditions{MaxAge: "7d", MaxDocs: 10000}, MaxIndices: 2}
+		So(policy.readEnv(), ShouldBeNil)
+		So(policy.Rollover.MaxAge, ShouldEqual, "7d")
+		So(policy.Rollover.MaxDocs, ShouldEqual, 500)
+		So(policy.MaxAge, ShouldEqual, "90d")
+		So(policy.MaxIndices, ShouldEqual, 0)
+	})
+	Convey("should delete the expired indices but the write index", t, func() {
+		now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+		indices := []backingIndex{
+			{Name: ".logs-000004", Created: now.AddDate(0, 0, -1), Size: 300},
+			{Name: ".logs-000001", Created: now.AddDate(0, 0, -100), Size: 100},
+			{Name: ".logs-000003", Created: now.AddDate(0, 0, -10), Size: 200},
+			{Name: ".logs-000002", Created: now.AddDate(0, 0, -50), Size: 400},
+		}
+		So(expiredIndices(indices, RetentionPolicy{MaxIndices: 2}, now, true), ShouldResemble, []string{".logs-000001", ".logs-000002"})
+		So(expiredIndices(indices, RetentionPolicy{MaxAge: "30d"}, now, true), ShouldResemble, []string{".logs-000001", ".logs-000002"})
+		So(expiredIndices(indices, RetentionPolicy{MaxAge: "30d"}, now, false), ShouldResemble, []string{})
+		So(expiredIndices(indices, RetentionPolicy{MaxSize: "600b"}, now, true), ShouldResemble, []string{".logs-000001", ".logs-000002"})
+		So(expiredIndices(indices, RetentionPolicy{MaxSize: "1b"}, now, true), ShouldResemble, []string{".logs-000001", ".logs-000002", ".logs-000003"})
+	})
+}
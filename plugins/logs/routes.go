@@ -23,6 +23,13 @@ func (l *Logs) routes() []plugins.Route {
 			HandlerFunc: middleware(l.getLogById()),
 			Description: "Returns the logs for the passed ID, if present",
 		},
+		{
+			Name:        replayRouteName,
+			Methods:     []string{http.MethodPost},
+			Path:        "/_log/{id}/replay",
+			HandlerFunc: middleware(isAdmin(l.replayLog())),
+			Description: "Replays the request of the log with the passed ID and returns the diff of the responses",
+		},
 		{
 			Name:        "Get logs",
 			Methods:     []string{http.MethodGet},
@@ -44,5 +51,61 @@ func (l *Logs) routes() []plugins.Route {
 			HandlerFunc: middleware(l.getSearchLogs()),
 			Description: "Returns the search request logs for the cluster",
 		},
+		{
+			Name:        "Get index logs insights",
+			Methods:     []string{http.MethodGet},
+			Path:        "/{index}/_logs/insights",
+			HandlerFunc: middleware(l.getInsights()),
+			Description: "Returns the request counts, latency percentiles, error rates and slowest queries of the logs for an index",
+		},
+		{
+			Name:        "Get logs insights",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/insights",
+			HandlerFunc: middleware(l.getInsights()),
+			Description: "Returns the request counts, latency percentiles, error rates and slowest queries of the logs for the cluster",
+		},
+		{
+			Name:        "Get logs sampling",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/sampling",
+			HandlerFunc: middleware(l.getSampling()),
+			Description: "Returns the sampling rules of the logs recorder",
+		},
+		{
+			Name:        "Update logs sampling",
+			Methods:     []string{http.MethodPut},
+			Path:        "/_logs/sampling",
+			HandlerFunc: middleware(isAdmin(l.putSampling())),
+			Description: "Replaces the sampling rules of the logs recorder",
+		},
+		{
+			Name:        "Reload logs sampling",
+			Methods:     []string{http.MethodPost},
+			Path:        "/_logs/sampling/_reload",
+			HandlerFunc: middleware(isAdmin(l.reloadSampling())),
+			Description: "Reloads the saved sampling rules of the logs recorder, or the ones of the LOGS_SAMPLING_FILE if none are saved",
+		},
+		{
+			Name:        "Get logs retention",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/retention",
+			HandlerFunc: middleware(l.getRetention()),
+			Description: "Returns the retention policy of the logs along with the indices of the logs",
+		},
+		{
+			Name:        "Update logs retention",
+			Methods:     []string{http.MethodPut},
+			Path:        "/_logs/retention",
+			HandlerFunc: middleware(isAdmin(l.putRetention())),
+			Description: "Replaces the retention policy of the logs and applies it with ILM, ISM or the rollover cron job",
+		},
+		{
+			Name:        "Get log sinks",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_logs/sinks",
+			HandlerFunc: middleware(l.getSinks()),
+			Description: "Returns the log sinks along with their queue depths",
+		},
 	}
 }
@@ -0,0 +1,316 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	es7 "github.com/olivere/elastic/v7"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
+)
+
+const (
+	envSamplingFile        = "LOGS_SAMPLING_FILE"
+	envSamplingEsIndex     = "LOGS_SAMPLING_ES_INDEX"
+	defaultSamplingEsIndex = ".logs_sampling"
+	// samplingDocID is the id of the saved sampling config in the sampling index
+	samplingDocID = "_sampling"
+	// defaultAlwaysLogStatus is the status from which the requests are always logged
+	defaultAlwaysLogStatus = 500
+)
+
+// SamplingRule selects the requests by their category, indices, path and
+// status class, and either drops them or logs them at a rate.
+type SamplingRule struct {
+	Name string `json:"name,omitempty"`
+	// Categories are the categories of the requests, e.g. "search"
+	Categories []string `json:"categories,omitempty"`
+	// Indices are the glob patterns of the indices, a request matches if one of its indices does
+	Indices []string `json:"indices,omitempty"`
+	// Paths are the glob patterns of the paths, e.g. "/_cluster/health"
+	Paths []string `json:"paths,omitempty"`
+	// StatusClasses are the classes of the response status, e.g. "2xx"
+	StatusClasses []string `json:"status_classes,omitempty"`
+	// Rate is the fraction of the requests logged, from 0 to 1
+	Rate *float64 `json:"rate,omitempty"`
+	// Drop drops the requests, even the errors and the slow ones
+	Drop bool `json:"drop,omitempty"`
+}
+
+// SamplingConfig decides which requests get logged.
+type SamplingConfig struct {
+	// DefaultRate is the rate of the requests that don't match a rule, defaults to 1
+	DefaultRate *float64 `json:"default_rate,omitempty"`
+	// AlwaysLogStatus logs the requests with this status or above regardless of the rates,
+	// defaults to 500, -1 disables it
+	AlwaysLogStatus *int `json:"always_log_status,omitempty"`
+	// AlwaysLogTookMs logs the requests slower than this many milliseconds regardless of the rates
+	AlwaysLogTookMs int64 `json:"always_log_took_ms,omitempty"`
+	// Rules are evaluated in order, the first matching rule applies
+	Rules []SamplingRule `json:"rules"`
+}
+
+// defaultSampling logs every request except the health checks.
+func defaultSampling() SamplingConfig {
+	return SamplingConfig{
+		Rules: []SamplingRule{
+			{Name: "health checks", Paths: []string{"/_cluster/health"}, Drop: true},
+		},
+	}
+}
+
+// sampledRequest is what the sampling rules are matched against.
+type sampledRequest struct {
+	category string
+	indices  []string
+	path     string
+	// status and took are unknown until the request has been served
+	status int
+	took   time.Duration
+}
+
+type samplingRule struct {
+	SamplingRule
+	categories map[string]bool
+	indices    *matcher.Set
+	paths      *matcher.Set
+	classes    map[int]bool
+}
+
+// matches returns true if the rule matches the request, the status classes
+// are only matched once the request has been served.
+func (r *samplingRule) matches(req sampledRequest, served bool) bool {
+	if len(r.categories) > 0 && !r.categories[req.category] {
+		return false
+	}
+	if r.paths != nil && !r.paths.Match(req.path) {
+		return false
+	}
+	if r.indices != nil {
+		matched := false
+		for _, index := range req.indices {
+			if r.indices.Match(index) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.classes) > 0 && (!served || !r.classes[req.status/100]) {
+		return false
+	}
+	return true
+}
+
+// sampling is a compiled sampling config.
+type sampling struct {
+	config          SamplingConfig
+	defaultRate     float64
+	alwaysLogStatus int
+	alwaysLogTook   time.Duration
+	rules           []*samplingRule
+}
+
+func (c SamplingConfig) compile() (*sampling, error) {
+	s := &sampling{
+		config:          c,
+		defaultRate:     1,
+		alwaysLogStatus: defaultAlwaysLogStatus,
+		alwaysLogTook:   time.Duration(c.AlwaysLogTookMs) * time.Millisecond,
+	}
+	if c.DefaultRate != nil {
+		if *c.DefaultRate < 0 || *c.DefaultRate > 1 {
+			return nil, fmt.Errorf("default_rate must be between 0 and 1")
+		}
+		s.defaultRate = *c.DefaultRate
+	}
+	if c.AlwaysLogStatus != nil {
+		s.alwaysLogStatus = *c.AlwaysLogStatus
+	}
+	if c.AlwaysLogTookMs < 0 {
+		return nil, fmt.Errorf("always_log_took_ms can't be negative")
+	}
+	for i, rule := range c.Rules {
+		compiled, err := compileSamplingRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %v", i, err)
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+func compileSamplingRule(rule SamplingRule) (*samplingRule, error) {
+	if rule.Drop == (rule.Rate != nil) {
+		return nil, fmt.Errorf("a rule must either drop the requests or define a rate")
+	}
+	if rule.Rate != nil && (*rule.Rate < 0 || *rule.Rate > 1) {
+		return nil, fmt.Errorf("rate must be between 0 and 1")
+	}
+	compiled := &samplingRule{
+		SamplingRule: rule,
+		categories:   make(map[string]bool),
+		classes:      make(map[int]bool),
+	}
+	for _, name := range rule.Categories {
+		if !isCategory(name) {
+			return nil, fmt.Errorf(`invalid category "%s"`, name)
+		}
+		compiled.categories[name] = true
+	}
+	if len(rule.Indices) > 0 {
+		compiled.indices = matcher.Globs(rule.Indices)
+	}
+	if len(rule.Paths) > 0 {
+		compiled.paths = matcher.Globs(rule.Paths)
+	}
+	for _, class := range rule.StatusClasses {
+		class = strings.ToLower(class)
+		if len(class) != 3 || class[0] < '1' || class[0] > '5' || class[1:] != "xx" {
+			return nil, fmt.Errorf(`invalid status class "%s", must be one of "1xx" to "5xx"`, class)
+		}
+		compiled.classes[int(class[0]-'0')] = true
+	}
+	return compiled, nil
+}
+
+func isCategory(name string) bool {
+	for c := category.Docs; c <= category.Pipelines; c++ {
+		if c.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dropped returns true if a drop rule matches the request before it's served,
+// so that the dropped requests aren't even dumped.
+func (s *sampling) dropped(req sampledRequest) bool {
+	for _, rule := range s.rules {
+		if len(rule.classes) == 0 && rule.matches(req, false) {
+			return rule.Drop
+		}
+	}
+	return false
+}
+
+// sample returns true if the served request must be logged, random returns a
+// number in [0, 1).
+func (s *sampling) sample(req sampledRequest, random func() float64) bool {
+	rate := s.defaultRate
+	for _, rule := range s.rules {
+		if rule.matches(req, true) {
+			if rule.Drop {
+				return false
+			}
+			rate = *rule.Rate
+			break
+		}
+	}
+	if s.alwaysLogStatus >= 0 && req.status >= s.alwaysLogStatus {
+		return true
+	}
+	if s.alwaysLogTook > 0 && req.took >= s.alwaysLogTook {
+		return true
+	}
+	return rate >= 1 || random() < rate
+}
+
+// sampler holds the sampling config, it can be replaced at runtime.
+type sampler struct {
+	mu       sync.RWMutex
+	sampling *sampling
+	random   func() float64
+}
+
+func newSampler(saved *SamplingConfig) (*sampler, error) {
+	s := &sampler{random: rand.Float64}
+	if err := s.reload(saved); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sampler) current() *sampling {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampling
+}
+
+func (s *sampler) set(config SamplingConfig) error {
+	compiled, err := config.compile()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.sampling = compiled
+	s.mu.Unlock()
+	return nil
+}
+
+// reload applies the saved sampling config, or reads the one of the
+// LOGS_SAMPLING_FILE if none is saved. The default config applies if the file
+// isn't set either.
+func (s *sampler) reload(saved *SamplingConfig) error {
+	if saved != nil {
+		return s.set(*saved)
+	}
+	config := defaultSampling()
+	if path := os.Getenv(envSamplingFile); path != "" {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("can't read %s: %v", envSamplingFile, err)
+		}
+		config = SamplingConfig{}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return fmt.Errorf("invalid %s: %v", envSamplingFile, err)
+		}
+	}
+	return s.set(config)
+}
+
+// initSampling creates the index of the saved sampling config.
+func (es *elasticsearch) initSampling(ctx context.Context) error {
+	return createSettingsIndex(ctx, es.samplingIndex)
+}
+
+// getSampling returns the saved sampling config, nil if none is saved.
+func (es *elasticsearch) getSampling(ctx context.Context) (*SamplingConfig, error) {
+	response, err := util.GetClient7().Get().
+		Index(es.samplingIndex).
+		Id(samplingDocID).
+		Do(ctx)
+	if es7.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config SamplingConfig
+	if err := json.Unmarshal(response.Source, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// putSampling saves the sampling config so that all the nodes apply it.
+func (es *elasticsearch) putSampling(ctx context.Context, config SamplingConfig) error {
+	_, err := util.GetClient7().Index().
+		Index(es.samplingIndex).
+		Id(samplingDocID).
+		BodyJson(config).
+		Refresh("wait_for").
+		Do(ctx)
+	return err
+}
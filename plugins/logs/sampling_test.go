@@ -0,0 +1,73 @@
+package logs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSampling(t *testing.T) {
+	rate := func(r float64) *float64 { return &r }
+	never := func() float64 { return 0.99 }
+	always := func() float64 { return 0 }
+	Convey("should drop the health checks by default", t, func() {
+		s, err := defaultSampling().compile()
+		So(err, ShouldBeNil)
+		So(s.dropped(sampledRequest{path: "/_cluster/health"}), ShouldBeTrue)
+		So(s.dropped(sampledRequest{path: "/books/_search"}), ShouldBeFalse)
+		So(s.sample(sampledRequest{path: "/books/_search", status: 200}, never), ShouldBeTrue)
+	})
+	Convey("should sample the requests at the rate of the first matching rule", t, func() {
+		s, err := SamplingConfig{
+			AlwaysLogTookMs: 500,
+			Rules: []SamplingRule{
+				{Categories: []string{"search"}, Indices: []string{"products*"}, StatusClasses: []string{"2xx"}, Rate: rate(0.1)},
+				{Categories: []string{"search"}, Rate: rate(0.5)},
+			},
+		}.compile()
+		So(err, ShouldBeNil)
+		search := sampledRequest{category: "search", indices: []string{"products-2021"}, status: 200, took: time.Millisecond}
+		So(s.sample(search, never), ShouldBeFalse)
+		So(s.sample(search, always), ShouldBeTrue)
+		search.indices = []string{"books"}
+		So(s.sample(search, func() float64 { return 0.3 }), ShouldBeTrue)
+		So(s.sample(sampledRequest{category: "docs", status: 200}, never), ShouldBeTrue)
+		Convey("but always log the errors and the slow requests", func() {
+			So(s.sample(sampledRequest{category: "search", indices: []string{"products"}, status: 503}, never), ShouldBeTrue)
+			So(s.sample(sampledRequest{category: "search", indices: []string{"products"}, status: 200, took: time.Second}, never), ShouldBeTrue)
+		})
+	})
+	Convey("should reject the invalid rules", t, func() {
+		_, err := SamplingConfig{Rules: []SamplingRule{{Categories: []string{"searches"}, Rate: rate(1)}}}.compile()
+		So(err, ShouldNotBeNil)
+		_, err = SamplingConfig{Rules: []SamplingRule{{StatusClasses: []string{"6xx"}, Rate: rate(1)}}}.compile()
+		So(err, ShouldNotBeNil)
+		_, err = SamplingConfig{Rules: []SamplingRule{{Paths: []string{"/"}}}}.compile()
+		So(err, ShouldNotBeNil)
+		_, err = SamplingConfig{Rules: []SamplingRule{{Rate: rate(2)}}}.compile()
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestIsAdmin(t *testing.T) {
+	serve := func(reqUser *user.User) int {
+		req := httptest.NewRequest(http.MethodPut, "/_logs/sampling", nil)
+		if reqUser != nil {
+			req = req.WithContext(user.NewContext(context.Background(), reqUser))
+		}
+		w := httptest.NewRecorder()
+		isAdmin(func(w http.ResponseWriter, req *http.Request) {})(w, req)
+		return w.Code
+	}
+	admin, member := true, false
+	Convey("should only allow the admin users to change the logs settings", t, func() {
+		So(serve(&user.User{Username: "admin", IsAdmin: &admin}), ShouldEqual, http.StatusOK)
+		So(serve(&user.User{Username: "member", IsAdmin: &member}), ShouldEqual, http.StatusUnauthorized)
+		So(serve(nil), ShouldEqual, http.StatusUnauthorized)
+	})
+}
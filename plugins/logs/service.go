@@ -5,6 +5,11 @@ import "context"
 type logsService interface {
 	getRawLogs(ctx context.Context, logsFilter logsFilter) ([]byte, error)
 	getRawLog(ctx context.Context, ID string, parseDiffs bool) ([]byte, *LogError)
-	indexRecord(ctx context.Context, r record)
+	getInsights(ctx context.Context, filter insightsFilter) ([]byte, error)
+	indexRecords(batch [][]byte) error
 	rolloverIndexJob(alias string)
+	getRetention(ctx context.Context) (*retentionStatus, error)
+	putRetention(ctx context.Context, policy RetentionPolicy) (*retentionStatus, error)
+	getSampling(ctx context.Context) (*SamplingConfig, error)
+	putSampling(ctx context.Context, config SamplingConfig) error
 }
@@ -0,0 +1,270 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	envSinks              = "LOGS_SINKS"
+	envSinkQueueSize      = "LOGS_SINK_QUEUE_SIZE"
+	envSinkBatchSize      = "LOGS_SINK_BATCH_SIZE"
+	envSinkFlushInterval  = "LOGS_SINK_FLUSH_INTERVAL"
+	envWebhookURL         = "LOGS_WEBHOOK_URL"
+	defaultSinkQueueSize  = 10000
+	defaultSinkBatchSize  = 500
+	defaultFlushInterval  = 5 * time.Second
+	sinkEnqueueTimeout    = 100 * time.Millisecond
+	sinkMaxRetries        = 3
+	sinkRetryBackoff      = 500 * time.Millisecond
+	webhookRequestTimeout = 30 * time.Second
+)
+
+// Names of the built-in sinks.
+const (
+	SinkFile          = "file"
+	SinkElasticsearch = "elasticsearch"
+	SinkStdout        = "stdout"
+	SinkWebhook       = "webhook"
+)
+
+var errQueueFull = errors.New("queue is full, the record is dropped")
+
+// SinkStats are the stats of a sink.
+type SinkStats struct {
+	Name string `json:"name"`
+	// QueueDepth is the number of records waiting to be written
+	QueueDepth int `json:"queue_depth"`
+	// QueueSize is the capacity of the queue, 0 for the sinks writing synchronously
+	QueueSize int `json:"queue_size"`
+	// Dropped is the number of records dropped because the queue was full or the writes failed
+	Dropped int64 `json:"dropped"`
+}
+
+// LogSink writes the marshalled log records somewhere.
+type LogSink interface {
+	// Write writes or queues a record, it must not block for long.
+	Write(rec []byte) error
+	// Stats returns the stats of the sink along with its queue depth.
+	Stats() SinkStats
+}
+
+// lineSink writes the records as JSON lines to a writer, such as the log file
+// shipped by filebeat or the stdout.
+type lineSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func (s *lineSink) Write(rec []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(rec); err != nil {
+		return err
+	}
+	_, err := s.w.Write([]byte("\n"))
+	return err
+}
+
+func (s *lineSink) Stats() SinkStats {
+	return SinkStats{Name: s.name}
+}
+
+// batchSink queues the records in a bounded queue and flushes them in batches,
+// a failed batch is retried with a backoff, so that a slow destination fills
+// the queue and the records are dropped instead of piling up in memory.
+type batchSink struct {
+	name      string
+	queue     chan []byte
+	batchSize int
+	interval  time.Duration
+	flush     func(batch [][]byte) error
+	dropped   int64
+}
+
+func newBatchSink(name string, flush func(batch [][]byte) error) *batchSink {
+	s := &batchSink{
+		name:      name,
+		queue:     make(chan []byte, envInt(envSinkQueueSize, defaultSinkQueueSize)),
+		batchSize: envInt(envSinkBatchSize, defaultSinkBatchSize),
+		interval:  defaultFlushInterval,
+		flush:     flush,
+	}
+	if value := os.Getenv(envSinkFlushInterval); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Errorln(logTag, ": invalid", envSinkFlushInterval, value, ", using", defaultFlushInterval)
+		} else {
+			s.interval = interval
+		}
+	}
+	go s.run()
+	return s
+}
+
+func envInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Errorln(logTag, ": invalid", name, value, ", using", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func (s *batchSink) Write(rec []byte) error {
+	select {
+	case s.queue <- rec:
+		return nil
+	default:
+	}
+	// wait a bit for the queue to drain before dropping the record
+	timer := time.NewTimer(sinkEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.queue <- rec:
+		return nil
+	case <-timer.C:
+		atomic.AddInt64(&s.dropped, 1)
+		return errQueueFull
+	}
+}
+
+func (s *batchSink) Stats() SinkStats {
+	return SinkStats{
+		Name:       s.name,
+		QueueDepth: len(s.queue),
+		QueueSize:  cap(s.queue),
+		Dropped:    atomic.LoadInt64(&s.dropped),
+	}
+}
+
+func (s *batchSink) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, s.batchSize)
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		s.send(batch)
+		batch = make([][]byte, 0, s.batchSize)
+	}
+}
+
+func (s *batchSink) send(batch [][]byte) {
+	backoff := sinkRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.flush(batch)
+		if err == nil {
+			return
+		}
+		if attempt == sinkMaxRetries {
+			log.Errorln(logTag, ":", s.name, "sink dropped", len(batch), "records :", err)
+			atomic.AddInt64(&s.dropped, int64(len(batch)))
+			return
+		}
+		log.Warnln(logTag, ":", s.name, "sink failed to write", len(batch), "records, retrying :", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// webhookFlush posts the batches as newline delimited JSON to the url.
+func webhookFlush(url string) func(batch [][]byte) error {
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	return func(batch [][]byte) error {
+		body := bytes.Join(batch, []byte("\n"))
+		body = append(body, '\n')
+		res, err := client.Post(url, "application/x-ndjson", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("webhook responded with %d", res.StatusCode)
+		}
+		return nil
+	}
+}
+
+// initSinks returns the sinks listed in the LOGS_SINKS env var, the log file
+// is the default sink.
+func (l *Logs) initSinks() ([]LogSink, error) {
+	names := splitList(os.Getenv(envSinks))
+	if len(names) == 0 {
+		names = []string{SinkFile}
+	}
+	var sinks []LogSink
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case SinkFile:
+			sinks = append(sinks, &lineSink{name: SinkFile, w: &l.lumberjack})
+		case SinkStdout:
+			sinks = append(sinks, &lineSink{name: SinkStdout, w: os.Stdout})
+		case SinkElasticsearch:
+			sinks = append(sinks, newBatchSink(SinkElasticsearch, l.es.indexRecords))
+		case SinkWebhook:
+			url := os.Getenv(envWebhookURL)
+			if url == "" {
+				return nil, fmt.Errorf("%s must be set to use the %s sink", envWebhookURL, SinkWebhook)
+			}
+			sinks = append(sinks, newBatchSink(SinkWebhook, webhookFlush(url)))
+		default:
+			return nil, fmt.Errorf(`invalid log sink "%s", must be one of "%s", "%s", "%s" or "%s"`,
+				name, SinkFile, SinkElasticsearch, SinkStdout, SinkWebhook)
+		}
+	}
+	return sinks, nil
+}
+
+// AddSink adds a sink the records get written to along with the configured ones.
+func (l *Logs) AddSink(sink LogSink) {
+	l.sinksMu.Lock()
+	defer l.sinksMu.Unlock()
+	l.sinks = append(l.sinks, sink)
+}
+
+func (l *Logs) currentSinks() []LogSink {
+	l.sinksMu.RLock()
+	defer l.sinksMu.RUnlock()
+	return l.sinks
+}
+
+// write writes the marshalled record to every sink.
+func (l *Logs) write(rec []byte) {
+	for _, sink := range l.currentSinks() {
+		if err := sink.Write(rec); err != nil {
+			log.Errorln(logTag, ": error writing the record to the", sink.Stats().Name, "sink :", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSinks(t *testing.T) {
+	Convey("should write the records as JSON lines", t, func() {
+		buf := new(bytes.Buffer)
+		sink := &lineSink{name: SinkStdout, w: buf}
+		So(sink.Write([]byte(`{"a":1}`)), ShouldBeNil)
+		So(sink.Write([]byte(`{"a":2}`)), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "{\"a\":1}\n{\"a\":2}\n")
+		So(sink.Stats(), ShouldResemble, SinkStats{Name: SinkStdout})
+	})
+	Convey("should flush the records in batches", t, func() {
+		flushed := make(chan [][]byte, 10)
+		sink := &batchSink{
+			name:      SinkWebhook,
+			queue:     make(chan []byte, 10),
+			batchSize: 2,
+			interval:  time.Hour,
+			flush: func(batch [][]byte) error {
+				flushed <- batch
+				return nil
+			},
+		}
+		go sink.run()
+		for _, rec := range []string{"1", "2", "3"} {
+			So(sink.Write([]byte(rec)), ShouldBeNil)
+		}
+		So(<-flushed, ShouldResemble, [][]byte{[]byte("1"), []byte("2")})
+		So(sink.Stats().QueueSize, ShouldEqual, 10)
+	})
+	Convey("should drop the records once the queue is full", t, func() {
+		blocked := make(chan struct{})
+		sink := &batchSink{
+			name:      SinkElasticsearch,
+			queue:     make(chan []byte, 1),
+			batchSize: 1,
+			interval:  time.Hour,
+			flush: func(batch [][]byte) error {
+				<-blocked
+				return errors.New("unavailable")
+			},
+		}
+		go sink.run()
+		// the first record is being flushed, the second one fills the queue
+		So(sink.Write([]byte("1")), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		So(sink.Write([]byte("2")), ShouldBeNil)
+		So(sink.Write([]byte("3")), ShouldEqual, errQueueFull)
+		So(sink.Stats(), ShouldResemble, SinkStats{Name: SinkElasticsearch, QueueDepth: 1, QueueSize: 1, Dropped: 1})
+		close(blocked)
+	})
+}
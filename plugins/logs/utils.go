@@ -1,7 +1,5 @@
 package logs
 
-import log "github.com/sirupsen/logrus"
-
 // LogsMappings mappings for .logs indices
 const LogsMappings = `{
    "dynamic":false,
@@ -465,21 +463,38 @@ const LogsMappings = `{
       },
       "timestamp":{
          "type":"date"
+      },
+      "username":{
+         "type":"keyword"
+      },
+      "client_ip":{
+         "type":"ip",
+         "ignore_malformed":true
+      },
+      "record_id":{
+         "type":"keyword"
+      },
+      "auth_events":{
+         "properties":{
+            "type":{
+               "type":"keyword"
+            },
+            "username":{
+               "type":"keyword"
+            },
+            "ip":{
+               "type":"keyword"
+            },
+            "failures":{
+               "type":"integer"
+            },
+            "locked_until":{
+               "type":"date"
+            },
+            "timestamp":{
+               "type":"date"
+            }
+         }
       }
    }
 }`
-
-var blacklistedPaths = []string{
-	0: "/_cluster/health",
-}
-
-// Check if the passed path is blacklisted
-func isPathBlacklisted(path string) bool {
-	for _, blacklistedPath := range blacklistedPaths {
-		if blacklistedPath == path {
-			log.Debugln(logTag, "ignoring blacklisted path:", path)
-			return true
-		}
-	}
-	return false
-}
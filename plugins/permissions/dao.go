@@ -5,46 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 
-	log "github.com/sirupsen/logrus"
-
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
-	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/appbaseio/reactivesearch-api/model/role"
 )
 
-type elasticsearch struct {
-	indexName string
-	mapping   string
+type credentials struct {
+	store credentialstore.CredentialStore
 }
 
-func initPlugin(indexName, mapping string) (*elasticsearch, error) {
-	ctx := context.Background()
-
-	es := &elasticsearch{indexName, mapping}
-
-	// Check if the meta index already exists
-	exists, err := util.GetClient7().IndexExists(indexName).
-		Do(ctx)
+func initPlugin() (*credentials, error) {
+	store, err := credentialstore.Instance()
 	if err != nil {
-		return nil, fmt.Errorf("%s: error while checking if index already exists: %v", logTag, err)
-	}
-	if exists {
-		log.Println(logTag, ": index named", indexName, "already exists, skipping...")
-		return es, nil
+		return nil, err
 	}
-
-	replicas := util.GetReplicas()
-	settings := fmt.Sprintf(mapping, util.HiddenIndexSettings(), replicas)
-
-	// Create a new meta index
-	_, err = util.GetClient7().CreateIndex(indexName).
-		Body(settings).
-		Do(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("%s: error while creating index named %s: %v", logTag, indexName, err)
+	if err := store.Init(context.Background()); err != nil {
+		return nil, err
 	}
 
-	log.Println(logTag, ": successfully created index named", indexName)
-	return es, nil
+	return &credentials{store}, nil
 }
 
 func applyExpiredField(data []byte) ([]byte, error) {
@@ -64,8 +43,27 @@ func applyExpiredField(data []byte) ([]byte, error) {
 	return marshalled, nil
 }
 
-func (es *elasticsearch) getPermission(ctx context.Context, username string) (*permission.Permission, error) {
-	raw, err := es.getRawPermission(ctx, username)
+// applyExpiredFields sets the expired field of the permissions and marshals them into a JSON array.
+func applyExpiredFields(permissions []json.RawMessage) ([]byte, error) {
+	rawPermissions := []json.RawMessage{}
+	for _, permission := range permissions {
+		rawPermission, err := applyExpiredField(permission)
+		if err != nil {
+			return nil, err
+		}
+		rawPermissions = append(rawPermissions, rawPermission)
+	}
+
+	raw, err := json.Marshal(rawPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal slice of raw permissions: %v", err)
+	}
+
+	return raw, nil
+}
+
+func (c *credentials) getPermission(ctx context.Context, username string) (*permission.Permission, error) {
+	raw, err := c.getRawPermission(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -78,44 +76,70 @@ func (es *elasticsearch) getPermission(ctx context.Context, username string) (*p
 	return &p, nil
 }
 
-func (es *elasticsearch) getRawPermission(ctx context.Context, username string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawPermissionEs6(ctx, username)
-	default:
-		return es.getRawPermissionEs7(ctx, username)
+func (c *credentials) getRawPermission(ctx context.Context, username string) ([]byte, error) {
+	raw, err := c.store.GetRawPermission(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyExpiredField(raw)
+}
+
+func (c *credentials) postPermission(ctx context.Context, p permission.Permission) (bool, error) {
+	if err := c.store.PutPermission(ctx, p); err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func (es *elasticsearch) postPermission(ctx context.Context, p permission.Permission) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(p.Username).
-		BodyJson(p).
-		Do(ctx)
-	if err != nil {
+// replacedFields are the objects of a permission that a patch replaces as a
+// whole, instead of merging them into the stored ones.
+var replacedFields = []string{"filter", "schedule", "quota", "request_limits"}
+
+func (c *credentials) patchPermission(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	// only the fields present in the patch need to be replaced, the others are
+	// merged with a plain partial document update
+	var replace []string
+	for _, field := range replacedFields {
+		if _, ok := patch[field]; ok {
+			replace = append(replace, field)
+		}
+	}
+	return c.store.PatchPermission(ctx, username, patch, replace...)
+}
+
+func (c *credentials) deletePermission(ctx context.Context, username string) (bool, error) {
+	if err := c.store.DeletePermission(ctx, username); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (es *elasticsearch) patchPermission(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.patchPermissionEs6(ctx, username, patch)
-	default:
-		return es.patchPermissionEs7(ctx, username, patch)
+func (c *credentials) getRawOwnerPermissions(ctx context.Context, owner string) ([]byte, error) {
+	permissions, err := c.store.GetRawOwnerPermissions(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyExpiredFields(permissions)
+}
+
+func (c *credentials) getPermissions(ctx context.Context, indices []string) ([]byte, error) {
+	permissions, err := c.store.GetRawPermissions(ctx, indices)
+	if err != nil {
+		return nil, err
 	}
+
+	return applyExpiredFields(permissions)
 }
 
-func (es *elasticsearch) deletePermission(ctx context.Context, username string) (bool, error) {
-	_, err := util.GetClient7().Delete().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(username).
-		Do(ctx)
+func (c *credentials) checkRoleExists(ctx context.Context, role string) (bool, error) {
+	_, err := c.store.GetRawRolePermission(ctx, role)
+	if err == credentialstore.ErrNotFound {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
@@ -123,38 +147,89 @@ func (es *elasticsearch) deletePermission(ctx context.Context, username string)
 	return true, nil
 }
 
-func (es *elasticsearch) getRawOwnerPermissions(ctx context.Context, owner string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawOwnerPermissionsEs6(ctx, owner)
-	default:
-		return es.getRawOwnerPermissionsEs7(ctx, owner)
+func (c *credentials) getRawRolePermission(ctx context.Context, role string) ([]byte, error) {
+	return c.store.GetRawRolePermission(ctx, role)
+}
+
+func (c *credentials) getRole(ctx context.Context, name string) (*role.Role, error) {
+	raw, err := c.getRawRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var r role.Role
+	err = json.Unmarshal(raw, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (c *credentials) getRawRole(ctx context.Context, name string) ([]byte, error) {
+	return c.store.GetRawRole(ctx, name)
+}
+
+func (c *credentials) getRawRoles(ctx context.Context) ([]byte, error) {
+	roles, err := c.store.GetRawRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(roles)
+}
+
+func (c *credentials) putRole(ctx context.Context, r role.Role) (bool, error) {
+	if err := c.store.PutRole(ctx, r); err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func (es *elasticsearch) getPermissions(ctx context.Context, indices []string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getPermissionsEs6(ctx, indices)
-	default:
-		return es.getPermissionsEs7(ctx, indices)
+func (c *credentials) deleteRole(ctx context.Context, name string) (bool, error) {
+	if err := c.store.DeleteRole(ctx, name); err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func (es *elasticsearch) checkRoleExists(ctx context.Context, role string) (bool, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.checkRoleExistsEs6(ctx, role)
-	default:
-		return es.checkRoleExistsEs7(ctx, role)
+func (c *credentials) isRoleDefined(ctx context.Context, name string) (bool, error) {
+	_, err := c.store.GetRawRole(ctx, name)
+	if err == credentialstore.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func (es *elasticsearch) getRawRolePermission(ctx context.Context, role string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawRolePermissionEs6(ctx, role)
-	default:
-		return es.getRawRolePermissionEs7(ctx, role)
+// getRoleReference returns the username of a user or a permission that
+// inherits the grants of the role, it's empty if the role isn't in use.
+func (c *credentials) getRoleReference(ctx context.Context, name string) (string, error) {
+	users, err := c.store.GetRawUsers(ctx)
+	if err != nil {
+		return "", err
 	}
+	permissions, err := c.store.GetRawPermissions(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, raw := range append(users, permissions...) {
+		var credential struct {
+			Username string   `json:"username"`
+			Roles    []string `json:"roles"`
+		}
+		if err := json.Unmarshal(raw, &credential); err != nil {
+			return "", err
+		}
+		for _, r := range credential.Roles {
+			if r == name {
+				return credential.Username, nil
+			}
+		}
+	}
+
+	return "", nil
 }
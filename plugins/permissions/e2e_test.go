@@ -190,6 +190,7 @@ func TestPermission(t *testing.T) {
 	var username string
 	var password string
 	var createdAt string
+	util.SkipWithoutES(t)
 	build := util.BuildArc{}
 	util.StartArc(&build)
 	build.Start()
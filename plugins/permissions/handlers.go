@@ -10,6 +10,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/credential"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
@@ -23,7 +24,7 @@ func (p *permissions) getPermission() http.HandlerFunc {
 		vars := mux.Vars(req)
 		username := vars["username"]
 
-		rawPermission, err := p.es.getRawPermission(req.Context(), username)
+		rawPermission, err := p.store.getRawPermission(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`permission with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -111,6 +112,35 @@ func (p *permissions) postPermission(opts ...permission.Options) http.HandlerFun
 		if permissionBody.TTL != 0 {
 			permissionOptions = append(permissionOptions, permission.SetTTL(permissionBody.TTL))
 		}
+		if permissionBody.SigningKeys != nil {
+			permissionOptions = append(permissionOptions, permission.SetSigningKeys(permissionBody.SigningKeys))
+		}
+		if permissionBody.Filter != nil {
+			permissionOptions = append(permissionOptions, permission.SetFilter(permissionBody.Filter))
+		}
+		if permissionBody.Roles != nil {
+			permissionOptions = append(permissionOptions, permission.SetRoles(permissionBody.Roles, func(name string) (bool, error) {
+				return p.store.isRoleDefined(req.Context(), name)
+			}))
+		}
+		if permissionBody.Schedule != nil {
+			permissionOptions = append(permissionOptions, permission.SetSchedule(permissionBody.Schedule))
+		}
+		if permissionBody.Quota != nil {
+			permissionOptions = append(permissionOptions, permission.SetQuota(permissionBody.Quota))
+		}
+		if permissionBody.RequestLimits != nil {
+			permissionOptions = append(permissionOptions, permission.SetRequestLimits(permissionBody.RequestLimits))
+		}
+		if permissionBody.WriteIncludes != nil {
+			permissionOptions = append(permissionOptions, permission.SetWriteIncludes(permissionBody.WriteIncludes))
+		}
+		if permissionBody.WriteExcludes != nil {
+			permissionOptions = append(permissionOptions, permission.SetWriteExcludes(permissionBody.WriteExcludes))
+		}
+		if permissionBody.WriteProtection != "" {
+			permissionOptions = append(permissionOptions, permission.SetWriteProtection(permissionBody.WriteProtection))
+		}
 
 		var newPermission *permission.Permission
 		if *reqUser.IsAdmin {
@@ -134,7 +164,7 @@ func (p *permissions) postPermission(opts ...permission.Options) http.HandlerFun
 
 		if newPermission.Role != "" {
 			var roleExists bool
-			roleExists, err = p.es.checkRoleExists(req.Context(), newPermission.Role)
+			roleExists, err = p.store.checkRoleExists(req.Context(), newPermission.Role)
 			if roleExists {
 				msg := fmt.Sprintf(`permission with role=%s already exists`, newPermission.Role)
 				log.Errorln(logTag, ":", err)
@@ -149,7 +179,7 @@ func (p *permissions) postPermission(opts ...permission.Options) http.HandlerFun
 			}
 		}
 
-		ok, err := p.es.postPermission(req.Context(), *newPermission)
+		ok, err := p.store.postPermission(req.Context(), *newPermission)
 		if ok && err == nil {
 			util.WriteBackRaw(w, rawPermission, http.StatusOK)
 			return
@@ -207,13 +237,22 @@ func (p *permissions) patchPermission() http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if patch["roles"] != nil {
+			err := permission.ValidateRoles(obj.Roles, func(name string) (bool, error) {
+				return p.store.isRoleDefined(req.Context(), name)
+			})
+			if err != nil {
+				util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		// If user is trying to patch acls without providing categories.
 		if patch["categories"] == nil && patch["acls"] != nil {
 			// we need to fetch the permission from elasticsearch before we make
 			// a patch request in order to validate the acls that the user intends
 			// to patch against the categories it already has.
-			reqPermission, err := p.es.getPermission(req.Context(), username)
+			reqPermission, err := p.store.getPermission(req.Context(), username)
 			if err != nil {
 				log.Errorln(logTag, ":", err)
 				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
@@ -236,7 +275,7 @@ func (p *permissions) patchPermission() http.HandlerFunc {
 
 		if roleExistsInPatch && patch["role"] != "" {
 			var roleExistsInES bool
-			roleExistsInES, err = p.es.checkRoleExists(req.Context(), obj.Role)
+			roleExistsInES, err = p.store.checkRoleExists(req.Context(), obj.Role)
 			if roleExistsInES {
 				msg := fmt.Sprintf(`permission with role=%s already exists`, obj.Role)
 				log.Errorln(logTag, ":", err)
@@ -254,7 +293,7 @@ func (p *permissions) patchPermission() http.HandlerFunc {
 		// Set the updated_at for the permission
 		patch["updated_at"] = time.Now().Format(time.RFC3339)
 
-		_, err2 := p.es.patchPermission(req.Context(), username, patch)
+		_, err2 := p.store.patchPermission(req.Context(), username, patch)
 		if err2 == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -313,7 +352,7 @@ func (p *permissions) deletePermission() http.HandlerFunc {
 			return
 		}
 
-		ok, err := p.es.deletePermission(req.Context(), username)
+		ok, err := p.store.deletePermission(req.Context(), username)
 		if ok && err == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -383,7 +422,7 @@ func (p *permissions) getPermissions() http.HandlerFunc {
 			util.WriteBackError(w, msg, http.StatusUnauthorized)
 			return
 		}
-		raw, err := p.es.getPermissions(ctx, indices)
+		raw, err := p.store.getPermissions(ctx, indices)
 		if err != nil {
 			msg := fmt.Sprintf(`an error occurred while fetching permissions`)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -399,7 +438,7 @@ func (p *permissions) getUserPermissions() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		owner, _, _ := req.BasicAuth()
 
-		raw, err := p.es.getRawOwnerPermissions(req.Context(), owner)
+		raw, err := p.store.getRawOwnerPermissions(req.Context(), owner)
 		if err != nil {
 			msg := fmt.Sprintf(`an error occurred while fetching permissions for "owner"="%s"`, owner)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -420,7 +459,7 @@ func (p *permissions) role() http.HandlerFunc {
 		var perm permission.Permission
 		if req.Method != http.MethodPost {
 			var err error
-			raw, err = p.es.getRawRolePermission(req.Context(), role)
+			raw, err = p.store.getRawRolePermission(req.Context(), role)
 			if raw == nil || err != nil {
 				msg := fmt.Sprintf(`an error occurred while fetching permissions for role=%s`, role)
 				log.Errorln(logTag, ":", msg, ":", err)
@@ -451,3 +490,89 @@ func (p *permissions) role() http.HandlerFunc {
 		}
 	}
 }
+
+type tokenRequest struct {
+	permission.TokenScope
+	// TTL is the lifetime of the token in seconds
+	TTL int64 `json:"ttl"`
+}
+
+type tokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func (p *permissions) postPermissionToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		username := vars["username"]
+
+		// a permission can only issue the tokens for itself
+		reqCredential, err := credential.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while issuing the token", http.StatusInternalServerError)
+			return
+		}
+		if reqCredential == credential.Permission {
+			reqPermission, err := permission.FromContext(req.Context())
+			if err != nil || reqPermission.Username != username {
+				msg := fmt.Sprintf(`credential is not allowed to issue tokens for "username"="%s"`, username)
+				util.WriteBackError(w, msg, http.StatusUnauthorized)
+				return
+			}
+			// a token is issued from the stored permission, which may grant more than the scoped token
+			if reqPermission.Scoped {
+				util.WriteBackError(w, "a scoped token is not allowed to issue tokens", http.StatusForbidden)
+				return
+			}
+		}
+
+		var body tokenRequest
+		reqBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if len(reqBody) > 0 {
+			if err := json.Unmarshal(reqBody, &body); err != nil {
+				msg := "can't parse request body"
+				log.Errorln(logTag, ":", msg, ":", err)
+				util.WriteBackError(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+
+		reqPermission, err := p.store.getPermission(req.Context(), username)
+		if err != nil || reqPermission == nil {
+			msg := fmt.Sprintf(`permission with "username"="%s" not found`, username)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+		if expired, err := reqPermission.IsExpired(); err != nil || expired {
+			msg := fmt.Sprintf(`permission with "username"="%s" is expired`, username)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		token, expiresAt, err := reqPermission.NewScopedToken(body.TokenScope, time.Duration(body.TTL)*time.Second)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		raw, err := json.Marshal(tokenResponse{
+			Token:     token,
+			ExpiresAt: expiresAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "an error occurred while issuing the token", http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
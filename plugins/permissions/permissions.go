@@ -1,23 +1,23 @@
 package permissions
 
 import (
-	"os"
+	"context"
 	"sync"
+	"time"
+
+	"github.com/robfig/cron"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/plugins"
 	"github.com/appbaseio/reactivesearch-api/util"
 )
 
 const (
-	logTag                    = "[permissions]"
-	defaultPermissionsEsIndex = ".permissions"
-	typeName                  = "_doc"
-	envEsURL                  = "ES_CLUSTER_URL"
-	envPermissionEsIndex      = "PERMISSIONS_ES_INDEX"
-	settings                  = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
+	logTag   = "[permissions]"
+	envEsURL = "ES_CLUSTER_URL"
 )
 
 var (
@@ -26,7 +26,7 @@ var (
 )
 
 type permissions struct {
-	es permissionService
+	store permissionService
 }
 
 // Use only this function to fetch the instance of permission from within
@@ -43,23 +43,42 @@ func (p *permissions) Name() string {
 func (p *permissions) InitFunc() error {
 	log.Println(logTag, ": initializing plugin")
 
-	indexName := os.Getenv(envPermissionEsIndex)
-	if indexName == "" {
-		indexName = defaultPermissionsEsIndex
-	}
-
 	// initialize the dao
 	var err error
-	p.es, err = initPlugin(indexName, settings)
+	p.store, err = initPlugin()
 	if err != nil {
 		return err
 	}
 
-	// Set plugin cache sync script
-	s := CacheSyncScript{
-		index: indexName,
+	// the embedded store is local to the node, there is nothing to sync
+	if !credentialstore.IsEmbedded() {
+		// Set plugin cache sync script
+		s := CacheSyncScript{
+			index: credentialstore.PermissionIndex(),
+		}
+		util.AddSyncScript(s)
+	}
+
+	// persist the counters of the quotas so that they are shared by the nodes,
+	// whichever store holds the credentials
+	store, err := newElasticsearchQuotas(context.Background(), QuotaIndex())
+	if err != nil {
+		return err
 	}
-	util.AddSyncScript(s)
+	quotas.store = store
+	c := cron.New()
+	c.AddFunc("@every "+quotaSyncInterval().String(), func() {
+		quotas.sync(context.Background(), time.Now())
+	})
+	c.AddFunc("@midnight", func() {
+		if quotas.store == nil {
+			return
+		}
+		if err := quotas.store.expire(context.Background(), time.Now()); err != nil {
+			log.Errorln(logTag, ": error while deleting the expired quota counters :", err)
+		}
+	})
+	c.Start()
 
 	return nil
 }
@@ -68,12 +87,12 @@ func (p *permissions) Routes() []plugins.Route {
 	return p.routes()
 }
 
-// Default empty middleware array function
+// ESMiddleware counts the requests against the quotas of the permissions.
 func (p *permissions) ESMiddleware() []middleware.Middleware {
-	return make([]middleware.Middleware, 0)
+	return []middleware.Middleware{quota}
 }
 
-// Default empty middleware array function
+// RSMiddleware counts the requests against the quotas of the permissions.
 func (p *permissions) RSMiddleware() []middleware.Middleware {
-	return make([]middleware.Middleware, 0)
+	return []middleware.Middleware{quota}
 }
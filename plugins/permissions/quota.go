@@ -0,0 +1,459 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/olivere/elastic/v7"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/explain"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+const (
+	envQuotasEsIndex        = "QUOTAS_ES_INDEX"
+	defaultQuotasEsIndex    = ".quotas"
+	envQuotaSyncInterval    = "QUOTA_SYNC_INTERVAL"
+	defaultQuotaSyncInteval = 10 * time.Second
+	// quotaRetention is the time the counters are kept after their window ends
+	quotaRetention = 31 * 24 * time.Hour
+)
+
+// quotas counts the requests of the permissions against their quotas.
+var quotas = newQuotaTracker(nil)
+
+// quotaStore persists the counters of the quotas so that they are shared by the
+// nodes and survive the restarts.
+type quotaStore interface {
+	// add adds the delta to the counter and returns its count
+	add(ctx context.Context, c *quotaCounter, delta int64) (int64, error)
+	// count returns the count of the counter, zero if it doesn't exist
+	count(ctx context.Context, id string) (int64, error)
+	// expire deletes the counters expired before the instant
+	expire(ctx context.Context, before time.Time) error
+}
+
+// quotaCounter counts the requests of a permission in a window of a budget,
+// the requests of the node are added to the persisted count of all the nodes
+// with every sync.
+type quotaCounter struct {
+	id        string
+	username  string
+	period    string
+	window    string
+	category  string
+	windowEnd time.Time
+	loaded    bool
+	persisted int64
+	pending   int64
+}
+
+func (c *quotaCounter) count() int64 {
+	return c.persisted + c.pending
+}
+
+// quotaUsage is the usage of a budget in its current window.
+type quotaUsage struct {
+	Period    string `json:"period"`
+	Category  string `json:"category,omitempty"`
+	Window    string `json:"window"`
+	Count     int64  `json:"count"`
+	Limit     int64  `json:"limit"`
+	Remaining int64  `json:"remaining"`
+	ResetAt   string `json:"reset_at"`
+	windowEnd time.Time
+}
+
+type quotaTracker struct {
+	mu       sync.Mutex
+	store    quotaStore
+	counters map[string]*quotaCounter
+}
+
+func newQuotaTracker(store quotaStore) *quotaTracker {
+	return &quotaTracker{store: store, counters: make(map[string]*quotaCounter)}
+}
+
+// errQuotaUnavailable is returned while the counters can't be loaded from the
+// store, the requests are rejected rather than counted from zero.
+var errQuotaUnavailable = errors.New("quota can't be enforced until the quota counters are loaded, retry later")
+
+// counters returns the counters of the budgets in their current windows, the
+// counters seen for the first time are loaded from the store. The loading is
+// retried with every call until it succeeds.
+func (t *quotaTracker) countersFor(ctx context.Context, username string, budgets []permission.QuotaBudget, now time.Time) ([]*quotaCounter, error) {
+	counters := make([]*quotaCounter, len(budgets))
+	var unloaded []*quotaCounter
+	t.mu.Lock()
+	for i, budget := range budgets {
+		window, windowEnd := permission.QuotaWindow(budget.Period, now)
+		scope := budget.Category
+		if scope == "" {
+			scope = "_all"
+		}
+		id := fmt.Sprintf("%s:%s:%s", username, window, scope)
+		c, ok := t.counters[id]
+		if !ok {
+			c = &quotaCounter{
+				id:        id,
+				username:  username,
+				period:    budget.Period,
+				window:    window,
+				category:  budget.Category,
+				windowEnd: windowEnd,
+			}
+			t.counters[id] = c
+		}
+		if !c.loaded {
+			unloaded = append(unloaded, c)
+		}
+		counters[i] = c
+	}
+	t.mu.Unlock()
+
+	for _, c := range unloaded {
+		if err := t.load(ctx, c); err != nil {
+			return nil, errQuotaUnavailable
+		}
+	}
+	return counters, nil
+}
+
+// load sets the count of the counter persisted by all the nodes.
+func (t *quotaTracker) load(ctx context.Context, c *quotaCounter) error {
+	var count int64
+	if t.store != nil {
+		var err error
+		count, err = t.store.count(ctx, c.id)
+		if err != nil {
+			log.Errorln(logTag, ": error while loading the quota counter", c.id, ":", err)
+			return err
+		}
+	}
+	t.mu.Lock()
+	if !c.loaded {
+		c.persisted = count
+		c.loaded = true
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// consume counts a request of the category against the budgets of the quota,
+// nothing is counted if any of the budgets is exhausted.
+func (t *quotaTracker) consume(ctx context.Context, username string, quota *permission.Quota, c string, now time.Time) ([]quotaUsage, *quotaUsage, error) {
+	budgets := quota.BudgetsFor(c)
+	counters, err := t.countersFor(ctx, username, budgets, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, counter := range counters {
+		if counter.count() >= budgets[i].Limit {
+			usage := newQuotaUsage(counter, budgets[i].Limit)
+			return nil, &usage, nil
+		}
+	}
+	usages := make([]quotaUsage, len(counters))
+	for i, counter := range counters {
+		counter.pending++
+		usages[i] = newQuotaUsage(counter, budgets[i].Limit)
+	}
+	return usages, nil, nil
+}
+
+// usage returns the usage of all the budgets of the quota.
+func (t *quotaTracker) usage(ctx context.Context, username string, quota *permission.Quota, now time.Time) ([]quotaUsage, error) {
+	budgets := quota.Budgets()
+	counters, err := t.countersFor(ctx, username, budgets, now)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usages := make([]quotaUsage, len(counters))
+	for i, counter := range counters {
+		usages[i] = newQuotaUsage(counter, budgets[i].Limit)
+	}
+	return usages, nil
+}
+
+func newQuotaUsage(c *quotaCounter, limit int64) quotaUsage {
+	remaining := limit - c.count()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return quotaUsage{
+		Period:    c.period,
+		Category:  c.category,
+		Window:    c.window,
+		Count:     c.count(),
+		Limit:     limit,
+		Remaining: remaining,
+		ResetAt:   c.windowEnd.Format(time.RFC3339),
+		windowEnd: c.windowEnd,
+	}
+}
+
+// sync flushes the requests counted by the node and refreshes the counts of
+// the other nodes, the counters of the past windows are dropped. The counters
+// that failed to load are loaded again.
+func (t *quotaTracker) sync(ctx context.Context, now time.Time) {
+	t.mu.Lock()
+	counters := make([]*quotaCounter, 0, len(t.counters))
+	deltas := make([]int64, 0, len(t.counters))
+	var unloaded []*quotaCounter
+	for id, c := range t.counters {
+		if !now.Before(c.windowEnd) && c.pending == 0 {
+			delete(t.counters, id)
+			continue
+		}
+		if c.loaded {
+			counters = append(counters, c)
+			deltas = append(deltas, c.pending)
+		} else {
+			unloaded = append(unloaded, c)
+		}
+	}
+	t.mu.Unlock()
+	if t.store == nil {
+		return
+	}
+
+	for _, c := range unloaded {
+		t.load(ctx, c)
+	}
+
+	for i, c := range counters {
+		var count int64
+		var err error
+		if deltas[i] > 0 {
+			count, err = t.store.add(ctx, c, deltas[i])
+		} else {
+			count, err = t.store.count(ctx, c.id)
+		}
+		if err != nil {
+			log.Errorln(logTag, ": error while syncing the quota counter", c.id, ":", err)
+			continue
+		}
+		t.mu.Lock()
+		c.persisted = count
+		c.pending -= deltas[i]
+		t.mu.Unlock()
+	}
+}
+
+// quotaSyncInterval returns the interval of the syncs of the counters.
+func quotaSyncInterval() time.Duration {
+	if value := os.Getenv(envQuotaSyncInterval); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval > 0 {
+			return interval
+		}
+		log.Warnln(logTag, ": invalid", envQuotaSyncInterval, value, ", using", defaultQuotaSyncInteval)
+	}
+	return defaultQuotaSyncInteval
+}
+
+// QuotaIndex returns the index the counters of the quotas are persisted in.
+func QuotaIndex() string {
+	if index := os.Getenv(envQuotasEsIndex); index != "" {
+		return index
+	}
+	return defaultQuotasEsIndex
+}
+
+// elasticsearchQuotas persists the counters in the quotas index.
+type elasticsearchQuotas struct {
+	index string
+}
+
+type quotaDoc struct {
+	Username  string `json:"username"`
+	Period    string `json:"period"`
+	Window    string `json:"window"`
+	Category  string `json:"category,omitempty"`
+	Count     int64  `json:"count"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func newElasticsearchQuotas(ctx context.Context, index string) (*elasticsearchQuotas, error) {
+	exists, err := util.GetClient7().IndexExists(index).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while checking if index %s exists: %v", index, err)
+	}
+	if !exists {
+		settings := fmt.Sprintf(`{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`,
+			util.HiddenIndexSettings(), util.GetReplicas())
+		if _, err := util.GetClient7().CreateIndex(index).Body(settings).Do(ctx); err != nil {
+			return nil, fmt.Errorf("error while creating index %s: %v", index, err)
+		}
+		log.Println(logTag, ": successfully created index named", index)
+	}
+	return &elasticsearchQuotas{index: index}, nil
+}
+
+func (es *elasticsearchQuotas) add(ctx context.Context, c *quotaCounter, delta int64) (int64, error) {
+	script := elastic.NewScript("ctx._source.count += params.delta").
+		Params(map[string]interface{}{"delta": delta})
+	upsert := quotaDoc{
+		Username:  c.username,
+		Period:    c.period,
+		Window:    c.window,
+		Category:  c.category,
+		Count:     delta,
+		ExpiresAt: c.windowEnd.Add(quotaRetention).Format(time.RFC3339),
+	}
+	resp, err := util.GetClient7().Update().
+		Index(es.index).
+		Id(c.id).
+		Script(script).
+		Upsert(upsert).
+		RetryOnConflict(3).
+		FetchSource(true).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if resp.GetResult == nil {
+		return 0, fmt.Errorf("update response of %s is missing the source", c.id)
+	}
+	var doc quotaDoc
+	if err := json.Unmarshal(resp.GetResult.Source, &doc); err != nil {
+		return 0, err
+	}
+	return doc.Count, nil
+}
+
+func (es *elasticsearchQuotas) count(ctx context.Context, id string) (int64, error) {
+	resp, err := util.GetClient7().Get().Index(es.index).Id(id).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var doc quotaDoc
+	if err := json.Unmarshal(resp.Source, &doc); err != nil {
+		return 0, err
+	}
+	return doc.Count, nil
+}
+
+func (es *elasticsearchQuotas) expire(ctx context.Context, before time.Time) error {
+	_, err := util.GetClient7().DeleteByQuery(es.index).
+		Query(elastic.NewRangeQuery("expires_at").Lt(before.Format(time.RFC3339))).
+		Do(ctx)
+	return err
+}
+
+// quota middleware counts the requests of the permissions with a quota, the
+// requests are rejected once a budget is exhausted.
+func quota(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reqPermission, err := permission.FromContext(ctx)
+		if err != nil || reqPermission.Quota == nil || explain.IsDryRun(ctx) {
+			h(w, req)
+			return
+		}
+		reqCategory := ""
+		if c, err := category.FromContext(ctx); err == nil {
+			reqCategory = c.String()
+		}
+
+		now := time.Now()
+		usages, exceeded, err := quotas.consume(ctx, reqPermission.Username, reqPermission.Quota, reqCategory, now)
+		if err != nil {
+			telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if exceeded != nil {
+			setQuotaHeaders(w, *exceeded, now)
+			w.Header().Set("Retry-After", w.Header().Get("X-Quota-Reset"))
+			msg := fmt.Sprintf("%s quota of %d requests exceeded", describeBudget(*exceeded), exceeded.Limit)
+			telemetry.WriteBackErrorWithTelemetry(req, w, msg, http.StatusTooManyRequests)
+			return
+		}
+		if len(usages) > 0 {
+			tightest := usages[0]
+			for _, usage := range usages[1:] {
+				if usage.Remaining < tightest.Remaining {
+					tightest = usage
+				}
+			}
+			setQuotaHeaders(w, tightest, now)
+		}
+		softLimit := reqPermission.Quota.SoftLimitFraction()
+		for _, usage := range usages {
+			if float64(usage.Count) >= softLimit*float64(usage.Limit) {
+				w.Header().Add("X-Quota-Warning", fmt.Sprintf("%s quota is %d%% used (%d of %d requests)",
+					describeBudget(usage), usage.Count*100/usage.Limit, usage.Count, usage.Limit))
+			}
+		}
+		h(w, req)
+	}
+}
+
+func describeBudget(usage quotaUsage) string {
+	if usage.Category == "" {
+		return usage.Period
+	}
+	return fmt.Sprintf("%s %s", usage.Period, usage.Category)
+}
+
+// setQuotaHeaders sets the headers of the budget, the reset is the number of
+// seconds until the window ends.
+func setQuotaHeaders(w http.ResponseWriter, usage quotaUsage, now time.Time) {
+	reset := int64(math.Ceil(usage.windowEnd.Sub(now).Seconds()))
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(usage.Limit, 10))
+	w.Header().Set("X-Quota-Remaining", strconv.FormatInt(usage.Remaining, 10))
+	w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset, 10))
+}
+
+func (p *permissions) getPermissionUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		username := mux.Vars(req)["username"]
+		obj, err := p.store.getPermission(req.Context(), username)
+		if err != nil || obj == nil {
+			msg := fmt.Sprintf(`permission with "username"="%s" not found`, username)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+		usage := []quotaUsage{}
+		if obj.Quota != nil {
+			usage, err = quotas.usage(req.Context(), username, obj.Quota, time.Now())
+			if err != nil {
+				util.WriteBackError(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		response := map[string]interface{}{
+			"username": username,
+			"quota":    obj.Quota,
+			"usage":    usage,
+		}
+		raw, err := json.Marshal(response)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, "error occurred while marshalling the usage", http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
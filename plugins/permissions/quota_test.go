@@ -0,0 +1,112 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeQuotas is a quota store shared by the trackers of the nodes.
+type fakeQuotas struct {
+	counts map[string]int64
+	err    error
+}
+
+func (f *fakeQuotas) add(ctx context.Context, c *quotaCounter, delta int64) (int64, error) {
+	f.counts[c.id] += delta
+	return f.counts[c.id], nil
+}
+
+func (f *fakeQuotas) count(ctx context.Context, id string) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.counts[id], nil
+}
+
+func (f *fakeQuotas) expire(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func TestQuota(t *testing.T) {
+	now := time.Date(2021, 3, 31, 23, 0, 0, 0, time.UTC)
+	q := &permission.Quota{
+		QuotaLimit: permission.QuotaLimit{Daily: 10, Monthly: 100},
+		Categories: map[string]permission.QuotaLimit{"search": {Daily: 2}},
+	}
+	Convey("should count the requests against the budgets of the category", t, func() {
+		tracker := newQuotaTracker(nil)
+		usages, exceeded, err := tracker.consume(context.Background(), "key", q, "search", now)
+		So(err, ShouldBeNil)
+		So(exceeded, ShouldBeNil)
+		So(usages, ShouldHaveLength, 3)
+		_, exceeded, _ = tracker.consume(context.Background(), "key", q, "search", now)
+		So(exceeded, ShouldBeNil)
+		_, exceeded, _ = tracker.consume(context.Background(), "key", q, "search", now)
+		So(exceeded, ShouldNotBeNil)
+		So(exceeded.Category, ShouldEqual, "search")
+		So(exceeded.Window, ShouldEqual, "2021-03-31")
+		usages, exceeded, _ = tracker.consume(context.Background(), "key", q, "docs", now)
+		So(exceeded, ShouldBeNil)
+		So(usages, ShouldHaveLength, 2)
+		So(usages[0].Count, ShouldEqual, 3)
+		// the day and the month of the budget reset
+		usages, _, _ = tracker.consume(context.Background(), "key", q, "search", now.Add(2*time.Hour))
+		So(usages[0].Count, ShouldEqual, 1)
+		So(usages[1].Window, ShouldEqual, "2021-04")
+	})
+	Convey("should share the counts through the store", t, func() {
+		store := &fakeQuotas{counts: make(map[string]int64)}
+		node1, node2 := newQuotaTracker(store), newQuotaTracker(store)
+		node1.consume(context.Background(), "key", q, "docs", now)
+		node1.sync(context.Background(), now)
+		usages, _, _ := node2.consume(context.Background(), "key", q, "docs", now)
+		So(usages[0].Count, ShouldEqual, 2)
+		node2.sync(context.Background(), now)
+		node1.sync(context.Background(), now)
+		usages, _ = node1.usage(context.Background(), "key", q, now)
+		So(usages[0].Count, ShouldEqual, 2)
+	})
+	Convey("should reject the requests until the counters are loaded", t, func() {
+		store := &fakeQuotas{counts: map[string]int64{"key:2021-03-31:_all": 5}, err: errors.New("unavailable")}
+		tracker := newQuotaTracker(store)
+		_, _, err := tracker.consume(context.Background(), "key", q, "docs", now)
+		So(err, ShouldEqual, errQuotaUnavailable)
+		_, err = tracker.usage(context.Background(), "key", q, now)
+		So(err, ShouldEqual, errQuotaUnavailable)
+		// the counts of the other nodes are loaded once the store is back
+		store.err = nil
+		usages, exceeded, err := tracker.consume(context.Background(), "key", q, "docs", now)
+		So(err, ShouldBeNil)
+		So(exceeded, ShouldBeNil)
+		So(usages[0].Count, ShouldEqual, 6)
+	})
+	Convey("should warn past the soft limit and reject the exhausted quota", t, func() {
+		defer func(q *quotaTracker) { quotas = q }(quotas)
+		quotas = newQuotaTracker(nil)
+		p := &permission.Permission{Username: "key", Quota: &permission.Quota{QuotaLimit: permission.QuotaLimit{Daily: 2}, SoftLimit: 0.5}}
+		search := category.Search
+		serve := func() *httptest.ResponseRecorder {
+			ctx := permission.NewContext(context.Background(), p)
+			ctx = category.NewContext(ctx, &search)
+			w := httptest.NewRecorder()
+			quota(func(w http.ResponseWriter, req *http.Request) {})(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			return w
+		}
+		w := serve()
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("X-Quota-Remaining"), ShouldEqual, "1")
+		So(w.Header().Get("X-Quota-Warning"), ShouldNotBeEmpty)
+		So(serve().Code, ShouldEqual, http.StatusOK)
+		w = serve()
+		So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(w.Header().Get("Retry-After"), ShouldNotBeEmpty)
+	})
+}
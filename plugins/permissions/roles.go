@@ -0,0 +1,341 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/acl"
+	"github.com/appbaseio/reactivesearch-api/model/category"
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
+	"github.com/appbaseio/reactivesearch-api/model/op"
+	"github.com/appbaseio/reactivesearch-api/model/role"
+	"github.com/appbaseio/reactivesearch-api/plugins/auth"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/gorilla/mux"
+)
+
+func (p *permissions) getRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		raw, err := p.store.getRawRoles(req.Context())
+		if err != nil {
+			msg := "an error occurred while fetching the roles"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (p *permissions) getRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+
+		raw, err := p.store.getRawRole(req.Context(), name)
+		if err != nil {
+			msg := fmt.Sprintf(`role "%s" not found`, name)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+func (p *permissions) postRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		if req.URL.Query().Get("local") == "true" {
+			auth.ClearLocalRoles()
+			util.WriteBackMessage(w, "role is created successfully", http.StatusCreated)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		r := role.New(name)
+		err = json.Unmarshal(body, r)
+		if err != nil {
+			msg := "can't parse request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if r.Name != name {
+			util.WriteBackError(w, fmt.Sprintf(`role name "%s" doesn't match the name in the path`, r.Name), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := p.store.getRawRole(req.Context(), name); err == nil {
+			msg := fmt.Sprintf(`role "%s" already exists`, name)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		} else if err != credentialstore.ErrNotFound {
+			msg := fmt.Sprintf(`an error occurred while creating the role "%s"`, name)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		p.saveRole(w, req, r, http.MethodPost, "role is created successfully", http.StatusCreated)
+	}
+}
+
+func (p *permissions) patchRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		if req.URL.Query().Get("local") == "true" {
+			auth.ClearLocalRoles()
+			util.WriteBackMessage(w, "role is updated successfully", http.StatusOK)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		r, err := p.store.getRole(req.Context(), name)
+		if err != nil {
+			msg := fmt.Sprintf(`role "%s" not found`, name)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusNotFound)
+			return
+		}
+		createdAt := r.CreatedAt
+
+		// the fields present in the patch replace the stored ones
+		err = json.Unmarshal(body, r)
+		if err != nil {
+			msg := "can't parse request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if r.Name != name {
+			util.WriteBackError(w, "role name can't be updated", http.StatusBadRequest)
+			return
+		}
+		r.CreatedAt = createdAt
+		r.UpdatedAt = time.Now().Format(time.RFC3339)
+
+		p.saveRole(w, req, r, http.MethodPatch, "role is updated successfully", http.StatusOK)
+	}
+}
+
+// saveRole validates the role along with its inheritance chain before storing
+// it and clears the resolved grants on all the nodes.
+func (p *permissions) saveRole(w http.ResponseWriter, req *http.Request, r *role.Role, method, msg string, code int) {
+	if err := r.Validate(); err != nil {
+		util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lookup := func(ctx context.Context, name string) (*role.Role, error) {
+		if name == r.Name {
+			return r, nil
+		}
+		return p.store.getRole(ctx, name)
+	}
+	if _, err := role.Resolve(req.Context(), lookup, r.Name); err != nil {
+		util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := p.store.putRole(req.Context(), *r); err != nil {
+		errMsg := fmt.Sprintf(`an error occurred while saving the role "%s"`, r.Name)
+		log.Errorln(logTag, ":", errMsg, ":", err)
+		util.WriteBackError(w, errMsg, http.StatusInternalServerError)
+		return
+	}
+
+	if !clearRoles(w, method, r.Name) {
+		return
+	}
+	util.WriteBackMessage(w, msg, code)
+}
+
+func (p *permissions) deleteRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		msg := fmt.Sprintf(`role "%s" deleted`, name)
+		if req.URL.Query().Get("local") == "true" {
+			auth.ClearLocalRoles()
+			util.WriteBackMessage(w, msg, http.StatusOK)
+			return
+		}
+
+		raw, err := p.store.getRawRoles(req.Context())
+		if err != nil {
+			errMsg := fmt.Sprintf(`an error occurred while deleting the role "%s"`, name)
+			log.Errorln(logTag, ":", errMsg, ":", err)
+			util.WriteBackError(w, errMsg, http.StatusInternalServerError)
+			return
+		}
+		var roles []role.Role
+		if err := json.Unmarshal(raw, &roles); err != nil {
+			errMsg := fmt.Sprintf(`an error occurred while deleting the role "%s"`, name)
+			log.Errorln(logTag, ":", errMsg, ":", err)
+			util.WriteBackError(w, errMsg, http.StatusInternalServerError)
+			return
+		}
+		for _, r := range roles {
+			if util.Contains(r.Inherits, name) {
+				errMsg := fmt.Sprintf(`role "%s" is inherited by the role "%s"`, name, r.Name)
+				util.WriteBackError(w, errMsg, http.StatusBadRequest)
+				return
+			}
+		}
+		username, err := p.store.getRoleReference(req.Context(), name)
+		if err != nil {
+			errMsg := fmt.Sprintf(`an error occurred while deleting the role "%s"`, name)
+			log.Errorln(logTag, ":", errMsg, ":", err)
+			util.WriteBackError(w, errMsg, http.StatusInternalServerError)
+			return
+		}
+		if username != "" {
+			errMsg := fmt.Sprintf(`role "%s" is in use by "%s"`, name, username)
+			util.WriteBackError(w, errMsg, http.StatusBadRequest)
+			return
+		}
+
+		if _, err := p.store.deleteRole(req.Context(), name); err != nil {
+			errMsg := fmt.Sprintf(`role "%s" not found`, name)
+			log.Errorln(logTag, ":", errMsg, ":", err)
+			util.WriteBackError(w, errMsg, http.StatusNotFound)
+			return
+		}
+
+		if !clearRoles(w, http.MethodDelete, name) {
+			return
+		}
+		util.WriteBackMessage(w, msg, http.StatusOK)
+	}
+}
+
+// clearRoles clears the resolved grants on all the nodes through the proxy API,
+// or locally if there is a single node. It returns false if the response has
+// already been written.
+func clearRoles(w http.ResponseWriter, method, name string) bool {
+	// Only update local state when proxy API has not been called
+	// If proxy API would get called then it would automatically update the
+	// state for all machines
+	if !util.ShouldProxyToACCAPI() {
+		auth.ClearLocalRoles()
+		return true
+	}
+	res, err := util.ProxyACCAPI(util.ProxyConfig{
+		Method: method,
+		URL:    "/_role/" + name + "/definition",
+		Body:   nil,
+	})
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	// Failed to update all nodes, return error response
+	if res != nil {
+		log.Errorln(logTag, ":", "error encountered updating roles")
+		bodyBytes, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+		util.WriteBackRaw(w, bodyBytes, res.StatusCode)
+		return false
+	}
+	return true
+}
+
+type roleExplanation struct {
+	Role         string             `json:"role"`
+	Grants       *role.Grants       `json:"grants"`
+	Explanations []role.Explanation `json:"explanations"`
+}
+
+func (p *permissions) explainRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+
+		grants, err := auth.Instance().ResolveRoles(req.Context(), name)
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			util.WriteBackError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		requested, err := requestedGrants(req)
+		if err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := roleExplanation{Role: name, Grants: grants, Explanations: []role.Explanation{}}
+		for _, grant := range requested {
+			response.Explanations = append(response.Explanations, grants.Explain(grant))
+		}
+
+		raw, err := json.Marshal(response)
+		if err != nil {
+			msg := fmt.Sprintf(`an error occurred while explaining the role "%s"`, name)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusInternalServerError)
+			return
+		}
+		util.WriteBackRaw(w, raw, http.StatusOK)
+	}
+}
+
+// requestedGrants reads the grants to explain from the query params, the
+// categories, acls and ops are parsed to reject the unknown values.
+func requestedGrants(req *http.Request) ([]role.Grant, error) {
+	query := req.URL.Query()
+	var grants []role.Grant
+	for _, value := range query["category"] {
+		var c category.Category
+		if err := json.Unmarshal(quote(value), &c); err != nil {
+			return nil, err
+		}
+		grants = append(grants, role.Grant{Type: role.GrantCategory, Value: c.String()})
+	}
+	for _, value := range query["acl"] {
+		var a acl.ACL
+		if err := json.Unmarshal(quote(value), &a); err != nil {
+			return nil, err
+		}
+		grants = append(grants, role.Grant{Type: role.GrantACL, Value: a.String()})
+	}
+	for _, value := range query["op"] {
+		var o op.Operation
+		if err := json.Unmarshal(quote(value), &o); err != nil {
+			return nil, err
+		}
+		grants = append(grants, role.Grant{Type: role.GrantOp, Value: o.String()})
+	}
+	for _, value := range query["index"] {
+		grants = append(grants, role.Grant{Type: role.GrantIndex, Value: value})
+	}
+	return grants, nil
+}
+
+func quote(value string) []byte {
+	raw, _ := json.Marshal(value)
+	return raw
+}
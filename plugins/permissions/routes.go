@@ -37,6 +37,20 @@ func (p *permissions) routes() []plugins.Route {
 			HandlerFunc: middleware(p.deletePermission()),
 			Description: "Deletes the permission with {username}",
 		},
+		{
+			Name:        "Create scoped token",
+			Methods:     []string{http.MethodPost},
+			Path:        "/_permission/{username}/token",
+			HandlerFunc: middleware(p.postPermissionToken()),
+			Description: "Issues a short-lived token with the access of the permission with {username} narrowed down",
+		},
+		{
+			Name:        "Get permission usage",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_permission/{username}/usage",
+			HandlerFunc: middleware(p.getPermissionUsage()),
+			Description: "Returns the usage of the quota of the permission with {username}",
+		},
 		{
 			Name:        "Get user permissions",
 			Methods:     []string{http.MethodGet},
@@ -65,6 +79,48 @@ func (p *permissions) routes() []plugins.Route {
 			HandlerFunc: middleware(p.role()),
 			Description: "CRUD the permission with role {name}",
 		},
+		{
+			Name:        "Get roles",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_roles",
+			HandlerFunc: middleware(p.getRoles()),
+			Description: "Returns all the roles",
+		},
+		{
+			Name:        "Get role",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_role/{name}/definition",
+			HandlerFunc: middleware(p.getRole()),
+			Description: "Returns the definition of the role with {name}",
+		},
+		{
+			Name:        "Create role",
+			Methods:     []string{http.MethodPost},
+			Path:        "/_role/{name}/definition",
+			HandlerFunc: middleware(p.postRole()),
+			Description: "Creates the definition of the role with {name}",
+		},
+		{
+			Name:        "Patch role",
+			Methods:     []string{http.MethodPatch},
+			Path:        "/_role/{name}/definition",
+			HandlerFunc: middleware(p.patchRole()),
+			Description: "Updates the definition of the role with {name}",
+		},
+		{
+			Name:        "Delete role",
+			Methods:     []string{http.MethodDelete},
+			Path:        "/_role/{name}/definition",
+			HandlerFunc: middleware(p.deleteRole()),
+			Description: "Deletes the definition of the role with {name}",
+		},
+		{
+			Name:        "Explain role",
+			Methods:     []string{http.MethodGet},
+			Path:        "/_role/{name}/explain",
+			HandlerFunc: middleware(p.explainRole()),
+			Description: "Returns the effective grants of the role with {name} and the roles that grant the requested access",
+		},
 	}
 	return routes
 }
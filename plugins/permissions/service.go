@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/model/role"
 )
 
 type permissionService interface {
@@ -16,4 +17,11 @@ type permissionService interface {
 	getRawOwnerPermissions(ctx context.Context, owner string) ([]byte, error)
 	getRawRolePermission(ctx context.Context, role string) ([]byte, error)
 	checkRoleExists(ctx context.Context, role string) (bool, error)
+	getRole(ctx context.Context, name string) (*role.Role, error)
+	getRawRole(ctx context.Context, name string) ([]byte, error)
+	getRawRoles(ctx context.Context) ([]byte, error)
+	putRole(ctx context.Context, r role.Role) (bool, error)
+	deleteRole(ctx context.Context, name string) (bool, error)
+	isRoleDefined(ctx context.Context, name string) (bool, error)
+	getRoleReference(ctx context.Context, name string) (string, error)
 }
@@ -0,0 +1,75 @@
+package querytranslate
+
+import (
+	"net/http"
+
+	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	log "github.com/sirupsen/logrus"
+)
+
+// Weights of the estimated cost of a request.
+const (
+	// costPerQuery is charged for every executed query
+	costPerQuery = 1
+	// costPerHits is the number of requested hits charged as a query
+	costPerHits = 100
+	// costPerBuckets is the number of requested aggregation buckets charged as a query
+	costPerBuckets = 100
+	// costHighlight is charged for the queries that highlight the hits
+	costHighlight = 1
+	// costEndpoint is charged for the queries made to an endpoint
+	costEndpoint = 2
+)
+
+// estimateCost returns the cost of the request, the queries that don't execute
+// are free but a request costs one unit at least.
+func estimateCost(rsQuery RSQuery) int64 {
+	var cost int64
+	for _, query := range rsQuery.Query {
+		if !query.shouldExecuteQuery() {
+			continue
+		}
+		cost += costPerQuery
+		if window := resultWindow(query); window > 0 {
+			cost += int64(window / costPerHits)
+		}
+		if hasAggregations(query) {
+			cost += costPerQuery
+			if query.AggregationSize != nil && *query.AggregationSize > 0 {
+				cost += int64(*query.AggregationSize / costPerBuckets)
+			}
+		}
+		if query.Highlight != nil && *query.Highlight {
+			cost += costHighlight
+		}
+		if query.Endpoint != nil {
+			cost += costEndpoint
+		}
+	}
+	if cost < 1 {
+		cost = 1
+	}
+	return cost
+}
+
+// hasAggregations returns whether the query requests aggregations along with the hits.
+func hasAggregations(query Query) bool {
+	return query.Type == Term || query.AggregationField != nil ||
+		(query.Aggregations != nil && len(*query.Aggregations) > 0)
+}
+
+// estimateRequestCost stores the estimated cost of the request in the context
+// so that it's charged against the reactivesearch limit of the permission.
+func estimateRequestCost(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rsQuery, err := FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error occurred while estimating the cost of the request", http.StatusInternalServerError)
+			return
+		}
+		ctx := ratelimiter.NewCostContext(req.Context(), estimateCost(*rsQuery))
+		h(w, req.WithContext(ctx))
+	}
+}
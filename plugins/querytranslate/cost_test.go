@@ -0,0 +1,29 @@
+package querytranslate
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEstimateCost(t *testing.T) {
+	search, term := "search", "term"
+	size, aggregationSize := 200, 500
+	enabled, disabled := true, false
+	Convey("should charge a unit per executed query", t, func() {
+		rsQuery := RSQuery{Query: []Query{{ID: &search}, {ID: &term, Execute: &disabled}}}
+		So(estimateCost(rsQuery), ShouldEqual, 1)
+		rsQuery.Query[1].Execute = &enabled
+		So(estimateCost(rsQuery), ShouldEqual, 2)
+	})
+	Convey("should charge the hits, the aggregations, the highlights and the endpoints", t, func() {
+		rsQuery := RSQuery{Query: []Query{
+			{ID: &search, Size: &size, Highlight: &enabled},
+			{ID: &term, Type: Term, AggregationSize: &aggregationSize, Endpoint: &Endpoint{}},
+		}}
+		So(estimateCost(rsQuery), ShouldEqual, (1+2+costHighlight)+(1+1+5+costEndpoint))
+	})
+	Convey("should cost a unit at least", t, func() {
+		So(estimateCost(RSQuery{}), ShouldEqual, 1)
+	})
+}
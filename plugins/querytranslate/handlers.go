@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
 	"github.com/appbaseio/reactivesearch-api/model/index"
 	"github.com/appbaseio/reactivesearch-api/util"
 	"github.com/buger/jsonparser"
@@ -70,6 +71,15 @@ func (r *QueryTranslate) search() http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// expose the cost charged against the reactivesearch limit
+		if cost, err := ratelimiter.CostFromContext(ctx); err == nil {
+			rsResponse, err = jsonparser.Set(rsResponse, []byte(strconv.FormatInt(cost, 10)), "settings", "cost")
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, "can't add cost key to response settings", http.StatusInternalServerError)
+				return
+			}
+		}
 
 		// This is where the independent requests will be done.
 		independentReqBody, independentErr := FromIndependentRequestContext(req.Context())
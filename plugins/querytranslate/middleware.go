@@ -16,6 +16,7 @@ import (
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
 	"github.com/appbaseio/reactivesearch-api/middleware/classify"
+	"github.com/appbaseio/reactivesearch-api/middleware/guard"
 	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
 	"github.com/appbaseio/reactivesearch-api/middleware/validate"
 	"github.com/appbaseio/reactivesearch-api/model/category"
@@ -27,6 +28,7 @@ import (
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/plugins/logs"
 	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	"github.com/appbaseio/reactivesearch-api/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -44,12 +46,14 @@ func (c *chain) Wrap(mw []middleware.Middleware, h http.HandlerFunc) http.Handle
 
 func list() []middleware.Middleware {
 	return []middleware.Middleware{
+		guard.Body(),
 		classifyCategory,
 		classifyOp,
 		classify.Indices(),
 		saveRequestToCtx, // middleware to save the request body in context
 		logs.Recorder(),
 		auth.BasicAuth(),
+		estimateRequestCost,
 		ratelimiter.Limit(),
 		validate.Sources(),
 		validate.Referers(),
@@ -57,6 +61,8 @@ func list() []middleware.Middleware {
 		validate.Category(),
 		validate.Operation(),
 		validate.PermissionExpiry(),
+		guard.Permission(),
+		guardReact,
 		applySourceFiltering,
 	}
 }
@@ -93,6 +99,18 @@ func saveRequestToCtx(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if isSchemaValidationEnabled() {
+			if err := ValidateRSQuery(buf.Bytes()); err != nil {
+				if schemaErrs, ok := err.(SchemaErrors); ok {
+					writeSchemaErrors(w, schemaErrs)
+					return
+				}
+				log.Errorln(logTag, ":", err)
+				telemetry.WriteBackErrorWithTelemetry(req, w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
 		err = json.Unmarshal(buf.Bytes(), &body)
 		if err != nil {
 			log.Errorln(logTag, "error while unmarshalling request body to save to context", err)
@@ -307,7 +325,11 @@ func queryTranslate(h http.HandlerFunc) http.HandlerFunc {
 		if p != "" {
 			preference = &p
 		}
-		msearchQuery, _, translateErr = translateQuery(*body, iplookup.FromRequest(req), nil, preference)
+		var rsConfig *permission.ReactiveSearchConfig
+		if reqPermission != nil {
+			rsConfig = reqPermission.ReactiveSearchConfig
+		}
+		msearchQuery, _, translateErr = translateQuery(*body, iplookup.FromRequest(req), nil, preference, rsConfig)
 
 		// log.Println("RS QUERY", msearchQuery)
 		if translateErr != nil {
@@ -316,6 +338,16 @@ func queryTranslate(h http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Apply the document filter of the permission to each query
+		if reqPermission != nil {
+			msearchQuery, translateErr = util.ApplyFilterClausesToMsearch(msearchQuery, reqPermission.GetFilterClauses())
+			if translateErr != nil {
+				log.Errorln(logTag, ":", translateErr)
+				telemetry.WriteBackErrorWithTelemetry(req, w, translateErr.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Update the request body to the parsed query
 		req.Body = ioutil.NopCloser(strings.NewReader(msearchQuery))
 
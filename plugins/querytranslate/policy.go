@@ -0,0 +1,347 @@
+package querytranslate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/util"
+)
+
+// validateQueryPolicy checks the queries against the reactivesearch config of
+// the permission, it prevents the public keys from crafting expensive queries.
+func validateQueryPolicy(rsQuery RSQuery, config *permission.ReactiveSearchConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.MaxQueries != nil && len(rsQuery.Query) > *config.MaxQueries {
+		return fmt.Errorf("maximum allowed number of queries is %d", *config.MaxQueries)
+	}
+	for _, query := range rsQuery.Query {
+		id := ""
+		if query.ID != nil {
+			id = *query.ID
+		}
+		if !config.AllowsQueryType(query.Type.String()) {
+			return fmt.Errorf("query '%s': query type '%s' is not allowed", id, query.Type)
+		}
+		if isTrue(config.DisableCustomQuery) && query.CustomQuery != nil {
+			return fmt.Errorf("query '%s': 'customQuery' is not allowed", id)
+		}
+		if isTrue(config.DisableDefaultQuery) && query.DefaultQuery != nil {
+			return fmt.Errorf("query '%s': 'defaultQuery' is not allowed", id)
+		}
+		if isTrue(config.DisableScript) &&
+			(query.Script != nil || hasScript(query.DefaultQuery) || hasScript(query.CustomQuery)) {
+			return fmt.Errorf("query '%s': scripts are not allowed", id)
+		}
+		if config.MaxResultWindow != nil {
+			if window := resultWindow(query); window > *config.MaxResultWindow {
+				return fmt.Errorf("query '%s': maximum allowed value of from + size is %d", id, *config.MaxResultWindow)
+			}
+		}
+		if query.Endpoint != nil {
+			if err := validateEndpoint(query.Endpoint, config); err != nil {
+				return fmt.Errorf("query '%s': %s", id, err)
+			}
+		}
+		fields := []string{}
+		for _, dataField := range NormalizedDataFields(query.DataField, query.FieldWeights) {
+			fields = append(fields, dataField.Field)
+		}
+		for _, field := range []*string{query.AggregationField, query.CategoryField, query.VectorDataField} {
+			if field != nil {
+				fields = append(fields, *field)
+			}
+		}
+		for _, field := range fields {
+			if !config.CanQueryField(field) {
+				return fmt.Errorf("query '%s': querying the field '%s' is not allowed", id, field)
+			}
+		}
+	}
+	return nil
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+// scriptKeys are the keys of the elasticsearch DSL that run a script.
+var scriptKeys = []string{"script", "script_score", "script_fields", "scripted_metric"}
+
+// hasScript returns true if any of the keys of the query runs a script.
+func hasScript(query *map[string]interface{}) bool {
+	if query == nil {
+		return false
+	}
+	var walk func(value interface{}) bool
+	walk = func(value interface{}) bool {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if util.Contains(scriptKeys, key) || walk(child) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, child := range v {
+				if walk(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(*query)
+}
+
+// fieldQueries are the queries that are keyed by the name of the field.
+var fieldQueries = []string{
+	"term", "terms", "terms_set", "match", "match_phrase", "match_phrase_prefix",
+	"match_bool_prefix", "prefix", "wildcard", "regexp", "fuzzy", "range",
+	"span_term", "intervals", "geo_distance", "geo_bounding_box", "geo_polygon", "geo_shape",
+}
+
+// fieldQueryOptions are the options of the field queries that aren't fields.
+var fieldQueryOptions = []string{
+	"boost", "_name", "distance", "distance_type", "validation_method", "ignore_unmapped", "type",
+}
+
+// restrictDataFields checks the fields the translated query refers to against
+// the allowed data fields of the config, so that the defaultQuery, the
+// customQuery and the sortBy can't query the other fields. The source of the
+// hits is restricted to the allowed fields as well.
+func restrictDataFields(query []byte, config *permission.ReactiveSearchConfig) ([]byte, error) {
+	if config == nil || len(config.AllowedDataFields) == 0 {
+		return query, nil
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(query, &body); err != nil {
+		return nil, err
+	}
+	collector := fieldCollector{sources: []map[string]interface{}{body}}
+	collector.collect(body)
+	for _, field := range collector.fields {
+		// the meta fields, e.g. "_id" or "_score", can always be used
+		if !strings.HasPrefix(field, "_") && !config.CanQueryField(field) {
+			return nil, fmt.Errorf("querying the field '%s' is not allowed", field)
+		}
+	}
+	for _, source := range collector.sources {
+		if err := restrictSource(source, config); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(body)
+}
+
+// fieldCollector collects the fields a query refers to along with the objects
+// that filter the source of the hits, i.e. the query and the top hits.
+type fieldCollector struct {
+	fields  []string
+	sources []map[string]interface{}
+}
+
+func (c *fieldCollector) collect(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			switch {
+			case key == "_source":
+				// the source filters are restricted by restrictSource
+			case key == "aggs" || key == "aggregations":
+				c.collectAggregations(child)
+			case util.Contains(fieldQueries, key):
+				params, ok := child.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				// the aggregations of the same name, e.g. the composite sources, have a field
+				if _, isAggregation := params["field"].(string); isAggregation {
+					c.collect(params)
+					continue
+				}
+				for field := range params {
+					if !util.Contains(fieldQueryOptions, field) {
+						c.fields = append(c.fields, field)
+					}
+				}
+			case key == "field" || key == "default_field":
+				if field, ok := child.(string); ok {
+					c.fields = append(c.fields, field)
+				} else {
+					c.collect(child)
+				}
+			case key == "fields" || key == "docvalue_fields" || key == "stored_fields" || key == "sort":
+				c.collectNames(child)
+			default:
+				c.collect(child)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			c.collect(child)
+		}
+	}
+}
+
+// collectAggregations collects the fields of the named aggregations, the
+// parameters of an aggregation aren't keyed by the fields unlike the queries.
+func (c *fieldCollector) collectAggregations(value interface{}) {
+	aggregations, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, aggregation := range aggregations {
+		definition, ok := aggregation.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, params := range definition {
+			if key == "aggs" || key == "aggregations" {
+				c.collectAggregations(params)
+				continue
+			}
+			if paramsMap, ok := params.(map[string]interface{}); ok {
+				if key == "top_hits" {
+					c.sources = append(c.sources, paramsMap)
+				}
+				if key == "filter" {
+					// the filter aggregation is a query
+					c.collect(paramsMap)
+					continue
+				}
+				for param, child := range paramsMap {
+					c.collect(map[string]interface{}{param: child})
+				}
+			}
+		}
+	}
+}
+
+// collectNames collects the fields of a list or a map of fields, e.g. the
+// fields of a multi_match query, of the highlight or of the sort.
+func (c *fieldCollector) collectNames(value interface{}) {
+	switch v := value.(type) {
+	case string:
+		// the fields can be boosted, e.g. "title^3"
+		c.fields = append(c.fields, strings.SplitN(v, "^", 2)[0])
+	case map[string]interface{}:
+		if field, ok := v["field"].(string); ok {
+			c.fields = append(c.fields, field)
+			return
+		}
+		for field := range v {
+			c.fields = append(c.fields, field)
+		}
+	case []interface{}:
+		for _, child := range v {
+			c.collectNames(child)
+		}
+	}
+}
+
+// restrictSource replaces the wildcard includes of the source filter with the
+// allowed fields and rejects the includes of the other fields.
+func restrictSource(body map[string]interface{}, config *permission.ReactiveSearchConfig) error {
+	var includes []interface{}
+	switch source := body["_source"].(type) {
+	case nil:
+	case bool:
+		if !source {
+			return nil
+		}
+	case string:
+		includes = []interface{}{source}
+	case []interface{}:
+		includes = source
+	case map[string]interface{}:
+		if value, ok := source["includes"].([]interface{}); ok {
+			includes = value
+		} else if value, ok := source["include"].([]interface{}); ok {
+			includes = value
+		}
+	default:
+		return fmt.Errorf("invalid '_source' of the query")
+	}
+	allowed := []string{}
+	for _, include := range includes {
+		field, _ := include.(string)
+		if field == "*" {
+			allowed = append(allowed, config.AllowedDataFields...)
+			continue
+		}
+		if !config.CanQueryField(field) {
+			return fmt.Errorf("querying the field '%s' is not allowed", field)
+		}
+		allowed = append(allowed, field)
+	}
+	if len(includes) == 0 {
+		allowed = config.AllowedDataFields
+	}
+	restricted := map[string]interface{}{"includes": allowed}
+	if source, ok := body["_source"].(map[string]interface{}); ok {
+		if excludes, ok := source["excludes"]; ok {
+			restricted["excludes"] = excludes
+		}
+	}
+	body["_source"] = restricted
+	return nil
+}
+
+// resultWindow returns the from + size of the query, the values of the
+// defaultQuery take precedence. The customQuery can set them as well, so the
+// larger of both windows is returned.
+func resultWindow(query Query) int {
+	from, size := 0, 0
+	if query.From != nil {
+		from = *query.From
+	}
+	if query.Size != nil {
+		size = *query.Size
+	}
+	from, size = windowOf(query.DefaultQuery, from, size)
+	window := from + size
+	if customFrom, customSize := windowOf(query.CustomQuery, from, size); customFrom+customSize > window {
+		window = customFrom + customSize
+	}
+	return window
+}
+
+// windowOf returns the from and size of the elasticsearch DSL, or the passed
+// ones when they aren't set.
+func windowOf(dsl *map[string]interface{}, from, size int) (int, int) {
+	if dsl == nil {
+		return from, size
+	}
+	if value, ok := (*dsl)["from"].(float64); ok {
+		from = int(value)
+	}
+	if value, ok := (*dsl)["size"].(float64); ok {
+		size = int(value)
+	}
+	return from, size
+}
+
+func validateEndpoint(endpoint *Endpoint, config *permission.ReactiveSearchConfig) error {
+	if isTrue(config.DisableEndpoint) {
+		return fmt.Errorf("'endpoint' is not allowed")
+	}
+	if len(config.AllowedEndpointHosts) == 0 {
+		return nil
+	}
+	if endpoint.URL == nil {
+		return fmt.Errorf("'endpoint.url' is required")
+	}
+	endpointURL, err := url.Parse(*endpoint.URL)
+	if err != nil || endpointURL.Hostname() == "" {
+		return fmt.Errorf("invalid 'endpoint.url': %s", *endpoint.URL)
+	}
+	if !config.AllowsEndpointHost(endpointURL.Hostname()) {
+		return fmt.Errorf("endpoint host '%s' is not allowed", endpointURL.Hostname())
+	}
+	return nil
+}
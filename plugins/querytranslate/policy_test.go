@@ -0,0 +1,125 @@
+package querytranslate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryPolicy(t *testing.T) {
+	id := "test"
+	one, ten, hundred := 1, 10, 100
+	disabled := true
+	Convey("should restrict the query types and the number of queries", t, func() {
+		config := &permission.ReactiveSearchConfig{AllowedQueryTypes: []string{"search"}, MaxQueries: &one}
+		rsQuery := RSQuery{Query: []Query{{ID: &id, DataField: "title", Type: Term}}}
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, config)
+		So(err, ShouldBeError)
+		rsQuery.Query[0].Type = Search
+		_, _, err = translateQuery(rsQuery, "127.0.0.1", nil, nil, config)
+		So(err, ShouldBeNil)
+		rsQuery.Query = append(rsQuery.Query, rsQuery.Query[0])
+		_, _, err = translateQuery(rsQuery, "127.0.0.1", nil, nil, config)
+		So(err, ShouldBeError)
+	})
+	Convey("should limit the depth and the fan out of the react prop", t, func() {
+		react := map[string]interface{}{"and": []interface{}{"a", map[string]interface{}{"or": []interface{}{"b", "c"}}}}
+		rsQuery := RSQuery{Query: []Query{{ID: &id, React: &react}}}
+		So(validateReact(rsQuery, permission.RequestLimits{MaxReactDepth: 4, MaxReactFanOut: 3}), ShouldBeNil)
+		So(validateReact(rsQuery, permission.RequestLimits{MaxReactDepth: 3}), ShouldBeError)
+		So(validateReact(rsQuery, permission.RequestLimits{MaxReactFanOut: 2}), ShouldBeError)
+	})
+	Convey("should restrict the result window", t, func() {
+		config := &permission.ReactiveSearchConfig{MaxResultWindow: &hundred}
+		rsQuery := RSQuery{Query: []Query{{ID: &id, DataField: "title", From: &hundred, Size: &ten}}}
+		So(validateQueryPolicy(rsQuery, config), ShouldBeError)
+		defaultQuery := map[string]interface{}{"from": float64(0), "size": float64(10)}
+		rsQuery.Query[0].DefaultQuery = &defaultQuery
+		So(validateQueryPolicy(rsQuery, config), ShouldBeNil)
+		customQuery := map[string]interface{}{"from": float64(95), "size": float64(10)}
+		rsQuery.Query[0].CustomQuery = &customQuery
+		So(validateQueryPolicy(rsQuery, config), ShouldBeError)
+	})
+	Convey("should forbid the custom queries and the scripts", t, func() {
+		customQuery := map[string]interface{}{
+			"query": map[string]interface{}{"function_score": map[string]interface{}{
+				"functions": []interface{}{map[string]interface{}{"script_score": map[string]interface{}{}}},
+			}},
+		}
+		rsQuery := RSQuery{Query: []Query{{ID: &id, DataField: "title", CustomQuery: &customQuery}}}
+		So(validateQueryPolicy(rsQuery, &permission.ReactiveSearchConfig{DisableScript: &disabled}), ShouldBeError)
+		So(validateQueryPolicy(rsQuery, &permission.ReactiveSearchConfig{DisableCustomQuery: &disabled}), ShouldBeError)
+		So(validateQueryPolicy(rsQuery, &permission.ReactiveSearchConfig{DisableDefaultQuery: &disabled}), ShouldBeNil)
+	})
+	Convey("should only match the script keys", t, func() {
+		query := map[string]interface{}{"query": map[string]interface{}{"match": map[string]interface{}{"transcript": "foo"}}}
+		So(hasScript(&query), ShouldBeFalse)
+		query = map[string]interface{}{"script_fields": map[string]interface{}{}}
+		So(hasScript(&query), ShouldBeTrue)
+		query = map[string]interface{}{"aggs": map[string]interface{}{"total": map[string]interface{}{"scripted_metric": map[string]interface{}{}}}}
+		So(hasScript(&query), ShouldBeTrue)
+	})
+	Convey("should restrict the endpoint hosts", t, func() {
+		config := &permission.ReactiveSearchConfig{AllowedEndpointHosts: []string{"*.example.com"}}
+		endpointURL := "https://api.example.com/search"
+		rsQuery := RSQuery{Query: []Query{{ID: &id, Endpoint: &Endpoint{URL: &endpointURL}}}}
+		So(validateQueryPolicy(rsQuery, config), ShouldBeNil)
+		endpointURL = "https://example.org/search"
+		So(validateQueryPolicy(rsQuery, config), ShouldBeError)
+		So(validateQueryPolicy(rsQuery, &permission.ReactiveSearchConfig{DisableEndpoint: &disabled}), ShouldBeError)
+	})
+	Convey("should restrict the data fields", t, func() {
+		config := &permission.ReactiveSearchConfig{AllowedDataFields: []string{"title", "meta.*"}}
+		rsQuery := RSQuery{Query: []Query{{ID: &id, DataField: []interface{}{"title.search", "meta.tags"}}}}
+		So(validateQueryPolicy(rsQuery, config), ShouldBeNil)
+		rsQuery.Query[0].DataField = "price"
+		So(validateQueryPolicy(rsQuery, config), ShouldBeError)
+	})
+	Convey("should restrict the fields of the translated query", t, func() {
+		config := &permission.ReactiveSearchConfig{AllowedDataFields: []string{"title", "meta.*"}}
+		translate := func(query Query) (map[string]interface{}, error) {
+			query.ID = &id
+			query.DataField = "title"
+			msearch, _, err := translateQuery(RSQuery{Query: []Query{query}}, "127.0.0.1", nil, nil, config)
+			if err != nil {
+				return nil, err
+			}
+			var body map[string]interface{}
+			err = json.Unmarshal([]byte(strings.Split(msearch, "\n")[1]), &body)
+			return body, err
+		}
+		body, err := translate(Query{})
+		So(err, ShouldBeNil)
+		So(body["_source"], ShouldResemble, map[string]interface{}{
+			"includes": []interface{}{"title", "meta.*"},
+			"excludes": []interface{}{},
+		})
+
+		defaultQuery := map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"price": 10}}}
+		_, err = translate(Query{DefaultQuery: &defaultQuery})
+		So(err, ShouldBeError)
+		customQuery := map[string]interface{}{"query": map[string]interface{}{"range": map[string]interface{}{"meta.year": map[string]interface{}{"gte": 2000}}}}
+		_, err = translate(Query{CustomQuery: &customQuery})
+		So(err, ShouldBeNil)
+		aggs := map[string]interface{}{"aggs": map[string]interface{}{
+			"prices": map[string]interface{}{"terms": map[string]interface{}{"field": "price", "size": 10}},
+		}}
+		_, err = translate(Query{DefaultQuery: &aggs})
+		So(err, ShouldBeError)
+		sortBy := map[string]interface{}{"sort": []interface{}{map[string]interface{}{"price": map[string]interface{}{"order": "asc"}}}}
+		_, err = translate(Query{DefaultQuery: &sortBy})
+		So(err, ShouldBeError)
+		_, err = translate(Query{IncludeFields: &[]string{"price"}})
+		So(err, ShouldBeError)
+		_, err = translate(Query{IncludeFields: &[]string{"meta.tags"}})
+		So(err, ShouldBeNil)
+	})
+	Convey("should validate the config", t, func() {
+		So((&permission.ReactiveSearchConfig{AllowedQueryTypes: []string{"knn"}}).Validate(), ShouldBeError)
+		zero := 0
+		So((&permission.ReactiveSearchConfig{MaxQueries: &zero}).Validate(), ShouldBeError)
+	})
+}
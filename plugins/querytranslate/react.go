@@ -0,0 +1,90 @@
+package querytranslate
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/appbaseio/reactivesearch-api/middleware/guard"
+	"github.com/appbaseio/reactivesearch-api/model/permission"
+	"github.com/appbaseio/reactivesearch-api/plugins/telemetry"
+	log "github.com/sirupsen/logrus"
+)
+
+// validateReact checks the nesting of the react prop of the queries and the
+// number of queries they react to, before the react props get evaluated.
+func validateReact(rsQuery RSQuery, limits permission.RequestLimits) error {
+	for _, query := range rsQuery.Query {
+		if query.React == nil {
+			continue
+		}
+		id := ""
+		if query.ID != nil {
+			id = *query.ID
+		}
+		depth, fanOut := reactComplexity(*query.React)
+		if limits.MaxReactDepth > 0 && depth > limits.MaxReactDepth {
+			return &guard.Error{
+				Code:    http.StatusBadRequest,
+				Limit:   guard.LimitReactDepth,
+				Max:     int64(limits.MaxReactDepth),
+				Message: fmt.Sprintf("query '%s': react prop is nested deeper than %d levels", id, limits.MaxReactDepth),
+			}
+		}
+		if limits.MaxReactFanOut > 0 && fanOut > limits.MaxReactFanOut {
+			return &guard.Error{
+				Code:    http.StatusBadRequest,
+				Limit:   guard.LimitReactFanOut,
+				Max:     int64(limits.MaxReactFanOut),
+				Message: fmt.Sprintf("query '%s': reacts to %d queries, more than the %d allowed", id, fanOut, limits.MaxReactFanOut),
+			}
+		}
+	}
+	return nil
+}
+
+// reactComplexity returns the nesting of the "and", "or", "not" objects and
+// the arrays of the react prop along with the number of query ids it refers to.
+func reactComplexity(react interface{}) (int, int) {
+	switch v := react.(type) {
+	case string:
+		return 0, 1
+	case []interface{}:
+		return childrenComplexity(v)
+	case map[string]interface{}:
+		children := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			children = append(children, child)
+		}
+		return childrenComplexity(children)
+	}
+	return 0, 0
+}
+
+func childrenComplexity(children []interface{}) (int, int) {
+	deepest, fanOut := 0, 0
+	for _, child := range children {
+		depth, count := reactComplexity(child)
+		if depth > deepest {
+			deepest = depth
+		}
+		fanOut += count
+	}
+	return deepest + 1, fanOut
+}
+
+// guardReact rejects the queries with a react prop that exceeds the limits of the request.
+func guardReact(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rsQuery, err := FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			telemetry.WriteBackErrorWithTelemetry(req, w, "error occurred while retrieving request body from context", http.StatusInternalServerError)
+			return
+		}
+		if err := validateReact(*rsQuery, guard.For(req)); err != nil {
+			err.(*guard.Error).WriteBack(w)
+			return
+		}
+		h(w, req)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/middleware/guard"
+	"github.com/appbaseio/reactivesearch-api/middleware/ratelimiter"
 	"github.com/appbaseio/reactivesearch-api/plugins"
 )
 
@@ -12,8 +14,8 @@ var (
 )
 
 func (c *chain) ValidateWrap(h http.HandlerFunc) http.HandlerFunc {
-	// Save request to ctx
-	mw := []middleware.Middleware{saveRequestToCtx}
+	// Limit the anonymous requests, guard and save request to ctx
+	mw := []middleware.Middleware{ratelimiter.LimitAnonymous(), guard.Body(), saveRequestToCtx, guardReact}
 	// Append query translate middleware at the end
 	mw = append(mw, queryTranslate)
 	return c.Adapt(h, mw...)
@@ -25,14 +27,14 @@ func (px *QueryTranslate) routes() []plugins.Route {
 		Name:        "To get the API schema for ReactiveSearch",
 		Methods:     []string{http.MethodGet},
 		Path:        "/_reactivesearch/schema",
-		HandlerFunc: px.HandleApiSchema(),
+		HandlerFunc: ratelimiter.LimitAnonymous()(px.HandleApiSchema()),
 		Description: "Get the API schema for ReactiveSearch endpoint.",
 	})
 	routes = append(routes, plugins.Route{
 		Name:        "To validate reactivesearch query",
 		Methods:     []string{http.MethodPost},
 		Path:        "/_reactivesearch.v3/validate",
-		HandlerFunc: middlewareFunction(px.validate()), // Validate route is an open route, don't apply middleware on it
+		HandlerFunc: middlewareFunction(px.validate()), // Validate route is an open route, only the anonymous rate limit applies to it
 		Description: "Validates the query props and returns the query DSL.",
 	})
 	// Routes without v3 suffix
@@ -40,7 +42,7 @@ func (px *QueryTranslate) routes() []plugins.Route {
 		Name:        "To validate reactivesearch query",
 		Methods:     []string{http.MethodPost},
 		Path:        "/_reactivesearch/validate",
-		HandlerFunc: middlewareFunction(px.validate()), // Validate route is an open route, don't apply middleware on it
+		HandlerFunc: middlewareFunction(px.validate()), // Validate route is an open route, only the anonymous rate limit applies to it
 		Description: "Validates the query props and returns the query DSL.",
 	})
 	return routes
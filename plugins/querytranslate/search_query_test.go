@@ -17,7 +17,7 @@ func transformQuery(query map[string]interface{}) (string, error) {
 	if err2 != nil {
 		return "", err2
 	}
-	q, _, err := translateQuery(body, "127.0.0.1", nil, nil)
+	q, _, err := translateQuery(body, "127.0.0.1", nil, nil, nil)
 	return q, err
 }
 
@@ -7,12 +7,17 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/util"
 	log "github.com/sirupsen/logrus"
 )
 
 // transform the query
-func translateQuery(rsQuery RSQuery, userIP string, queryForId *string, preference *string) (string, []byte, error) {
+func translateQuery(rsQuery RSQuery, userIP string, queryForId *string, preference *string, config *permission.ReactiveSearchConfig) (string, []byte, error) {
+	// Validate the queries against the reactivesearch config of the permission
+	if err := validateQueryPolicy(rsQuery, config); err != nil {
+		return "", nil, err
+	}
 	// Validate custom events
 	if rsQuery.Settings != nil && rsQuery.Settings.CustomEvents != nil {
 		for k, v := range *rsQuery.Settings.CustomEvents {
@@ -208,6 +213,10 @@ func translateQuery(rsQuery RSQuery, userIP string, queryForId *string, preferen
 			if err2 != nil {
 				return mSearchQuery, nil, err2
 			}
+			queryInBytes, err2 = restrictDataFields(queryInBytes, config)
+			if err2 != nil {
+				return mSearchQuery, nil, fmt.Errorf("query '%s': %s", *query.ID, err2)
+			}
 			// Add preference
 			var preferenceId string
 			if preference != nil {
@@ -462,7 +471,7 @@ func GetDefaultScript(backend Backend) string {
 
 // global function to transform the RS API query to _msearch equivalent query
 func TranslateQuery(rsQuery RSQuery, userIP string, queryForId *string, preference *string) (string, []byte, error) {
-	return translateQuery(rsQuery, userIP, queryForId, preference)
+	return translateQuery(rsQuery, userIP, queryForId, preference, nil)
 }
 
 type QueryByType func(query *Query) (*interface{}, error)
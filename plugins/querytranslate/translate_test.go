@@ -18,7 +18,7 @@ func TestTranslateQuery(t *testing.T) {
 				},
 			},
 		}
-		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil)
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, nil)
 		So(err, ShouldBeError)
 	})
 	Convey("with single dataField for geo", t, func() {
@@ -32,7 +32,7 @@ func TestTranslateQuery(t *testing.T) {
 				},
 			},
 		}
-		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil)
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, nil)
 		So(err, ShouldBeNil)
 	})
 	Convey("with single dataField for term", t, func() {
@@ -46,7 +46,7 @@ func TestTranslateQuery(t *testing.T) {
 				},
 			},
 		}
-		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil)
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, nil)
 		So(err, ShouldBeNil)
 	})
 	Convey("with multiple dataFields for search", t, func() {
@@ -59,7 +59,7 @@ func TestTranslateQuery(t *testing.T) {
 				},
 			},
 		}
-		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil)
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, nil)
 		So(err, ShouldBeNil)
 	})
 	Convey("without dataField", t, func() {
@@ -74,7 +74,7 @@ func TestTranslateQuery(t *testing.T) {
 				},
 			},
 		}
-		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil)
+		_, _, err := translateQuery(rsQuery, "127.0.0.1", nil, nil, nil)
 		So(err, ShouldNotBeNil)
 	})
 }
@@ -0,0 +1,252 @@
+package querytranslate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	envSchemaValidation = "REACTIVESEARCH_SCHEMA_VALIDATION"
+	// schemaValidationOff disables the validation of the requests against the schema
+	schemaValidationOff = "off"
+	defaultEngine       = "elasticsearch"
+)
+
+// SchemaError is a violation of the reactivesearch schema, the path is a JSON
+// pointer to the invalid value of the request, e.g. "/query/0/size".
+type SchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaErrors are the violations of the reactivesearch schema by a request.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = fmt.Sprintf("%s: %s", err.Path, err.Message)
+	}
+	return "invalid reactivesearch request: " + strings.Join(messages, "; ")
+}
+
+// rsValidator validates the requests against the reactivesearch schema.
+type rsValidator struct {
+	schema *jsonschema.Schema
+	// engines are the engines supporting the props of the queries and the settings
+	queryEngines    map[string][]string
+	settingsEngines map[string][]string
+	// known are all the engines of the schema
+	known map[string]bool
+}
+
+var (
+	validator     *rsValidator
+	validatorErr  error
+	validatorOnce sync.Once
+)
+
+func getValidator() (*rsValidator, error) {
+	validatorOnce.Do(func() {
+		validator, validatorErr = newRSValidator()
+	})
+	return validator, validatorErr
+}
+
+func newRSValidator() (*rsValidator, error) {
+	raw, err := GetReactiveSearchSchema()
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		ID         string `json:"$id"`
+		Properties struct {
+			Query struct {
+				Items struct {
+					Properties map[string]struct {
+						Engine []string `json:"engine"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"query"`
+			Settings struct {
+				Properties map[string]struct {
+					Engine []string `json:"engine"`
+				} `json:"properties"`
+			} `json:"settings"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	if err := compiler.AddResource(doc.ID, bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	v := &rsValidator{
+		schema:          schema,
+		queryEngines:    make(map[string][]string),
+		settingsEngines: make(map[string][]string),
+		known:           make(map[string]bool),
+	}
+	for prop, value := range doc.Properties.Query.Items.Properties {
+		v.queryEngines[prop] = value.Engine
+		for _, engine := range value.Engine {
+			v.known[engine] = true
+		}
+	}
+	for prop, value := range doc.Properties.Settings.Properties {
+		v.settingsEngines[prop] = value.Engine
+	}
+	return v, nil
+}
+
+// ValidateRSQuery strictly validates a reactivesearch request body against the
+// schema of the API: the unknown props, the values of the wrong type and the
+// props that the backend of the request doesn't support are reported as
+// SchemaErrors, sorted by their paths.
+func ValidateRSQuery(body []byte) error {
+	v, err := getValidator()
+	if err != nil {
+		return fmt.Errorf("error while compiling the reactivesearch schema: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var instance interface{}
+	if err := decoder.Decode(&instance); err != nil {
+		return SchemaErrors{{Path: "", Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	var errs SchemaErrors
+	if err := v.schema.Validate(instance); err != nil {
+		validationErr, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return err
+		}
+		errs = append(errs, leafErrors(validationErr)...)
+	}
+	errs = append(errs, v.unsupportedProps(instance)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+// leafErrors returns the causes of the validation error, the errors wrapping
+// other errors only point at the failing schemas.
+func leafErrors(err *jsonschema.ValidationError) SchemaErrors {
+	if len(err.Causes) == 0 {
+		return SchemaErrors{{Path: err.InstanceLocation, Message: err.Message}}
+	}
+	var errs SchemaErrors
+	for _, cause := range err.Causes {
+		errs = append(errs, leafErrors(cause)...)
+	}
+	return errs
+}
+
+// unsupportedProps reports the props of the queries and the settings that
+// aren't supported by the backend of the request.
+func (v *rsValidator) unsupportedProps(instance interface{}) SchemaErrors {
+	request, ok := instance.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	engine := defaultEngine
+	settings, _ := request["settings"].(map[string]interface{})
+	if backend, ok := settings["backend"].(string); ok {
+		engine = backend
+	}
+	// the backends without a prop listing them aren't checked
+	if !v.known[engine] {
+		return nil
+	}
+	var errs SchemaErrors
+	for prop := range settings {
+		// the backend prop selects the engine
+		if prop == "backend" {
+			continue
+		}
+		if !supports(v.settingsEngines[prop], engine) {
+			errs = append(errs, SchemaError{
+				Path:    "/settings/" + escapePointer(prop),
+				Message: fmt.Sprintf("'%s' is not supported by the %s backend", prop, engine),
+			})
+		}
+	}
+	queries, _ := request["query"].([]interface{})
+	for i, item := range queries {
+		query, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for prop := range query {
+			if !supports(v.queryEngines[prop], engine) {
+				errs = append(errs, SchemaError{
+					Path:    fmt.Sprintf("/query/%d/%s", i, escapePointer(prop)),
+					Message: fmt.Sprintf("'%s' is not supported by the %s backend", prop, engine),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// supports returns true if the engine is one of the engines of a prop, the
+// unknown props are reported by the schema instead.
+func supports(engines []string, engine string) bool {
+	if engines == nil {
+		return true
+	}
+	for _, e := range engines {
+		if e == engine {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePointer escapes a reference token of a JSON pointer.
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// isSchemaValidationEnabled returns false if the strict validation of the
+// requests is turned off with the REACTIVESEARCH_SCHEMA_VALIDATION env var.
+func isSchemaValidationEnabled() bool {
+	return os.Getenv(envSchemaValidation) != schemaValidationOff
+}
+
+// writeSchemaErrors writes back the violations of the schema along with their paths.
+func writeSchemaErrors(w http.ResponseWriter, errs SchemaErrors) {
+	raw, err := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    http.StatusBadRequest,
+			"status":  http.StatusText(http.StatusBadRequest),
+			"message": errs.Error(),
+			"errors":  errs,
+		},
+	})
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(raw)
+}
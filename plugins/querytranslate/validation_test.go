@@ -0,0 +1,39 @@
+package querytranslate
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidateRSQuery(t *testing.T) {
+	Convey("should accept a valid request", t, func() {
+		body := `{"query":[{"id":"search","dataField":["title"],"size":10,"value":"harry"}],"settings":{"recordAnalytics":true}}`
+		So(ValidateRSQuery([]byte(body)), ShouldBeNil)
+	})
+	Convey("should point at the unknown props and the values of the wrong type", t, func() {
+		body := `{"query":[{"id":"search","size":"10"},{"id":"result","sizee":5}]}`
+		err := ValidateRSQuery([]byte(body))
+		So(err, ShouldHaveSameTypeAs, SchemaErrors{})
+		errs := err.(SchemaErrors)
+		So(errs, ShouldHaveLength, 2)
+		So(errs[0].Path, ShouldEqual, "/query/0/size")
+		So(errs[1].Path, ShouldEqual, "/query/1")
+		So(errs[1].Message, ShouldContainSubstring, "sizee")
+	})
+	Convey("should reject the props that the backend doesn't support", t, func() {
+		body := `{"query":[{"id":"search","distinctField":"author"}],"settings":{"backend":"mongodb"}}`
+		err := ValidateRSQuery([]byte(body))
+		So(err, ShouldResemble, SchemaErrors{{
+			Path:    "/query/0/distinctField",
+			Message: "'distinctField' is not supported by the mongodb backend",
+		}})
+		body = `{"query":[{"id":"search","distinctField":"author"}]}`
+		So(ValidateRSQuery([]byte(body)), ShouldBeNil)
+	})
+	Convey("should report the invalid JSON at the root", t, func() {
+		err := ValidateRSQuery([]byte(`{"query":`))
+		So(err, ShouldNotBeNil)
+		So(err.(SchemaErrors)[0].Path, ShouldEqual, "")
+	})
+}
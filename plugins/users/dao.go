@@ -8,63 +8,39 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/model/user"
-	"github.com/appbaseio/reactivesearch-api/util"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type elasticsearch struct {
-	indexName string
+type credentials struct {
+	store credentialstore.CredentialStore
 }
 
-func initPlugin(indexName, mapping string) (*elasticsearch, error) {
-	ctx := context.Background()
-
-	es := &elasticsearch{indexName}
-	defer func() {
-		if es != nil {
-			if err := es.postMasterUser(); err != nil {
-				log.Errorln(logTag, ":", err)
-			}
-		}
-	}()
-
-	// Check if the meta index already exists
-	exists, err := util.GetClient7().IndexExists(indexName).
-		Do(ctx)
+func initPlugin() (*credentials, error) {
+	store, err := credentialstore.Instance()
 	if err != nil {
-		return nil, fmt.Errorf("%s: error while checking if index already exists: %v",
-			logTag, err)
+		return nil, err
 	}
-	if exists {
-		log.Println(logTag, ": index named", indexName, "already exists, skipping...")
-		// hash the passwords if not hashed already
-		err := es.hashPasswords()
-		if err != nil {
-			return nil, err
-		}
-
-		return es, nil
+	if err := store.Init(context.Background()); err != nil {
+		return nil, err
 	}
 
-	replicas := util.GetReplicas()
-	settings := fmt.Sprintf(mapping, util.HiddenIndexSettings(), replicas)
-	// Meta index does not exists, create a new one
-	_, err = util.GetClient7().CreateIndex(indexName).
-		Body(settings).
-		Do(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("%s: error while creating index named %s: %v",
-			logTag, indexName, err)
+	c := &credentials{store}
+	// hash the passwords if not hashed already
+	if err := c.hashPasswords(); err != nil {
+		return nil, err
+	}
+	if err := c.postMasterUser(); err != nil {
+		log.Errorln(logTag, ":", err)
 	}
 
-	log.Println(logTag, ": successfully created index named", indexName)
-	return es, nil
+	return c, nil
 }
 
-func (es *elasticsearch) hashPasswords() error {
+func (c *credentials) hashPasswords() error {
 	// get all users
-	rawUsers, err := es.getRawUsers(context.Background())
+	rawUsers, err := c.getRawUsers(context.Background())
 	if err != nil {
 		return err
 	}
@@ -90,7 +66,7 @@ func (es *elasticsearch) hashPasswords() error {
 		}
 
 		// patch the user
-		_, err = es.patchUser(context.Background(), user.Username, map[string]interface{}{
+		_, err = c.patchUser(context.Background(), user.Username, map[string]interface{}{
 			"password":           string(hashedPassword),
 			"password_hash_type": "bcrypt",
 		})
@@ -105,7 +81,7 @@ func (es *elasticsearch) hashPasswords() error {
 	return nil
 }
 
-func (es *elasticsearch) postMasterUser() error {
+func (c *credentials) postMasterUser() error {
 	// Create a master user, if credentials are not provided, we create a default
 	// master user. ReactiveSearch shouldn't be initialized without a root user.
 	username, password := os.Getenv("USERNAME"), os.Getenv("PASSWORD")
@@ -127,14 +103,14 @@ func (es *elasticsearch) postMasterUser() error {
 
 	admin.PasswordHashType = "bcrypt"
 
-	if created, err := es.postUser(context.Background(), *admin); !created || err != nil {
+	if created, err := c.postUser(context.Background(), *admin); !created || err != nil {
 		return fmt.Errorf("%s: error while creating a master user: %v", logTag, err)
 	}
 	return nil
 }
 
-func (es *elasticsearch) getUser(ctx context.Context, username string) (*user.User, error) {
-	raw, err := es.getRawUser(ctx, username)
+func (c *credentials) getUser(ctx context.Context, username string) (*user.User, error) {
+	raw, err := c.getRawUser(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -148,53 +124,44 @@ func (es *elasticsearch) getUser(ctx context.Context, username string) (*user.Us
 	return &u, nil
 }
 
-func (es *elasticsearch) getRawUsers(ctx context.Context) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawUsersEs6(ctx)
-	default:
-		return es.getRawUsersEs7(ctx)
+func (c *credentials) getRawUsers(ctx context.Context) ([]byte, error) {
+	users, err := c.store.GetRawUsers(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	return json.Marshal(users)
 }
 
-func (es *elasticsearch) getRawUser(ctx context.Context, username string) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.getRawUserEs6(ctx, username)
-	default:
-		return es.getRawUserEs7(ctx, username)
-	}
+func (c *credentials) getRawUser(ctx context.Context, username string) ([]byte, error) {
+	return c.store.GetRawUser(ctx, username)
 }
 
-func (es *elasticsearch) postUser(ctx context.Context, u user.User) (bool, error) {
-	_, err := util.GetClient7().Index().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(u.Username).
-		BodyJson(u).
-		Do(ctx)
-	if err != nil {
+func (c *credentials) postUser(ctx context.Context, u user.User) (bool, error) {
+	if err := c.store.PutUser(ctx, u); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (es *elasticsearch) patchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
-	switch util.GetVersion() {
-	case 6:
-		return es.patchUserEs6(ctx, username, patch)
-	default:
-		return es.patchUserEs7(ctx, username, patch)
+func (c *credentials) patchUser(ctx context.Context, username string, patch map[string]interface{}) ([]byte, error) {
+	return c.store.PatchUser(ctx, username, patch)
+}
+
+func (c *credentials) deleteUser(ctx context.Context, username string) (bool, error) {
+	if err := c.store.DeleteUser(ctx, username); err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func (es *elasticsearch) deleteUser(ctx context.Context, username string) (bool, error) {
-	_, err := util.GetClient7().Delete().
-		Refresh("wait_for").
-		Index(es.indexName).
-		Id(username).
-		Do(ctx)
+func (c *credentials) isRoleDefined(ctx context.Context, name string) (bool, error) {
+	_, err := c.store.GetRawRole(ctx, name)
+	if err == credentialstore.ErrNotFound {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
@@ -127,6 +127,7 @@ var defaultUser = map[string]interface{}{
 }
 
 func TestUser(t *testing.T) {
+	util.SkipWithoutES(t)
 	build := util.BuildArc{}
 	util.StartArc(&build)
 	build.Start()
@@ -9,6 +9,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/appbaseio/reactivesearch-api/model/permission"
 	"github.com/appbaseio/reactivesearch-api/model/user"
 	"github.com/appbaseio/reactivesearch-api/plugins/auth"
 	"github.com/appbaseio/reactivesearch-api/util"
@@ -35,7 +36,7 @@ func (u *Users) getUser() http.HandlerFunc {
 		}
 
 		// fetch the user from elasticsearch
-		rawUser, err := u.es.getRawUser(req.Context(), username)
+		rawUser, err := u.store.getRawUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -56,7 +57,7 @@ func (u *Users) getUserWithUsername() http.HandlerFunc {
 			return
 		}
 
-		rawUser, err := u.es.getRawUser(req.Context(), username)
+		rawUser, err := u.store.getRawUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
@@ -107,6 +108,14 @@ func (u *Users) postUser() http.HandlerFunc {
 		if userBody.Indices != nil {
 			opts = append(opts, user.SetIndices(userBody.Indices))
 		}
+		if userBody.Roles != nil {
+			opts = append(opts, user.SetRoles(userBody.Roles, func(name string) (bool, error) {
+				return u.store.isRoleDefined(req.Context(), name)
+			}))
+		}
+		if userBody.Limits != nil {
+			opts = append(opts, user.SetLimits(userBody.Limits))
+		}
 		if userBody.Username == "" {
 			util.WriteBackError(w, `can't create a user without a "username"`, http.StatusBadRequest)
 			return
@@ -115,6 +124,10 @@ func (u *Users) postUser() http.HandlerFunc {
 			util.WriteBackError(w, `user "password" shouldn't be empty`, http.StatusBadRequest)
 			return
 		}
+		if err := user.GetPasswordPolicy().Validate(userBody.Username, userBody.Password); err != nil {
+			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		// If user is not an admin then at least one action must present
 		if userBody.IsAdmin == nil || !*userBody.IsAdmin {
 			if userBody.AllowedActions == nil || len(*userBody.AllowedActions) == 0 {
@@ -155,7 +168,7 @@ func (u *Users) postUser() http.HandlerFunc {
 			return
 		}
 
-		ok, err := u.es.postUser(req.Context(), *newUser)
+		ok, err := u.store.postUser(req.Context(), *newUser)
 		if ok && err == nil {
 			// Subscribe to down time alerts
 			if newUser.HasAction(user.DowntimeAlerts) {
@@ -210,23 +223,33 @@ func (u *Users) patchUser() http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if patch["roles"] != nil {
+			err := permission.ValidateRoles(userBody.Roles, func(name string) (bool, error) {
+				return u.store.isRoleDefined(req.Context(), name)
+			})
+			if err != nil {
+				util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		// If user is trying to update the password then store the hashed password
+		// If user is trying to update the password then store the hashed password,
+		// once it passes the password policy and the reuse check
 		if patch["password"] != nil {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userBody.Password), bcrypt.DefaultCost)
+			passwordPatch, status, err := u.getPasswordPatch(req.Context(), username, userBody.Password)
 			if err != nil {
-				msg := fmt.Sprintf("an error occurred while hashing password: %v", userBody.Password)
-				log.Errorln(logTag, ":", msg, ":", err)
-				util.WriteBackError(w, msg, http.StatusInternalServerError)
+				util.WriteBackError(w, err.Error(), status)
 				return
 			}
-			patch["password"] = string(hashedPassword)
+			for key, value := range passwordPatch {
+				patch[key] = value
+			}
 		}
 
 		// Set the updated_at field
 		patch["updated_at"] = time.Now().Format(time.RFC3339)
 
-		_, err2 := u.es.patchUser(req.Context(), username, patch)
+		_, err2 := u.store.patchUser(req.Context(), username, patch)
 		if err2 == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -333,23 +356,33 @@ func (u *Users) patchUserWithUsername() http.HandlerFunc {
 			util.WriteBackError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if patch["roles"] != nil {
+			err := permission.ValidateRoles(userBody.Roles, func(name string) (bool, error) {
+				return u.store.isRoleDefined(req.Context(), name)
+			})
+			if err != nil {
+				util.WriteBackError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		// If user is trying to update the password then store the hashed password
+		// If user is trying to update the password then store the hashed password,
+		// once it passes the password policy and the reuse check
 		if patch["password"] != nil {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userBody.Password), bcrypt.DefaultCost)
+			passwordPatch, status, err := u.getPasswordPatch(req.Context(), username, userBody.Password)
 			if err != nil {
-				msg := fmt.Sprintf("an error occurred while hashing password: %v", userBody.Password)
-				log.Errorln(logTag, ":", msg, ":", err)
-				util.WriteBackError(w, msg, http.StatusInternalServerError)
+				util.WriteBackError(w, err.Error(), status)
 				return
 			}
-			patch["password"] = string(hashedPassword)
+			for key, value := range passwordPatch {
+				patch[key] = value
+			}
 		}
 
 		// Set the updated_at
 		patch["updated_at"] = time.Now().Format(time.RFC3339)
 
-		_, err2 := u.es.patchUser(req.Context(), username, patch)
+		_, err2 := u.store.patchUser(req.Context(), username, patch)
 		if err2 == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -430,14 +463,14 @@ func (u *Users) deleteUser() http.HandlerFunc {
 			return
 		}
 
-		userDetails, err := u.es.getUser(req.Context(), username)
+		userDetails, err := u.store.getUser(req.Context(), username)
 		if err != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err)
 			util.WriteBackError(w, msg, http.StatusNotFound)
 			return
 		}
-		ok, err := u.es.deleteUser(req.Context(), username)
+		ok, err := u.store.deleteUser(req.Context(), username)
 		if ok && err == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -507,14 +540,14 @@ func (u *Users) deleteUserWithUsername() http.HandlerFunc {
 			util.WriteBackMessage(w, msg, http.StatusOK)
 			return
 		}
-		userDetails, err2 := u.es.getUser(req.Context(), username)
+		userDetails, err2 := u.store.getUser(req.Context(), username)
 		if err2 != nil {
 			msg := fmt.Sprintf(`user with "username"="%s" not found`, username)
 			log.Errorln(logTag, ":", msg, ":", err2)
 			util.WriteBackError(w, msg, http.StatusNotFound)
 			return
 		}
-		ok, err := u.es.deleteUser(req.Context(), username)
+		ok, err := u.store.deleteUser(req.Context(), username)
 		if ok && err == nil {
 			// Only update local state when proxy API has not been called
 			// If proxy API would get called then it would automatically update the
@@ -568,7 +601,7 @@ func (u *Users) deleteUserWithUsername() http.HandlerFunc {
 
 func (u *Users) getAllUsers() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		raw, err := u.es.getRawUsers(req.Context())
+		raw, err := u.store.getRawUsers(req.Context())
 		if err != nil {
 			msg := `an error occurred while fetching users`
 			log.Errorln(logTag, ":", err)
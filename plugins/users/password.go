@@ -0,0 +1,156 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/appbaseio/reactivesearch-api/model/user"
+	"github.com/appbaseio/reactivesearch-api/plugins/auth"
+	"github.com/appbaseio/reactivesearch-api/util"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type passwordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// getPasswordPatch validates the new password of the user against the password
+// policy, it returns the patch to store the hashed password along with the
+// status code to respond with in case of an error.
+func (u *Users) getPasswordPatch(ctx context.Context, username, password string) (map[string]interface{}, int, error) {
+	policy := user.GetPasswordPolicy()
+	if err := policy.Validate(username, password); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	reqUser, err := u.store.getUser(ctx, username)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		return nil, http.StatusNotFound, fmt.Errorf(`user with "username"="%s" not found`, username)
+	}
+	if reqUser.IsPasswordReused(password, policy.HistorySize) {
+		return nil, http.StatusBadRequest, fmt.Errorf("password must not be the same as the current password or the last %d previous passwords", policy.HistorySize)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Errorln(logTag, ":", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("an error occurred while hashing password")
+	}
+
+	return reqUser.GetPasswordPatch(string(hashedPassword), policy.HistorySize, time.Now()), http.StatusOK, nil
+}
+
+func (u *Users) patchUserPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		username, ok := vars["username"]
+		if !ok {
+			util.WriteBackError(w, `can't change the password without a "username"`, http.StatusBadRequest)
+			return
+		}
+
+		reqUser, err := user.FromContext(req.Context())
+		if err != nil {
+			log.Errorln(logTag, ":", err)
+			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+			util.WriteBackError(w, "password can only be changed with the user credentials", http.StatusUnauthorized)
+			return
+		}
+		isAdmin := *reqUser.IsAdmin || reqUser.HasAction(user.UserManagement)
+		if !isAdmin && reqUser.Username != username {
+			msg := fmt.Sprintf(`user with "username"="%s" can't change the password of other users`, reqUser.Username)
+			w.Header().Set("www-authenticate", "Basic realm=\"Authentication Required\"")
+			util.WriteBackError(w, msg, http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := "can't read request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		var change passwordChange
+		err = json.Unmarshal(body, &change)
+		if err != nil {
+			msg := "can't parse request body"
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if change.NewPassword == "" {
+			util.WriteBackError(w, `"new_password" shouldn't be empty`, http.StatusBadRequest)
+			return
+		}
+
+		// the admins can reset the passwords, rest must prove that they know the current one
+		if !isAdmin {
+			var matched bool
+			if reqUser.PasswordHashType == "" {
+				matched = reqUser.Password == change.OldPassword
+			} else {
+				matched = bcrypt.CompareHashAndPassword([]byte(reqUser.Password), []byte(change.OldPassword)) == nil
+			}
+			if !matched {
+				util.WriteBackError(w, `"old_password" is incorrect`, http.StatusUnauthorized)
+				return
+			}
+		}
+
+		patch, status, err := u.getPasswordPatch(req.Context(), username, change.NewPassword)
+		if err != nil {
+			util.WriteBackError(w, err.Error(), status)
+			return
+		}
+
+		_, err = u.store.patchUser(req.Context(), username, patch)
+		if err != nil {
+			msg := fmt.Sprintf(`an error occurred while changing the password of user with "username"="%s"`, username)
+			log.Errorln(logTag, ":", msg, ":", err)
+			util.WriteBackError(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		if util.ShouldProxyToACCAPI() {
+			// Invoke ACCAPI to clear the cached user on all the machines
+			res, err := util.ProxyACCAPI(util.ProxyConfig{
+				Method: http.MethodPatch,
+				URL:    "/_user/" + username,
+				Body:   nil,
+			})
+			if err != nil {
+				log.Errorln(logTag, ":", err)
+				util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Failed to update all nodes, return error response
+			if res != nil {
+				log.Errorln(logTag, ":", "error encountered changing the user password")
+				bodyBytes, err := ioutil.ReadAll(res.Body)
+				if err != nil {
+					log.Errorln(logTag, ":", err)
+					util.WriteBackError(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				util.WriteBackRaw(w, bodyBytes, res.StatusCode)
+				return
+			}
+		} else {
+			// clear user details locally
+			auth.ClearLocalUser(username)
+		}
+
+		util.WriteBackMessage(w, "Password is changed successfully", http.StatusOK)
+	}
+}
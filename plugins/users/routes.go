@@ -51,6 +51,13 @@ func (u *Users) routes() []plugins.Route {
 			HandlerFunc: middleware(hasUserAccess(u.patchUserWithUsername())),
 			Description: "Modifies the user with {username}",
 		},
+		{
+			Name:        "Change password of user with {username}",
+			Methods:     []string{http.MethodPatch},
+			Path:        "/_user/{username}/password",
+			HandlerFunc: middleware(u.patchUserPassword()),
+			Description: "Changes the password of the user with {username}, the old password is required for non-admin users",
+		},
 		{
 			Name:        "Delete user",
 			Methods:     []string{http.MethodDelete},
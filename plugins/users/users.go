@@ -1,21 +1,18 @@
 package users
 
 import (
-	"os"
 	"sync"
 
 	"github.com/appbaseio/reactivesearch-api/middleware"
+	"github.com/appbaseio/reactivesearch-api/model/credentialstore"
 	"github.com/appbaseio/reactivesearch-api/plugins"
 	"github.com/appbaseio/reactivesearch-api/util"
 )
 
 const (
 	logTag              = "[users]"
-	envUsersEsIndex     = "USERS_ES_INDEX"
-	typeName            = "_doc"
 	envEsURL            = "ES_CLUSTER_URL"
 	defaultUsersEsIndex = ".users"
-	settings            = `{ "settings" : { %s "index.number_of_shards" : 1, "index.number_of_replicas" : %d } }`
 )
 
 var (
@@ -25,7 +22,7 @@ var (
 
 // Users plugin deals with user management.
 type Users struct {
-	es userService
+	store userService
 }
 
 // Use only this function to fetch the instance of user from within
@@ -43,24 +40,21 @@ func (u *Users) Name() string {
 
 // InitFunc is the implementation of Plugin interface.
 func (u *Users) InitFunc() error {
-	// fetch vars from env
-	indexName := os.Getenv(envUsersEsIndex)
-	if indexName == "" {
-		indexName = defaultUsersEsIndex
-	}
-
 	// initialize the dao
 	var err error
-	u.es, err = initPlugin(indexName, settings)
+	u.store, err = initPlugin()
 	if err != nil {
 		return err
 	}
 
-	// Set plugin cache sync script
-	s := CacheSyncScript{
-		index: indexName,
+	// the embedded store is local to the node, there is nothing to sync
+	if !credentialstore.IsEmbedded() {
+		// Set plugin cache sync script
+		s := CacheSyncScript{
+			index: credentialstore.UserIndex(),
+		}
+		util.AddSyncScript(s)
 	}
-	util.AddSyncScript(s)
 
 	return nil
 }
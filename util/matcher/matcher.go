@@ -0,0 +1,322 @@
+// Package matcher compiles the glob patterns of the referers, the index and the
+// field patterns and the CIDR sources once and caches them, so that the requests
+// don't have to compile them over and over again.
+//
+// The glob patterns are anchored and matched literally, except for "*" that
+// matches any characters, e.g. "https://*.example.com/*" matches neither
+// "https://appxexample.com/" nor "https://app.example.com.evil.io/".
+//
+// A referer pattern matches the URLs it's a prefix of up to a path, a query or
+// a fragment, e.g. "https://example.com" matches "https://example.com/page",
+// and a referer pattern without a scheme matches any scheme.
+//
+// The referer, index and field patterns stored before the globs were regular
+// expressions, the ones holding a regex meta character other than "." and "*",
+// e.g. "^https://(www\.)?example\.com", keep being matched the way they used
+// to be so that the stored permissions don't have to be migrated.
+package matcher
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxEntries is the number of compiled matchers of a generation of the cache.
+const maxEntries = 1024
+
+// Kinds of the matchers, part of the keys of the cache.
+const (
+	kindGlobs    = "globs"
+	kindFields   = "fields"
+	kindReferers = "referers"
+	kindIndices  = "indices"
+	kindCIDRs    = "cidrs"
+)
+
+// legacyMeta are the regex meta characters that tell apart the patterns stored
+// as regular expressions, "." and "*" are common in the globs as well.
+const legacyMeta = `^$()[]{}|+\`
+
+// allowAllAddresses are the sources that allow any IP.
+var allowAllAddresses = []string{"::/0", "0000:0000:0000:0000:0000:0000:0000:0000/0", "0.0.0.0/0"}
+
+// Set matches the strings against a set of glob patterns.
+type Set struct {
+	// all is true if a pattern is "*"
+	all bool
+	// exact are the patterns without a "*", matched with a lookup
+	exact map[string]bool
+	// fields is true if a pattern matches the sub fields as well
+	fields bool
+	// re is the alternation of the patterns with a "*"
+	re *regexp.Regexp
+	// legacy are the regular expressions of the patterns stored before the globs
+	legacy []*regexp.Regexp
+}
+
+// setOptions tell how the patterns of a set are compiled.
+type setOptions struct {
+	// fields is true if a pattern matches the sub fields as well
+	fields bool
+	// prefixes is true if a pattern matches the URLs it's a prefix of
+	prefixes bool
+	// legacy returns the regular expression a stored pattern used to be
+	// compiled to, nil if the set doesn't accept the legacy patterns
+	legacy func(pattern string) string
+}
+
+// Match returns true if the value is matched by one of the patterns of the set.
+func (s *Set) Match(value string) bool {
+	if s.all {
+		return true
+	}
+	if s.exact[value] {
+		return true
+	}
+	if s.fields {
+		// "a.b.c" is a sub field of the "a" and "a.b" patterns
+		for i := strings.IndexByte(value, '.'); i >= 0; i = nextDot(value, i) {
+			if s.exact[value[:i]] {
+				return true
+			}
+		}
+	}
+	if s.re != nil && s.re.MatchString(value) {
+		return true
+	}
+	for _, re := range s.legacy {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func nextDot(value string, i int) int {
+	next := strings.IndexByte(value[i+1:], '.')
+	if next < 0 {
+		return -1
+	}
+	return i + 1 + next
+}
+
+// Networks matches the IPs against a set of CIDR sources.
+type Networks struct {
+	all  bool
+	nets []*net.IPNet
+}
+
+// Contains returns true if the IP is part of one of the networks.
+func (n *Networks) Contains(ip net.IP) bool {
+	if n.all {
+		return true
+	}
+	for _, ipNet := range n.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func globBody(pattern string) string {
+	return strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+}
+
+// Globs returns the compiled set of the glob patterns.
+func Globs(patterns []string) *Set {
+	return defaultCache.get(kindGlobs, patterns, func() (interface{}, error) {
+		return compileSet(patterns, setOptions{}), nil
+	}).(*Set)
+}
+
+// Referers returns the compiled set of the referer patterns.
+func Referers(patterns []string) *Set {
+	return defaultCache.get(kindReferers, patterns, func() (interface{}, error) {
+		return compileSet(patterns, setOptions{prefixes: true, legacy: legacyUnanchored}), nil
+	}).(*Set)
+}
+
+// Indices returns the compiled set of the index patterns.
+func Indices(patterns []string) *Set {
+	return defaultCache.get(kindIndices, patterns, func() (interface{}, error) {
+		return compileSet(patterns, setOptions{legacy: legacyIndex}), nil
+	}).(*Set)
+}
+
+// Fields returns the compiled set of the field patterns, a field pattern
+// matches the dot separated sub fields of the fields it matches, e.g. "user"
+// and "us*" match "user.name".
+func Fields(patterns []string) *Set {
+	return defaultCache.get(kindFields, patterns, func() (interface{}, error) {
+		return compileSet(patterns, setOptions{fields: true, legacy: legacyUnanchored}), nil
+	}).(*Set)
+}
+
+// CIDRs returns the compiled networks of the sources in the CIDR notation.
+func CIDRs(sources []string) (*Networks, error) {
+	var err error
+	networks := defaultCache.get(kindCIDRs, sources, func() (interface{}, error) {
+		var n *Networks
+		n, err = compileNetworks(sources)
+		return n, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return networks.(*Networks), nil
+}
+
+// Forget drops the compiled matchers of the patterns from the cache.
+func Forget(patterns []string) {
+	defaultCache.forget(patterns)
+}
+
+func compileSet(patterns []string, opts setOptions) *Set {
+	s := &Set{exact: make(map[string]bool), fields: opts.fields}
+	var exprs []string
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			s.all = true
+			continue
+		}
+		if opts.legacy != nil && strings.ContainsAny(pattern, legacyMeta) {
+			// the patterns that aren't valid expressions are matched as globs
+			if re, err := regexp.Compile(opts.legacy(pattern)); err == nil {
+				s.legacy = append(s.legacy, re)
+				continue
+			}
+		}
+		if opts.prefixes {
+			exprs = append(exprs, prefixBody(pattern))
+			continue
+		}
+		if !strings.Contains(pattern, "*") {
+			s.exact[pattern] = true
+			continue
+		}
+		exprs = append(exprs, globBody(pattern))
+	}
+	if len(exprs) > 0 {
+		suffix := ""
+		if opts.fields {
+			suffix = `(\..*)?`
+		}
+		s.re = regexp.MustCompile("^(?:" + strings.Join(exprs, "|") + ")" + suffix + "$")
+	}
+	return s
+}
+
+// prefixBody returns the expression of a referer pattern, the pattern matches
+// the URLs that continue it with a path, a query or a fragment.
+func prefixBody(pattern string) string {
+	body := globBody(pattern)
+	if !strings.Contains(pattern, "://") && !strings.HasPrefix(pattern, "*") {
+		body = `(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?` + body
+	}
+	switch {
+	case strings.HasSuffix(pattern, "*"):
+	case strings.HasSuffix(pattern, "/"):
+		body += ".*"
+	default:
+		body += `(?:[/?#].*)?`
+	}
+	return body
+}
+
+// legacyUnanchored is the expression the referer and the field patterns used
+// to be compiled to.
+func legacyUnanchored(pattern string) string {
+	return strings.Replace(pattern, "*", ".*", -1)
+}
+
+// legacyIndex is the expression the index patterns used to be compiled to.
+func legacyIndex(pattern string) string {
+	pattern = strings.Replace(pattern, "*", ".*", -1)
+	if !strings.HasSuffix(pattern, ".*") {
+		pattern += `\b`
+	}
+	if !strings.HasPrefix(pattern, ".*") {
+		pattern = `\b` + pattern
+	}
+	return pattern
+}
+
+func compileNetworks(sources []string) (*Networks, error) {
+	n := &Networks{}
+	for _, source := range sources {
+		for _, address := range allowAllAddresses {
+			if source == address {
+				n.all = true
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(source)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid source "%s": %v`, source, err)
+		}
+		n.nets = append(n.nets, ipNet)
+	}
+	return n, nil
+}
+
+// cache keeps the compiled matchers of the last two generations, a matcher
+// of the previous generation is promoted when used so that the unused ones
+// are dropped once the current generation is full.
+type cache struct {
+	mu       sync.Mutex
+	current  map[string]interface{}
+	previous map[string]interface{}
+}
+
+var defaultCache = newCache()
+
+func newCache() *cache {
+	return &cache{
+		current:  make(map[string]interface{}),
+		previous: make(map[string]interface{}),
+	}
+}
+
+func cacheKey(kind string, patterns []string) string {
+	return kind + "\x00" + strings.Join(patterns, "\x00")
+}
+
+func (c *cache) get(kind string, patterns []string, compile func() (interface{}, error)) interface{} {
+	key := cacheKey(kind, patterns)
+	c.mu.Lock()
+	if m, ok := c.current[key]; ok {
+		c.mu.Unlock()
+		return m
+	}
+	m, ok := c.previous[key]
+	c.mu.Unlock()
+	if !ok {
+		var err error
+		m, err = compile()
+		if err != nil {
+			// the errors aren't cached so that they are reported every time
+			return m
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.current) >= maxEntries {
+		c.previous = c.current
+		c.current = make(map[string]interface{})
+	}
+	c.current[key] = m
+	return m
+}
+
+func (c *cache) forget(patterns []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, kind := range []string{kindGlobs, kindFields, kindReferers, kindIndices, kindCIDRs} {
+		key := cacheKey(kind, patterns)
+		delete(c.current, key)
+		delete(c.previous, key)
+	}
+}
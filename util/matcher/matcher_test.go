@@ -0,0 +1,160 @@
+package matcher
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatcher(t *testing.T) {
+	Convey("should anchor and escape the globs", t, func() {
+		referers := Globs([]string{"https://*.example.com/*", "http://localhost:3000"})
+		So(referers.Match("https://app.example.com/search"), ShouldBeTrue)
+		So(referers.Match("http://localhost:3000"), ShouldBeTrue)
+		So(referers.Match("https://app.example.com.evil.io/"), ShouldBeFalse)
+		So(referers.Match("https://appxexample.com/"), ShouldBeFalse)
+		So(referers.Match("http://localhost:30001"), ShouldBeFalse)
+		So(Globs([]string{"*"}).Match("anything"), ShouldBeTrue)
+		So(Globs(nil).Match(""), ShouldBeFalse)
+	})
+	Convey("should match the referers the patterns are a prefix of", t, func() {
+		referers := Referers([]string{"https://example.com", "http://localhost:3000/", "shop.io", "*.example.org"})
+		So(referers.Match("https://example.com"), ShouldBeTrue)
+		So(referers.Match("https://example.com/page"), ShouldBeTrue)
+		So(referers.Match("https://example.com?q=1"), ShouldBeTrue)
+		So(referers.Match("https://example.com.evil.io/"), ShouldBeFalse)
+		So(referers.Match("https://example.community/"), ShouldBeFalse)
+		So(referers.Match("http://localhost:3000/search"), ShouldBeTrue)
+		So(referers.Match("http://localhost:30001/"), ShouldBeFalse)
+		So(referers.Match("https://shop.io/cart"), ShouldBeTrue)
+		So(referers.Match("https://myshop.io/cart"), ShouldBeFalse)
+		So(referers.Match("https://app.example.org/page"), ShouldBeTrue)
+		So(referers.Match("https://app.example.org.evil.io/"), ShouldBeFalse)
+	})
+	Convey("should match the stored regex patterns as they used to", t, func() {
+		referers := Referers([]string{`^https://(www\.)?example\.com`, "https://app[0-9].example.io"})
+		So(referers.Match("https://www.example.com/page"), ShouldBeTrue)
+		So(referers.Match("https://example.com"), ShouldBeTrue)
+		So(referers.Match("http://example.com"), ShouldBeFalse)
+		So(referers.Match("https://app1.example.io/"), ShouldBeTrue)
+		So(referers.Match("https://appx.example.io/"), ShouldBeFalse)
+		indices := Indices([]string{"^logs-[0-9]+$", "books"})
+		So(indices.Match("logs-2021"), ShouldBeTrue)
+		So(indices.Match("logs-latest"), ShouldBeFalse)
+		So(indices.Match("books"), ShouldBeTrue)
+		So(indices.Match("books-old"), ShouldBeFalse)
+		So(Fields([]string{"^(title|author)$"}).Match("subtitle"), ShouldBeFalse)
+		So(Fields([]string{"^(title|author)$"}).Match("author"), ShouldBeTrue)
+		// the invalid expressions are matched as globs
+		So(Referers([]string{"https://example.com/(*"}).Match("https://example.com/(a"), ShouldBeTrue)
+	})
+	Convey("should match the sub fields of the field patterns", t, func() {
+		fields := Fields([]string{"user", "meta.*_at"})
+		So(fields.Match("user"), ShouldBeTrue)
+		So(fields.Match("user.name.first"), ShouldBeTrue)
+		So(fields.Match("username"), ShouldBeFalse)
+		So(fields.Match("meta.created_at"), ShouldBeTrue)
+		So(fields.Match("meta.created_at.raw"), ShouldBeTrue)
+		So(fields.Match("meta.created"), ShouldBeFalse)
+	})
+	Convey("should match the IPs against the sources", t, func() {
+		sources, err := CIDRs([]string{"10.0.0.0/8", "192.168.1.1/32"})
+		So(err, ShouldBeNil)
+		So(sources.Contains(net.ParseIP("10.2.3.4")), ShouldBeTrue)
+		So(sources.Contains(net.ParseIP("192.168.1.2")), ShouldBeFalse)
+		all, err := CIDRs([]string{"::/0"})
+		So(err, ShouldBeNil)
+		So(all.Contains(net.ParseIP("8.8.8.8")), ShouldBeTrue)
+		_, err = CIDRs([]string{"10.0.0.1"})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should cache the compiled matchers until they are forgotten", t, func() {
+		patterns := []string{"books-*"}
+		So(Globs(patterns), ShouldEqual, Globs(patterns))
+		compiled := Globs(patterns)
+		Forget(patterns)
+		So(Globs(patterns), ShouldNotEqual, compiled)
+	})
+	Convey("should drop the unused matchers of the previous generation", t, func() {
+		c := newCache()
+		compile := func() (interface{}, error) { return new(Set), nil }
+		used := c.get(kindGlobs, []string{"used"}, compile)
+		for i := 0; i < maxEntries; i++ {
+			c.get(kindGlobs, []string{fmt.Sprint(i)}, compile)
+		}
+		So(c.get(kindGlobs, []string{"used"}, compile), ShouldEqual, used)
+		for i := 0; i < maxEntries; i++ {
+			c.get(kindGlobs, []string{fmt.Sprint("new", i)}, compile)
+		}
+		So(c.current, ShouldNotContainKey, cacheKey(kindGlobs, []string{"0"}))
+		So(c.previous, ShouldNotContainKey, cacheKey(kindGlobs, []string{"0"}))
+	})
+}
+
+func allowlist(size int) []string {
+	patterns := make([]string, size)
+	for i := range patterns {
+		if i%2 == 0 {
+			patterns[i] = fmt.Sprintf("https://site-%d.example.com", i)
+		} else {
+			patterns[i] = fmt.Sprintf("https://*.site-%d.example.com/*", i)
+		}
+	}
+	return patterns
+}
+
+// naiveMatch is the per request matching the matcher replaces.
+func naiveMatch(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+		if matched, _ := regexp.MatchString(expr, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func BenchmarkNaiveReferers(b *testing.B) {
+	patterns := allowlist(500)
+	for i := 0; i < b.N; i++ {
+		naiveMatch(patterns, "https://app.site-499.example.com/search")
+	}
+}
+
+func BenchmarkCompiledReferers(b *testing.B) {
+	patterns := allowlist(500)
+	for i := 0; i < b.N; i++ {
+		Referers(patterns).Match("https://app.site-499.example.com/search")
+	}
+}
+
+func BenchmarkNaiveSources(b *testing.B) {
+	sources := make([]string, 500)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+	}
+	ip := net.ParseIP("10.1.243.7")
+	for i := 0; i < b.N; i++ {
+		for _, source := range sources {
+			if _, ipNet, _ := net.ParseCIDR(source); ipNet.Contains(ip) {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkCompiledSources(b *testing.B) {
+	sources := make([]string, 500)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+	}
+	ip := net.ParseIP("10.1.243.7")
+	for i := 0; i < b.N; i++ {
+		networks, _ := CIDRs(sources)
+		networks.Contains(ip)
+	}
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// unfilteredKeys are the keys of a search request body that aren't restricted
+// by the query, so they can't be combined with a document filter.
+var unfilteredKeys = []string{"suggest", "knn"}
+
+// ApplyFilterClauses wraps the query of a search request body in a bool query
+// with the passed clauses in the filter context, so that the documents not
+// matching the clauses can never be returned. The requests that can read the
+// documents outside of the query, i.e. the suggesters, the knn searches and
+// the global aggregations, are rejected.
+func ApplyFilterClauses(body map[string]interface{}, clauses []interface{}) (map[string]interface{}, error) {
+	if len(clauses) == 0 {
+		return body, nil
+	}
+	if body == nil {
+		body = make(map[string]interface{})
+	}
+	for _, key := range unfilteredKeys {
+		if _, ok := body[key]; ok {
+			return nil, fmt.Errorf(`"%s" is not allowed for a credential with a document filter`, key)
+		}
+	}
+	for _, key := range []string{"aggs", "aggregations"} {
+		if hasGlobalAggregation(body[key]) {
+			return nil, errors.New(`"global" aggregation is not allowed for a credential with a document filter`)
+		}
+	}
+	query, ok := body["query"]
+	if !ok || query == nil {
+		query = map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		}
+	}
+	body["query"] = map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   []interface{}{query},
+			"filter": clauses,
+		},
+	}
+	return body, nil
+}
+
+// hasGlobalAggregation checks whether any of the aggregations or of their
+// sub aggregations is a global aggregation, which ignores the query.
+func hasGlobalAggregation(aggs interface{}) bool {
+	named, ok := aggs.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, agg := range named {
+		definition, ok := agg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := definition["global"]; ok {
+			return true
+		}
+		if hasGlobalAggregation(definition["aggs"]) || hasGlobalAggregation(definition["aggregations"]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyFilterClausesToMsearch applies the filter clauses to each of the
+// request bodies of a _msearch request, the header lines are kept as is.
+func ApplyFilterClausesToMsearch(body string, clauses []interface{}) (string, error) {
+	if len(clauses) == 0 {
+		return body, nil
+	}
+	lines := strings.Split(body, "\n")
+	var isHeader = true
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if isHeader {
+			isHeader = false
+			continue
+		}
+		isHeader = true
+		reqBody := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &reqBody); err != nil {
+			return "", err
+		}
+		filtered, err := ApplyFilterClauses(reqBody, clauses)
+		if err != nil {
+			return "", err
+		}
+		raw, err := json.Marshal(filtered)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = string(raw)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApplyFilterClauses(t *testing.T) {
+	clauses := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"tenant": "foo"}},
+	}
+	Convey("should wrap the query in a bool filter", t, func() {
+		output, err := ApplyFilterClauses(map[string]interface{}{
+			"query": map[string]interface{}{"match": map[string]interface{}{"title": "bar"}},
+			"size":  5,
+		}, clauses)
+		So(err, ShouldBeNil)
+		So(output, ShouldResemble, map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must":   []interface{}{map[string]interface{}{"match": map[string]interface{}{"title": "bar"}}},
+					"filter": clauses,
+				},
+			},
+			"size": 5,
+		})
+	})
+	Convey("should use match_all without a query", t, func() {
+		output, err := ApplyFilterClauses(nil, clauses)
+		So(err, ShouldBeNil)
+		So(output, ShouldResemble, map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must":   []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}},
+					"filter": clauses,
+				},
+			},
+		})
+	})
+	Convey("should reject the global aggregations", t, func() {
+		_, err := ApplyFilterClauses(map[string]interface{}{
+			"aggs": map[string]interface{}{
+				"all": map[string]interface{}{
+					"global": map[string]interface{}{},
+					"aggs": map[string]interface{}{
+						"docs": map[string]interface{}{"top_hits": map[string]interface{}{}},
+					},
+				},
+			},
+		}, clauses)
+		So(err, ShouldNotBeNil)
+
+		_, err = ApplyFilterClauses(map[string]interface{}{
+			"aggregations": map[string]interface{}{
+				"tags": map[string]interface{}{
+					"terms": map[string]interface{}{"field": "tag"},
+					"aggs": map[string]interface{}{
+						"all": map[string]interface{}{"global": map[string]interface{}{}},
+					},
+				},
+			},
+		}, clauses)
+		So(err, ShouldNotBeNil)
+
+		_, err = ApplyFilterClauses(map[string]interface{}{
+			"aggs": map[string]interface{}{
+				"tags": map[string]interface{}{"terms": map[string]interface{}{"field": "tag"}},
+			},
+		}, clauses)
+		So(err, ShouldBeNil)
+	})
+	Convey("should reject the suggesters and the knn searches", t, func() {
+		_, err := ApplyFilterClauses(map[string]interface{}{
+			"suggest": map[string]interface{}{
+				"title": map[string]interface{}{"text": "ba", "term": map[string]interface{}{"field": "title"}},
+			},
+		}, clauses)
+		So(err, ShouldNotBeNil)
+
+		_, err = ApplyFilterClauses(map[string]interface{}{
+			"knn": map[string]interface{}{"field": "vector", "query_vector": []interface{}{1, 2}, "k": 10},
+		}, clauses)
+		So(err, ShouldNotBeNil)
+
+		_, err = ApplyFilterClausesToMsearch("{}\n{\"suggest\":{}}\n", clauses)
+		So(err, ShouldNotBeNil)
+	})
+	Convey("should only modify the body lines of msearch", t, func() {
+		output, err := ApplyFilterClausesToMsearch("{\"index\":\"test\"}\n{}\n{}\n{\"size\":1}\n", clauses)
+		So(err, ShouldBeNil)
+		So(output, ShouldEqual, "{\"index\":\"test\"}\n"+
+			"{\"query\":{\"bool\":{\"filter\":[{\"term\":{\"tenant\":\"foo\"}}],\"must\":[{\"match_all\":{}}]}}}\n"+
+			"{}\n"+
+			"{\"query\":{\"bool\":{\"filter\":[{\"term\":{\"tenant\":\"foo\"}}],\"must\":[{\"match_all\":{}}]}},\"size\":1}\n")
+	})
+}
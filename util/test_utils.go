@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
-
+	"os"
 	"os/exec"
 	"strconv"
 	"syscall"
@@ -24,6 +24,15 @@ type BuildArc struct {
 	FeatureSuggestions  bool
 }
 
+// SkipWithoutES skips an e2e test when SKIP_ES_TESTS is set, e.g. on the
+// machines without an elasticsearch cluster. The tests fail otherwise, so that
+// they can't pass without running.
+func SkipWithoutES(t interface{ Skip(args ...interface{}) }) {
+	if skip, _ := strconv.ParseBool(os.Getenv("SKIP_ES_TESTS")); skip {
+		t.Skip("skipping e2e test, SKIP_ES_TESTS is set")
+	}
+}
+
 func cleanES() bool {
 	err := exec.Command("/bin/sh", "-c", "curl -XDELETE localhost:9200/.*").Run()
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	appbase_errors "github.com/appbaseio/reactivesearch-api/errors"
+	"github.com/appbaseio/reactivesearch-api/util/matcher"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -427,18 +428,8 @@ func IsRSAPIValidateRoute(req *http.Request) bool {
 	return strings.HasSuffix(req.URL.Path, "_reactivesearch.v3/validate") || strings.HasSuffix(req.URL.Path, "_reactivesearch/validate")
 }
 
-// ValidateIndex validates an index against a pattern
+// ValidateIndex validates an index against a pattern, "*" matches any characters
+// and the patterns stored as regular expressions are matched as such.
 func ValidateIndex(pattern string, index string) (bool, error) {
-	pattern = strings.Replace(pattern, "*", ".*", -1)
-	if !strings.HasSuffix(pattern, ".*") {
-		pattern += "\\b"
-	}
-	if !strings.HasPrefix(pattern, ".*") {
-		pattern = "\\b" + pattern
-	}
-	matched, err := regexp.MatchString(pattern, index)
-	if err != nil {
-		return true, err
-	}
-	return matched, nil
+	return matcher.Indices([]string{pattern}).Match(index), nil
 }
@@ -49,5 +49,13 @@ func TestValidateIndex(t *testing.T) {
 			m, _ := ValidateIndex("test", "ttest")
 			So(m, ShouldBeFalse)
 		})
+		Convey("Stored regex pattern (success)", func() {
+			m, _ := ValidateIndex("^logs-[0-9]+$", "logs-2021")
+			So(m, ShouldBeTrue)
+		})
+		Convey("Stored regex pattern (failure)", func() {
+			m, _ := ValidateIndex("^logs-[0-9]+$", "logs-latest")
+			So(m, ShouldBeFalse)
+		})
 	})
 }